@@ -2,12 +2,13 @@ package tester
 
 import (
 	"crypto/ecdsa"
+	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"math/big"
-	"math/rand"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
@@ -28,7 +29,8 @@ func GenerateECRecoverInput(privateKey *ecdsa.PrivateKey) []byte {
 	// Prepare input data for ecRecover precompiled contract
 	inputData := make([]byte, 128)
 	copy(inputData[0:32], messageHash.Bytes())
-	copy(inputData[32:64], common.LeftPadBytes(signature[64:65], 32))
+	// The recovery id of the signature is 0 or 1, but ecRecover expects v to be 27 or 28.
+	copy(inputData[32:64], common.LeftPadBytes([]byte{signature[64] + 27}, 32))
 	copy(inputData[64:96], common.LeftPadBytes(signature[0:32], 32))
 	copy(inputData[96:128], common.LeftPadBytes(signature[32:64], 32))
 
@@ -174,57 +176,202 @@ func GenerateP256VerifyInput() []byte {
 	return inputData
 }
 
-func CallPrecompiledContracts(address int, lt *LoadTester, opts *bind.TransactOpts, iterations uint64, privateKey *ecdsa.PrivateKey) (*ethtypes.Transaction, error) {
-	var inputData []byte
+// The generators of BLS12-381 and their doubles, in the uncompressed encoding of EIP-2537 where
+// every field element is padded to 64 bytes.
+const (
+	blsG1Generator       = "0000000000000000000000000000000017f1d3a73197d7942695638c4fa9ac0fc3688c4f9774b905a14e3a3f171bac586c55e83ff97a1aeffb3af00adb22c6bb0000000000000000000000000000000008b3f481e3aaa0f1a09e30ed741d8ae4fcf5e095d5d00af600db18cb2c04b3edd03cc744a2888ae40caa232946c5e7e1"
+	blsG1GeneratorDouble = "000000000000000000000000000000000572cbea904d67468808c8eb50a9450c9721db309128012543902d0ac358a62ae28f75bb8f1c7c42c39a8c5529bf0f4e00000000000000000000000000000000166a9d8cabc673a322fda673779d8e3822ba3ecb8670e461f73bb9021d5fd76a4c56d9d4cd16bd1bba86881979749d28"
+	blsG1GeneratorNeg    = "0000000000000000000000000000000017f1d3a73197d7942695638c4fa9ac0fc3688c4f9774b905a14e3a3f171bac586c55e83ff97a1aeffb3af00adb22c6bb00000000000000000000000000000000114d1d6855d545a8aa7d76c8cf2e21f267816aef1db507c96655b9d5caac42364e6f38ba0ecb751bad54dcd6b939c2ca"
+	blsG2Generator       = "00000000000000000000000000000000024aa2b2f08f0a91260805272dc51051c6e47ad4fa403b02b4510b647ae3d1770bac0326a805bbefd48056c8c121bdb80000000000000000000000000000000013e02b6052719f607dacd3a088274f65596bd0d09920b61ab5da61bbdc7f5049334cf11213945d57e5ac7d055d042b7e000000000000000000000000000000000ce5d527727d6e118cc9cdc6da2e351aadfd9baa8cbdd3a76d429a695160d12c923ac9cc3baca289e193548608b82801000000000000000000000000000000000606c4a02ea734cc32acd2b02bc28b99cb3e287e85a763af267492ab572e99ab3f370d275cec1da1aaa9075ff05f79be"
+	blsG2GeneratorDouble = "000000000000000000000000000000001638533957d540a9d2370f17cc7ed5863bc0b995b8825e0ee1ea1e1e4d00dbae81f14b0bf3611b78c952aacab827a053000000000000000000000000000000000a4edef9c1ed7f729f520e47730a124fd70662a904ba1074728114d1031e1572c6c886f6b57ec72a6178288c47c33577000000000000000000000000000000000468fb440d82b0630aeb8dca2b5256789a66da69bf91009cbfe6bd221e47aa8ae88dece9764bf3bd999d95d71e4c9899000000000000000000000000000000000f6d4552fa65dd2638b361543f887136a43253d9c66c411697003f7a13c308f5422e1aa0a59c8967acdefd8b6e36ccf3"
+	// blsFieldElement and blsFieldElement2 are elements of the base field, below its modulus.
+	blsFieldElement  = "000000000000000000000000000000000000000000000000000000000000000094a46dda620ccfbe6811d72390ae64a94a5da48d9694dcacb7fe34efc435e113"
+	blsFieldElement2 = "0000000000000000000000000000000000000000000000000000000000000000a312f5ed9249ef2c47811b91d9af716f2f41ead6dbdd65132d1fbffa2afe7b07"
+)
+
+func mustDecodeHex(inputHex string) []byte {
+	inputData, err := hex.DecodeString(inputHex)
+	if err != nil {
+		panic(err)
+	}
+
+	return inputData
+}
+
+func GeneratePointEvaluationInput() []byte {
+	// The commitment of the constant polynomial p(x) = 2 is 2*G1, so the evaluation at any point
+	// is 2 and the proof is the point at infinity.
+	commitment := mustDecodeHex("a572cbea904d67468808c8eb50a9450c9721db309128012543902d0ac358a62ae28f75bb8f1c7c42c39a8c5529bf0f4e")
+	proof := mustDecodeHex("c00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000")
+	z := mustDecodeHex("594e519ae499312b29433b7dd8a97ff068defcba9755b6d5d00e84c524d67b06")
+	y := common.LeftPadBytes([]byte{2}, 32)
+
+	versionedHash := sha256.Sum256(commitment)
+	versionedHash[0] = 0x01 // VERSIONED_HASH_VERSION_KZG
+
+	inputData := append(versionedHash[:], z...)
+	inputData = append(inputData, y...)
+	inputData = append(inputData, commitment...)
+	inputData = append(inputData, proof...)
+	return inputData
+}
+
+func GenerateBLS12G1AddInput() []byte {
+	return mustDecodeHex(blsG1Generator + blsG1GeneratorDouble)
+}
+
+func GenerateBLS12G1MSMInput() []byte {
+	scalar1 := hex.EncodeToString(common.LeftPadBytes(big.NewInt(0x1234).Bytes(), 32))
+	scalar2 := hex.EncodeToString(common.LeftPadBytes(big.NewInt(0x5678).Bytes(), 32))
+	return mustDecodeHex(blsG1Generator + scalar1 + blsG1GeneratorDouble + scalar2)
+}
+
+func GenerateBLS12G2AddInput() []byte {
+	return mustDecodeHex(blsG2Generator + blsG2GeneratorDouble)
+}
+
+func GenerateBLS12G2MSMInput() []byte {
+	scalar1 := hex.EncodeToString(common.LeftPadBytes(big.NewInt(0x1234).Bytes(), 32))
+	scalar2 := hex.EncodeToString(common.LeftPadBytes(big.NewInt(0x5678).Bytes(), 32))
+	return mustDecodeHex(blsG2Generator + scalar1 + blsG2GeneratorDouble + scalar2)
+}
+
+func GenerateBLS12PairingCheckInput() []byte {
+	// e(G1, G2) * e(-G1, G2) = 1, so the check succeeds.
+	return mustDecodeHex(blsG1Generator + blsG2Generator + blsG1GeneratorNeg + blsG2Generator)
+}
+
+func GenerateBLS12MapFpToG1Input() []byte {
+	return mustDecodeHex(blsFieldElement)
+}
+
+func GenerateBLS12MapFp2ToG2Input() []byte {
+	return mustDecodeHex(blsFieldElement + blsFieldElement2)
+}
+
+// GetPrecompiledContractInput returns a valid input of the precompiled contract.
+func GetPrecompiledContractInput(address int, privateKey *ecdsa.PrivateKey) ([]byte, error) {
+	switch address {
+	case 1:
+		return GenerateECRecoverInput(privateKey), nil
+	case 2:
+		return GenerateSHA256Input(), nil
+	case 3:
+		return GenerateRIPEMD160Input(), nil
+	case 4:
+		return GenerateIdentityInput(), nil
+	case 5:
+		return GenerateModExpInput(), nil
+	case 6:
+		return GenerateECAddInput(), nil
+	case 7:
+		return GenerateECMulInput(), nil
+	case 8:
+		return GenerateECPairingInput(), nil
+	case 9:
+		return GenerateBlake2FInput(), nil
+	case 10:
+		return GeneratePointEvaluationInput(), nil
+	case 11:
+		return GenerateBLS12G1AddInput(), nil
+	case 12:
+		return GenerateBLS12G1MSMInput(), nil
+	case 13:
+		return GenerateBLS12G2AddInput(), nil
+	case 14:
+		return GenerateBLS12G2MSMInput(), nil
+	case 15:
+		return GenerateBLS12PairingCheckInput(), nil
+	case 16:
+		return GenerateBLS12MapFpToG1Input(), nil
+	case 17:
+		return GenerateBLS12MapFp2ToG2Input(), nil
+	case 100:
+		return GenerateP256VerifyInput(), nil
+	}
+
+	return nil, fmt.Errorf("unrecognized precompiled address %d", address)
+}
+
+// GetPrecompiledContractAccount returns the account of the precompiled contract. P256Verify
+// is selected with 100 but lives at 0x100.
+func GetPrecompiledContractAccount(address int) common.Address {
+	if address == 100 {
+		return common.HexToAddress("0x0000000000000000000000000000000000000100")
+	}
+	return common.BigToAddress(big.NewInt(int64(address)))
+}
+
+// PrecompileCallerBin deploys the contract from contracts/src/asm/precompile-caller.easm. The
+// calldata is the address of a precompiled contract and a number of iterations, each as a word,
+// followed by the input that the precompiled contract is called with on every iteration.
+const PrecompileCallerBin = "603080600b6000396000f3604036038060406000376020355b8015602e57600060008360006000355afa602657600080fd5b60019003600d565b00"
+
+// DeployPrecompileCaller deploys the contract used to call the precompiled contracts that the
+// load tester contract doesn't know about.
+func DeployPrecompileCaller(opts *bind.TransactOpts, backend bind.ContractBackend) (common.Address, *ethtypes.Transaction, *bind.BoundContract, error) {
+	return bind.DeployContract(opts, abi.ABI{}, common.FromHex(PrecompileCallerBin), backend)
+}
+
+// CallPrecompiledContracts calls a precompiled contract. The classic precompiles are called
+// through the load tester contract, and the ones that it doesn't know about are called
+// `iterations` times through the precompile caller contract.
+func CallPrecompiledContracts(address int, lt *LoadTester, caller *bind.BoundContract, opts *bind.TransactOpts, iterations uint64, privateKey *ecdsa.PrivateKey) (*ethtypes.Transaction, error) {
+	inputData, err := GetPrecompiledContractInput(address, privateKey)
+	if err != nil {
+		return nil, err
+	}
 
 	switch address {
 	case 1:
 		log.Trace().Str("method", "TestECRecover").Msg("Executing contract method")
-		inputData = GenerateECRecoverInput(privateKey)
 		return lt.TestECRecover(opts, inputData)
 	case 2:
 		log.Trace().Str("method", "TestSHA256").Msg("Executing contract method")
-		inputData = GenerateSHA256Input()
 		return lt.TestSHA256(opts, inputData)
 	case 3:
 		log.Trace().Str("method", "TestRipemd160").Msg("Executing contract method")
-		inputData = GenerateRIPEMD160Input()
 		return lt.TestRipemd160(opts, inputData)
 	case 4:
 		log.Trace().Str("method", "TestIdentity").Msg("Executing contract method")
-		inputData = GenerateIdentityInput()
 		return lt.TestIdentity(opts, inputData)
 	case 5:
 		log.Trace().Str("method", "TestModExp").Msg("Executing contract method")
-		inputData = GenerateModExpInput()
 		return lt.TestModExp(opts, inputData)
 	case 6:
 		log.Trace().Str("method", "TestECAdd").Msg("Executing contract method")
-		inputData = GenerateECAddInput()
 		return lt.TestECAdd(opts, inputData)
 	case 7:
 		log.Trace().Str("method", "TestECMul").Msg("Executing contract method")
-		inputData = GenerateECMulInput()
 		return lt.TestECMul(opts, inputData)
 	case 8:
 		log.Trace().Str("method", "TestECPairing").Msg("Executing contract method")
-		inputData = GenerateECPairingInput()
 		return lt.TestECPairing(opts, inputData)
 	case 9:
 		log.Trace().Str("method", "TestBlake2f").Msg("Executing contract method")
-		inputData = GenerateECPairingInput()
 		return lt.TestBlake2f(opts, inputData)
 	case 100:
 		log.Trace().Str("method", "TestP256Verify").Msg("Executing contract method")
-		inputData = GenerateP256VerifyInput()
 		return lt.TestP256Verify(opts, inputData)
 	}
 
-	return nil, fmt.Errorf("unrecognized precompiled address %d", address)
+	account := GetPrecompiledContractAccount(address)
+	log.Trace().Stringer("account", account).Uint64("iterations", iterations).Msg("Calling precompiled contract")
+	return caller.RawTransact(opts, GetPrecompileCallerInput(account, iterations, inputData))
 }
 
-func GetRandomPrecompiledContractAddress() int {
-	codes := []int{
+// GetPrecompileCallerInput returns the calldata of the precompile caller contract calling the
+// precompiled contract at account with inputData `iterations` times.
+func GetPrecompileCallerInput(account common.Address, iterations uint64, inputData []byte) []byte {
+	data := common.LeftPadBytes(account.Bytes(), 32)
+	data = append(data, common.LeftPadBytes(new(big.Int).SetUint64(iterations).Bytes(), 32)...)
+	return append(data, inputData...)
+}
+
+// GetPrecompiledContractAddresses returns the precompiled contracts that are called in random
+// mode.
+func GetPrecompiledContractAddresses() []int {
+	return []int{
 		1,
 		2,
 		3,
@@ -234,8 +381,14 @@ func GetRandomPrecompiledContractAddress() int {
 		// 7, // NOTE: ecMul requires a lot of gas and buggy
 		8,
 		9,
-		// 100, // P256Verify haven't been implemented on Ethereum yet
+		10,
+		11,
+		12,
+		13,
+		14,
+		15,
+		16,
+		17,
+		100,
 	}
-
-	return codes[rand.Intn(len(codes))]
 }
@@ -0,0 +1,432 @@
+package loadtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+
+	gethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// These are the lengths of the random strings, bytes and dynamic arrays when the spec
+	// doesn't constrain them.
+	abiCallDefaultMaxBytesLength = 32
+	abiCallDefaultMaxArrayLength = 4
+
+	abiCallStringCharacters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+type (
+	// abiCallSpec constrains the functions called in contract-call mode when an ABI is given.
+	abiCallSpec struct {
+		Functions map[string]*abiFunctionSpec `yaml:"functions"`
+	}
+
+	// abiFunctionSpec gives the weight of a function and constrains its arguments, which are
+	// identified by name or by position.
+	abiFunctionSpec struct {
+		Weight *float64               `yaml:"weight"`
+		Args   map[string]*abiArgSpec `yaml:"args"`
+	}
+
+	// abiArgSpec constrains the random values of an argument. Min and max apply to integers,
+	// values to any other non composite type, and the lengths to strings, bytes and dynamic
+	// arrays. The elements of arrays and the components of tuples have their own constraints.
+	abiArgSpec struct {
+		Min        string                 `yaml:"min"`
+		Max        string                 `yaml:"max"`
+		Values     []string               `yaml:"values"`
+		MinLength  *int                   `yaml:"min-length"`
+		MaxLength  *int                   `yaml:"max-length"`
+		Elements   *abiArgSpec            `yaml:"elements"`
+		Components map[string]*abiArgSpec `yaml:"components"`
+	}
+
+	// abiCallFunction is a function that can be picked in contract-call mode.
+	abiCallFunction struct {
+		Method gethabi.Method
+		Spec   *abiFunctionSpec
+	}
+
+	// abiCallGenerator picks the functions of the contract by weight and generates random
+	// arguments for them.
+	abiCallGenerator struct {
+		abi               gethabi.ABI
+		functions         []abiCallFunction
+		cumulativeWeights []float64
+	}
+
+	// contractArtifact is the subset of the Foundry and Hardhat build artifacts holding the ABI.
+	contractArtifact struct {
+		ABI json.RawMessage `json:"abi"`
+	}
+)
+
+var contractCalls *abiCallGenerator
+
+// newABICallGenerator reads the ABI of the contract and the optional spec. Without a spec,
+// every function that isn't view or pure is called with the same weight. With a spec that
+// lists functions, only those functions are called.
+func newABICallGenerator(abiFileName, specFileName string) (*abiCallGenerator, error) {
+	contractABI, err := readContractABI(abiFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := new(abiCallSpec)
+	if specFileName != "" {
+		data, iErr := os.ReadFile(specFileName)
+		if iErr != nil {
+			return nil, iErr
+		}
+		if err = yaml.Unmarshal(data, spec); err != nil {
+			return nil, fmt.Errorf("unable to parse the contract call spec %s: %w", specFileName, err)
+		}
+	}
+
+	names := make([]string, 0, len(contractABI.Methods))
+	for name := range contractABI.Methods {
+		names = append(names, name)
+	}
+	// The methods are sorted so that the same seed picks the same functions.
+	sort.Strings(names)
+
+	g := &abiCallGenerator{abi: contractABI}
+	matched := make(map[string]bool)
+	var totalWeight float64
+	for _, name := range names {
+		method := contractABI.Methods[name]
+		functionSpec, key := spec.getFunctionSpec(method)
+		weight := 1.0
+		if len(spec.Functions) > 0 {
+			if functionSpec == nil {
+				continue
+			}
+			matched[key] = true
+			if functionSpec.Weight != nil {
+				weight = *functionSpec.Weight
+			}
+		} else if method.IsConstant() {
+			continue
+		}
+		if weight < 0 {
+			return nil, fmt.Errorf("the weight of the %s function can't be negative", method.Sig)
+		}
+		if weight == 0 {
+			continue
+		}
+		totalWeight += weight
+		g.functions = append(g.functions, abiCallFunction{Method: method, Spec: functionSpec})
+		g.cumulativeWeights = append(g.cumulativeWeights, totalWeight)
+	}
+	for key := range spec.Functions {
+		if !matched[key] {
+			return nil, fmt.Errorf("the function %s of the contract call spec isn't in the ABI", key)
+		}
+	}
+	if len(g.functions) == 0 {
+		return nil, fmt.Errorf("no function of %s can be called", abiFileName)
+	}
+
+	// Every function is encoded once so that the errors of the spec show up before the load
+	// test starts.
+	for _, f := range g.functions {
+		if _, err = g.getCalldata(f); err != nil {
+			return nil, fmt.Errorf("unable to generate the arguments of %s: %w", f.Method.Sig, err)
+		}
+	}
+	log.Debug().Int("functions", len(g.functions)).Msg("Loaded the contract ABI")
+	return g, nil
+}
+
+// readContractABI reads an ABI, either on its own or from a Foundry or Hardhat build artifact.
+func readContractABI(fileName string) (gethabi.ABI, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return gethabi.ABI{}, err
+	}
+	var artifact contractArtifact
+	if json.Unmarshal(data, &artifact) == nil && len(artifact.ABI) > 0 {
+		data = artifact.ABI
+	}
+	contractABI, err := gethabi.JSON(bytes.NewReader(data))
+	if err != nil {
+		return gethabi.ABI{}, fmt.Errorf("unable to parse the ABI %s: %w", fileName, err)
+	}
+	return contractABI, nil
+}
+
+// getFunctionSpec returns the spec of a method, which is looked up by signature, by name and
+// then by the name shared by the overloaded methods.
+func (s *abiCallSpec) getFunctionSpec(method gethabi.Method) (*abiFunctionSpec, string) {
+	for _, key := range []string{method.Sig, method.Name, method.RawName} {
+		if functionSpec, ok := s.Functions[key]; ok {
+			if functionSpec == nil {
+				functionSpec = new(abiFunctionSpec)
+			}
+			return functionSpec, key
+		}
+	}
+	return nil, ""
+}
+
+// getRandomCall picks a function by weight and returns it along with its calldata.
+func (g *abiCallGenerator) getRandomCall() (gethabi.Method, []byte, error) {
+	r := randSrc.Float64() * g.cumulativeWeights[len(g.cumulativeWeights)-1]
+	idx := sort.SearchFloat64s(g.cumulativeWeights, r)
+	if idx >= len(g.functions) {
+		idx = len(g.functions) - 1
+	}
+	f := g.functions[idx]
+	calldata, err := g.getCalldata(f)
+	return f.Method, calldata, err
+}
+
+// getCalldata encodes a call to the function with random arguments.
+func (g *abiCallGenerator) getCalldata(f abiCallFunction) ([]byte, error) {
+	args := make([]interface{}, 0, len(f.Method.Inputs))
+	for k, input := range f.Method.Inputs {
+		var argSpec *abiArgSpec
+		if f.Spec != nil {
+			argSpec = getArgSpec(f.Spec.Args, input.Name, k)
+		}
+		value, err := getRandomABIValue(input.Type, argSpec)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d (%s): %w", k, input.Name, err)
+		}
+		args = append(args, value.Interface())
+	}
+	return g.abi.Pack(f.Method.Name, args...)
+}
+
+// getArgSpec returns the constraints of an argument or of a tuple component, which are
+// identified by name or by position.
+func getArgSpec(specs map[string]*abiArgSpec, name string, position int) *abiArgSpec {
+	if argSpec, ok := specs[name]; ok && name != "" {
+		return argSpec
+	}
+	return specs[strconv.Itoa(position)]
+}
+
+// getRandomABIValue returns a random value of the Go type used by the ABI encoder for t.
+func getRandomABIValue(t gethabi.Type, spec *abiArgSpec) (reflect.Value, error) {
+	if spec == nil {
+		spec = new(abiArgSpec)
+	}
+	if len(spec.Values) > 0 {
+		return parseABIValue(t, spec.Values[randSrc.Intn(len(spec.Values))])
+	}
+
+	switch t.T {
+	case gethabi.IntTy, gethabi.UintTy:
+		low, high, err := getABIIntegerBounds(t, spec)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return toABIInteger(t, getRandomBigInt(low, high)), nil
+	case gethabi.BoolTy:
+		return reflect.ValueOf(randSrc.Intn(2) == 1), nil
+	case gethabi.AddressTy:
+		return reflect.ValueOf(*getRandomAddress()), nil
+	case gethabi.StringTy:
+		length, err := getRandomLength(spec, abiCallDefaultMaxBytesLength)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		b := make([]byte, length)
+		for k := range b {
+			b[k] = abiCallStringCharacters[randSrc.Intn(len(abiCallStringCharacters))]
+		}
+		return reflect.ValueOf(string(b)), nil
+	case gethabi.BytesTy:
+		length, err := getRandomLength(spec, abiCallDefaultMaxBytesLength)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		b := make([]byte, length)
+		_, _ = randSrc.Read(b)
+		return reflect.ValueOf(b), nil
+	case gethabi.FixedBytesTy, gethabi.FunctionTy:
+		v := reflect.New(t.GetType()).Elem()
+		b := make([]byte, v.Len())
+		_, _ = randSrc.Read(b)
+		reflect.Copy(v, reflect.ValueOf(b))
+		return v, nil
+	case gethabi.SliceTy:
+		length, err := getRandomLength(spec, abiCallDefaultMaxArrayLength)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v := reflect.MakeSlice(t.GetType(), length, length)
+		for k := 0; k < length; k++ {
+			element, err := getRandomABIValue(*t.Elem, spec.Elements)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.Index(k).Set(element)
+		}
+		return v, nil
+	case gethabi.ArrayTy:
+		v := reflect.New(t.GetType()).Elem()
+		for k := 0; k < t.Size; k++ {
+			element, err := getRandomABIValue(*t.Elem, spec.Elements)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.Index(k).Set(element)
+		}
+		return v, nil
+	case gethabi.TupleTy:
+		v := reflect.New(t.GetType()).Elem()
+		for k, elem := range t.TupleElems {
+			component, err := getRandomABIValue(*elem, getArgSpec(spec.Components, t.TupleRawNames[k], k))
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.Field(k).Set(component)
+		}
+		return v, nil
+	default:
+		return reflect.Value{}, fmt.Errorf("unsupported ABI type: %s", t.String())
+	}
+}
+
+// getABIIntegerBounds returns the range of the random values of an integer type, narrowed
+// by the spec.
+func getABIIntegerBounds(t gethabi.Type, spec *abiArgSpec) (*big.Int, *big.Int, error) {
+	var low, high *big.Int
+	if t.T == gethabi.UintTy {
+		low = big.NewInt(0)
+		high = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(t.Size)), big.NewInt(1))
+	} else {
+		high = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(t.Size-1)), big.NewInt(1))
+		low = new(big.Int).Neg(new(big.Int).Add(high, big.NewInt(1)))
+	}
+	if spec.Min != "" {
+		specLow, ok := new(big.Int).SetString(spec.Min, 0)
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid min: %s", spec.Min)
+		}
+		if specLow.Cmp(low) > 0 {
+			low = specLow
+		}
+	}
+	if spec.Max != "" {
+		specHigh, ok := new(big.Int).SetString(spec.Max, 0)
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid max: %s", spec.Max)
+		}
+		if specHigh.Cmp(high) < 0 {
+			high = specHigh
+		}
+	}
+	if low.Cmp(high) > 0 {
+		return nil, nil, fmt.Errorf("the range [%s, %s] is empty for %s", low, high, t.String())
+	}
+	return low, high, nil
+}
+
+// getRandomBigInt returns a random integer between low and high, both included.
+func getRandomBigInt(low, high *big.Int) *big.Int {
+	span := new(big.Int).Sub(high, low)
+	span.Add(span, big.NewInt(1))
+	// The extra bytes make the modulo bias negligible.
+	b := make([]byte, len(span.Bytes())+8)
+	_, _ = randSrc.Read(b)
+	n := new(big.Int).SetBytes(b)
+	n.Mod(n, span)
+	return n.Add(n, low)
+}
+
+// toABIInteger converts an integer to the Go type used by the ABI encoder for t, which is
+// either a sized integer or a big integer.
+func toABIInteger(t gethabi.Type, n *big.Int) reflect.Value {
+	goType := t.GetType()
+	if goType == reflect.TypeOf(&big.Int{}) {
+		return reflect.ValueOf(n)
+	}
+	v := reflect.New(goType).Elem()
+	if t.T == gethabi.UintTy {
+		v.SetUint(n.Uint64())
+	} else {
+		v.SetInt(n.Int64())
+	}
+	return v
+}
+
+// getRandomLength returns a random length for strings, bytes and dynamic arrays.
+func getRandomLength(spec *abiArgSpec, defaultMax int) (int, error) {
+	low, high := 0, defaultMax
+	if spec.MinLength != nil {
+		low = *spec.MinLength
+		high = max(high, low)
+	}
+	if spec.MaxLength != nil {
+		high = *spec.MaxLength
+	}
+	if low < 0 || low > high {
+		return 0, fmt.Errorf("invalid length range [%d, %d]", low, high)
+	}
+	return low + randSrc.Intn(high-low+1), nil
+}
+
+// parseABIValue parses a value given in the spec for a non composite type.
+func parseABIValue(t gethabi.Type, s string) (reflect.Value, error) {
+	switch t.T {
+	case gethabi.IntTy, gethabi.UintTy:
+		n, ok := new(big.Int).SetString(s, 0)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("invalid integer: %s", s)
+		}
+		low, high, err := getABIIntegerBounds(t, new(abiArgSpec))
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if n.Cmp(low) < 0 || n.Cmp(high) > 0 {
+			return reflect.Value{}, fmt.Errorf("%s is out of range for %s", s, t.String())
+		}
+		return toABIInteger(t, n), nil
+	case gethabi.BoolTy:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(b), nil
+	case gethabi.AddressTy:
+		if !ethcommon.IsHexAddress(s) {
+			return reflect.Value{}, fmt.Errorf("invalid address: %s", s)
+		}
+		return reflect.ValueOf(ethcommon.HexToAddress(s)), nil
+	case gethabi.StringTy:
+		return reflect.ValueOf(s), nil
+	case gethabi.BytesTy:
+		b, err := hexutil.Decode(s)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid bytes %s: %w", s, err)
+		}
+		return reflect.ValueOf(b), nil
+	case gethabi.FixedBytesTy, gethabi.FunctionTy:
+		b, err := hexutil.Decode(s)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid bytes %s: %w", s, err)
+		}
+		v := reflect.New(t.GetType()).Elem()
+		if len(b) != v.Len() {
+			return reflect.Value{}, fmt.Errorf("%s isn't %d bytes long", s, v.Len())
+		}
+		reflect.Copy(v, reflect.ValueOf(b))
+		return v, nil
+	default:
+		return reflect.Value{}, fmt.Errorf("values can't be given for %s arguments", t.String())
+	}
+}
@@ -0,0 +1,138 @@
+package loadtest
+
+import (
+	"math/big"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	gethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetRandomABIValue tests that the random values can be encoded for their ABI type and
+// follow the constraints of the spec.
+func TestGetRandomABIValue(t *testing.T) {
+	type Test struct {
+		Name       string
+		Type       string
+		Components []gethabi.ArgumentMarshaling
+		Spec       *abiArgSpec
+		Check      func(t *testing.T, v reflect.Value)
+		ErrMsg     string
+	}
+
+	length := func(n int) *int { return &n }
+	tests := []Test{
+		{Name: "uint8", Type: "uint8"},
+		{Name: "int256", Type: "int256"},
+		{Name: "bool", Type: "bool"},
+		{Name: "address", Type: "address"},
+		{Name: "bytes32", Type: "bytes32"},
+		{
+			Name: "uint256 range",
+			Type: "uint256",
+			Spec: &abiArgSpec{Min: "10", Max: "0x14"},
+			Check: func(t *testing.T, v reflect.Value) {
+				n := v.Interface().(*big.Int)
+				assert.True(t, n.Cmp(big.NewInt(10)) >= 0 && n.Cmp(big.NewInt(20)) <= 0, "%s out of range", n)
+			},
+		},
+		{
+			Name: "int16 range",
+			Type: "int16",
+			Spec: &abiArgSpec{Min: "-5", Max: "5"},
+			Check: func(t *testing.T, v reflect.Value) {
+				assert.True(t, v.Int() >= -5 && v.Int() <= 5, "%d out of range", v.Int())
+			},
+		},
+		{
+			Name: "int64 values",
+			Type: "int64",
+			Spec: &abiArgSpec{Values: []string{"-1", "7"}},
+			Check: func(t *testing.T, v reflect.Value) {
+				assert.Contains(t, []int64{-1, 7}, v.Int())
+			},
+		},
+		{
+			Name: "address values",
+			Type: "address",
+			Spec: &abiArgSpec{Values: []string{"0x00000000000000000000000000000000000000aa"}},
+			Check: func(t *testing.T, v reflect.Value) {
+				assert.Equal(t, ethcommon.HexToAddress("0xaa"), v.Interface())
+			},
+		},
+		{
+			Name: "string length",
+			Type: "string",
+			Spec: &abiArgSpec{MinLength: length(3), MaxLength: length(3)},
+			Check: func(t *testing.T, v reflect.Value) {
+				assert.Len(t, v.String(), 3)
+			},
+		},
+		{
+			Name: "empty bytes",
+			Type: "bytes",
+			Spec: &abiArgSpec{MaxLength: length(0)},
+			Check: func(t *testing.T, v reflect.Value) {
+				assert.Equal(t, 0, v.Len())
+			},
+		},
+		{
+			Name: "dynamic array",
+			Type: "uint32[]",
+			Spec: &abiArgSpec{MinLength: length(2), MaxLength: length(2), Elements: &abiArgSpec{Max: "5"}},
+			Check: func(t *testing.T, v reflect.Value) {
+				assert.Equal(t, 2, v.Len())
+				for k := 0; k < v.Len(); k++ {
+					assert.LessOrEqual(t, v.Index(k).Uint(), uint64(5))
+				}
+			},
+		},
+		{
+			Name: "fixed array",
+			Type: "uint8[3]",
+			Check: func(t *testing.T, v reflect.Value) {
+				assert.Equal(t, 3, v.Len())
+			},
+		},
+		{
+			Name:       "tuple",
+			Type:       "tuple",
+			Components: []gethabi.ArgumentMarshaling{{Name: "amount", Type: "uint256"}, {Name: "to", Type: "address"}},
+			Spec:       &abiArgSpec{Components: map[string]*abiArgSpec{"amount": {Values: []string{"42"}}}},
+			Check: func(t *testing.T, v reflect.Value) {
+				assert.Equal(t, big.NewInt(42), v.Field(0).Interface())
+			},
+		},
+		{Name: "empty range", Type: "uint8", Spec: &abiArgSpec{Min: "300"}, ErrMsg: "is empty"},
+		{Name: "invalid min", Type: "uint8", Spec: &abiArgSpec{Min: "x"}, ErrMsg: "invalid min"},
+		{Name: "value out of range", Type: "uint8", Spec: &abiArgSpec{Values: []string{"256"}}, ErrMsg: "out of range"},
+		{Name: "invalid address", Type: "address", Spec: &abiArgSpec{Values: []string{"0x01"}}, ErrMsg: "invalid address"},
+		{Name: "invalid length range", Type: "string", Spec: &abiArgSpec{MinLength: length(4), MaxLength: length(2)}, ErrMsg: "invalid length range"},
+	}
+
+	defer func(r *rand.Rand) { randSrc = r }(randSrc)
+	randSrc = rand.New(rand.NewSource(1))
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			abiType, err := gethabi.NewType(tc.Type, "", tc.Components)
+			require.NoError(t, err)
+			for range 20 {
+				v, err := getRandomABIValue(abiType, tc.Spec)
+				if tc.ErrMsg != "" {
+					assert.ErrorContains(t, err, tc.ErrMsg)
+					return
+				}
+				require.NoError(t, err)
+				_, err = gethabi.Arguments{{Type: abiType}}.Pack(v.Interface())
+				require.NoError(t, err)
+				if tc.Check != nil {
+					tc.Check(t, v)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	accessListAccurate     = "accurate"
+	accessListOverDeclared = "over-declared"
+	accessListEmpty        = "empty"
+
+	accessListSourceRPC       = "rpc"
+	accessListSourceSynthetic = "synthetic"
+
+	// These are the storage slots of the LoadTester contract.
+	loadTesterCallCounterSlot = 0
+	loadTesterDumpsterSlot    = 2
+)
+
+type createAccessListResult struct {
+	AccessList ethtypes.AccessList `json:"accessList"`
+	GasUsed    hexutil.Uint64      `json:"gasUsed"`
+	Error      string              `json:"error,omitempty"`
+}
+
+// getAccessList returns the access list of a call to the load test contract, depending on the
+// kind of list and on its source.
+func getAccessList(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client, msg ethereum.CallMsg, method string, inputData []byte) (ethtypes.AccessList, error) {
+	ltp := inputLoadTestParams
+	if *ltp.AccessList == accessListEmpty {
+		return ethtypes.AccessList{}, nil
+	}
+
+	var accessList ethtypes.AccessList
+	var err error
+	if *ltp.AccessListSource == accessListSourceRPC {
+		accessList, err = createAccessList(ctx, rpc, msg)
+	} else {
+		accessList, err = getSyntheticAccessList(ctx, c, *msg.To, method, inputData)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if *ltp.AccessList == accessListOverDeclared {
+		accessList = overDeclareAccessList(accessList, *msg.To, *ltp.AccessListExtraEntries)
+	}
+	return accessList, nil
+}
+
+// createAccessList asks the RPC endpoint for the access list of the call with
+// eth_createAccessList.
+func createAccessList(ctx context.Context, rpc *ethrpc.Client, msg ethereum.CallMsg) (ethtypes.AccessList, error) {
+	args := map[string]interface{}{
+		"from":  msg.From,
+		"to":    msg.To,
+		"input": hexutil.Bytes(msg.Data),
+	}
+	var result createAccessListResult
+	if err := rpc.CallContext(ctx, &result, "eth_createAccessList", args, "latest"); err != nil {
+		return nil, err
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("unable to create the access list: %s", result.Error)
+	}
+	return result.AccessList, nil
+}
+
+// getSyntheticAccessList builds the access list of a call to the load test contract from the
+// storage layout of the contract, without simulating the call. The slot of the new dumpster
+// element depends on the number of elements, which is read from the contract, so the list can
+// be wrong if other transactions store bytes in the meantime.
+func getSyntheticAccessList(ctx context.Context, c *ethclient.Client, ltAddress ethcommon.Address, method string, inputData []byte) (ethtypes.AccessList, error) {
+	switch method {
+	case "inc":
+		return ethtypes.AccessList{{
+			Address:     ltAddress,
+			StorageKeys: []ethcommon.Hash{ethcommon.BigToHash(big.NewInt(loadTesterCallCounterSlot))},
+		}}, nil
+	case "store":
+		dumpsterSlot := ethcommon.BigToHash(big.NewInt(loadTesterDumpsterSlot))
+		length, err := c.StorageAt(ctx, ltAddress, dumpsterSlot, nil)
+		if err != nil {
+			return nil, err
+		}
+		// The elements of a dynamic array start at the hash of its slot.
+		elementSlot := new(big.Int).SetBytes(ethcrypto.Keccak256(dumpsterSlot.Bytes()))
+		elementSlot.Add(elementSlot, new(big.Int).SetBytes(length))
+		storageKeys := []ethcommon.Hash{dumpsterSlot, ethcommon.BigToHash(elementSlot)}
+		// The content of bytes longer than 31 bytes is stored starting at the hash of the slot.
+		if len(inputData) > 31 {
+			dataSlot := new(big.Int).SetBytes(ethcrypto.Keccak256(ethcommon.BigToHash(elementSlot).Bytes()))
+			for k := 0; k < (len(inputData)+31)/32; k++ {
+				storageKeys = append(storageKeys, ethcommon.BigToHash(new(big.Int).Add(dataSlot, big.NewInt(int64(k)))))
+			}
+		}
+		return ethtypes.AccessList{{Address: ltAddress, StorageKeys: storageKeys}}, nil
+	default:
+		return nil, fmt.Errorf("no synthetic access list for the %s function", method)
+	}
+}
+
+// overDeclareAccessList adds storage keys of the load test contract and addresses that aren't
+// accessed by the call.
+func overDeclareAccessList(accessList ethtypes.AccessList, ltAddress ethcommon.Address, extraEntries uint64) ethtypes.AccessList {
+	extraKeys := make([]ethcommon.Hash, 0, extraEntries)
+	for k := uint64(0); k < extraEntries; k++ {
+		var key ethcommon.Hash
+		_, _ = randSrc.Read(key[:])
+		extraKeys = append(extraKeys, key)
+	}
+
+	overDeclared := make(ethtypes.AccessList, 0, len(accessList)+int(extraEntries)+1)
+	hasContract := false
+	for _, tuple := range accessList {
+		if tuple.Address == ltAddress {
+			tuple.StorageKeys = append(append([]ethcommon.Hash{}, tuple.StorageKeys...), extraKeys...)
+			hasContract = true
+		}
+		overDeclared = append(overDeclared, tuple)
+	}
+	if !hasContract {
+		overDeclared = append(overDeclared, ethtypes.AccessTuple{Address: ltAddress, StorageKeys: extraKeys})
+	}
+	for k := uint64(0); k < extraEntries; k++ {
+		overDeclared = append(overDeclared, ethtypes.AccessTuple{Address: *getRandomAddress(), StorageKeys: []ethcommon.Hash{}})
+	}
+	return overDeclared
+}
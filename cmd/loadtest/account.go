@@ -0,0 +1,347 @@
+package loadtest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/0xPolygon/polygon-cli/bindings/funder"
+	"github.com/0xPolygon/polygon-cli/bindings/tokens"
+	"github.com/0xPolygon/polygon-cli/hdwallet"
+	"github.com/0xPolygon/polygon-cli/util"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+// loadTestAccount is an account used to send load test transactions. Every account
+// keeps track of its own nonce so that several senders can be used concurrently.
+type loadTestAccount struct {
+	PrivateKey *ecdsa.PrivateKey
+	Address    ethcommon.Address
+	StartNonce uint64
+
+	nonce      uint64
+	nonceMutex sync.RWMutex
+}
+
+// sendingAccountsFileEntry matches the entries of the wallets file written by `polycli fund`.
+type sendingAccountsFileEntry struct {
+	Address    string `json:"Address"`
+	PrivateKey string `json:"PrivateKey"`
+}
+
+var sendingAccounts []*loadTestAccount
+
+func newLoadTestAccount(privateKey *ecdsa.PrivateKey) *loadTestAccount {
+	return &loadTestAccount{
+		PrivateKey: privateKey,
+		Address:    ethcrypto.PubkeyToAddress(privateKey.PublicKey),
+	}
+}
+
+// initNonce sets the start nonce of the account using its pending nonce, unless a start
+// nonce is given.
+func (a *loadTestAccount) initNonce(ctx context.Context, c *ethclient.Client, startNonce uint64) error {
+	a.nonceMutex.Lock()
+	defer a.nonceMutex.Unlock()
+
+	// Get pending nonce to be prevent nonce collision (if tx from same sender is already present)
+	nonce, err := c.PendingNonceAt(ctx, a.Address)
+	if err != nil {
+		log.Error().Err(err).Stringer("address", a.Address).Msg("Unable to get account nonce")
+		return err
+	}
+	if startNonce > 0 {
+		nonce = startNonce
+	}
+	a.StartNonce = nonce
+	a.nonce = nonce
+	return nil
+}
+
+// nextNonce returns the nonce that should be used for the next transaction and
+// increments the nonce tracker.
+func (a *loadTestAccount) nextNonce() uint64 {
+	a.nonceMutex.Lock()
+	defer a.nonceMutex.Unlock()
+	nonce := a.nonce
+	a.nonce = a.nonce + 1
+	return nonce
+}
+
+// reserveNonces hands out a block of consecutive nonces and returns the first one.
+func (a *loadTestAccount) reserveNonces(count uint64) uint64 {
+	a.nonceMutex.Lock()
+	defer a.nonceMutex.Unlock()
+	nonce := a.nonce
+	a.nonce = a.nonce + count
+	return nonce
+}
+
+// currentNonce returns the first nonce that hasn't been handed out yet.
+func (a *loadTestAccount) currentNonce() uint64 {
+	a.nonceMutex.RLock()
+	defer a.nonceMutex.RUnlock()
+	return a.nonce
+}
+
+// getSendingAccount returns the account that a go routine should use to send transactions.
+// Go routines are spread evenly across the sending accounts.
+func getSendingAccount(routine int64) *loadTestAccount {
+	return sendingAccounts[int(routine)%len(sendingAccounts)]
+}
+
+// getSentTransactionCount returns the number of nonces used across all the sending accounts.
+func getSentTransactionCount() uint64 {
+	var count uint64
+	for _, a := range sendingAccounts {
+		count += a.currentNonce() - a.StartNonce
+	}
+	return count
+}
+
+// getSendingAccountsNonceLag returns the number of transactions sent by the sending accounts
+// that aren't included as of the given block. A nil block number means the latest block.
+func getSendingAccountsNonceLag(ctx context.Context, c *ethclient.Client, blockNumber *big.Int) (uint64, error) {
+	var lag uint64
+	for _, a := range sendingAccounts {
+		nonce, err := c.NonceAt(ctx, a.Address, blockNumber)
+		if err != nil {
+			return 0, err
+		}
+		if current := a.currentNonce(); current > nonce {
+			lag += current - nonce
+		}
+	}
+	return lag, nil
+}
+
+// initSendingAccounts builds the list of accounts used to send transactions. By default, the
+// only sending account is the one from `--private-key`. When more sending accounts are
+// requested, they're either loaded from a wallets file or derived from the private key, and
+// funded by the `--private-key` account.
+func initSendingAccounts(ctx context.Context, c *ethclient.Client, tops *bind.TransactOpts) error {
+	ltp := inputLoadTestParams
+	count := *ltp.SendingAccounts
+	file := *ltp.SendingAccountsFile
+
+	// The sending accounts of a worker are funded by the coordinator.
+	if workerPrivateKeys != nil {
+		sendingAccounts = getWorkerSendingAccounts()
+		log.Info().Int("count", len(sendingAccounts)).Msg("Initialized the sending accounts of the worker")
+		warnUnusedSendingAccounts()
+		return nil
+	}
+
+	if count <= 1 && file == "" {
+		sendingAccounts = []*loadTestAccount{newLoadTestAccount(ltp.ECDSAPrivateKey)}
+		return nil
+	}
+
+	var privateKeys []*ecdsa.PrivateKey
+	var err error
+	if file != "" {
+		privateKeys, err = loadSendingAccountsFile(file, count)
+	} else {
+		privateKeys, err = deriveSendingAccounts(*ltp.PrivateKey, count)
+	}
+	if err != nil {
+		return err
+	}
+
+	sendingAccounts = make([]*loadTestAccount, 0, len(privateKeys))
+	for _, pk := range privateKeys {
+		account := newLoadTestAccount(pk)
+		if account.Address == *ltp.FromETHAddress {
+			return fmt.Errorf("the sending account %s is the same as the funding account", account.Address)
+		}
+		sendingAccounts = append(sendingAccounts, account)
+	}
+	log.Info().Int("count", len(sendingAccounts)).Msg("Initialized sending accounts")
+	warnUnusedSendingAccounts()
+
+	addresses := make([]ethcommon.Address, 0, len(sendingAccounts))
+	for _, a := range sendingAccounts {
+		addresses = append(addresses, a.Address)
+	}
+	return fundSendingAccounts(ctx, c, tops, addresses)
+}
+
+// warnUnusedSendingAccounts warns when there are more sending accounts than go routines, since
+// every go routine sends from a single account and the other accounts are funded for nothing.
+func warnUnusedSendingAccounts() {
+	concurrency := *inputLoadTestParams.Concurrency
+	if int64(len(sendingAccounts)) > concurrency {
+		log.Warn().
+			Int("sendingAccounts", len(sendingAccounts)).
+			Int64("concurrency", concurrency).
+			Msg("There are more sending accounts than go routines. The extra accounts won't send any transaction")
+	}
+}
+
+// deriveSendingAccounts derives the private keys of the sending accounts from the default
+// mnemonic. The private key of the funding account is used as the passphrase so that
+// different funding accounts get different sending accounts.
+func deriveSendingAccounts(passphrase string, count uint64) ([]*ecdsa.PrivateKey, error) {
+	wallet, err := hdwallet.NewPolyWallet(codeQualitySeed, strings.TrimPrefix(passphrase, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	derivedWallets, err := wallet.ExportHDAddresses(int(count))
+	if err != nil {
+		return nil, err
+	}
+
+	privateKeys := make([]*ecdsa.PrivateKey, 0, count)
+	for _, w := range derivedWallets.Addresses {
+		pk, err := ethcrypto.HexToECDSA(w.HexPrivateKey)
+		if err != nil {
+			log.Error().Err(err).Str("path", w.Path).Msg("Couldn't process the derived private key")
+			return nil, err
+		}
+		log.Trace().Str("address", w.ETHAddress).Str("path", w.Path).Msg("Derived sending account")
+		privateKeys = append(privateKeys, pk)
+	}
+	return privateKeys, nil
+}
+
+// loadSendingAccountsFile reads the private keys of the sending accounts from a wallets file
+// written by `polycli fund`. If count is greater than one, only the first count accounts are used.
+func loadSendingAccountsFile(fileName string, count uint64) ([]*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var entries []sendingAccountsFileEntry
+	if err = json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no wallets found in %s", fileName)
+	}
+	if count > 1 {
+		if uint64(len(entries)) < count {
+			return nil, fmt.Errorf("%d sending accounts were requested but %s only has %d wallets", count, fileName, len(entries))
+		}
+		entries = entries[:count]
+	}
+
+	privateKeys := make([]*ecdsa.PrivateKey, 0, len(entries))
+	for _, e := range entries {
+		pk, err := ethcrypto.HexToECDSA(strings.TrimPrefix(e.PrivateKey, "0x"))
+		if err != nil {
+			log.Error().Err(err).Str("address", e.Address).Msg("Couldn't process the wallet private key")
+			return nil, err
+		}
+		privateKeys = append(privateKeys, pk)
+	}
+	return privateKeys, nil
+}
+
+// fundSendingAccounts tops up every sending account whose balance is below the funding
+// amount, using a `Funder` contract deployed by the funding account.
+func fundSendingAccounts(ctx context.Context, c *ethclient.Client, tops *bind.TransactOpts, sendingAddresses []ethcommon.Address) error {
+	ltp := inputLoadTestParams
+	amount := util.EthToWei(*ltp.SendingAccountsFundingAmount)
+	if amount.Sign() == 0 {
+		log.Info().Msg("Skipping the funding of the sending accounts")
+		return nil
+	}
+
+	addresses := make([]ethcommon.Address, 0)
+	for _, address := range sendingAddresses {
+		balance, err := c.BalanceAt(ctx, address, nil)
+		if err != nil {
+			log.Error().Err(err).Stringer("address", address).Msg("Unable to get the balance for the account")
+			return err
+		}
+		if balance.Cmp(amount) < 0 {
+			addresses = append(addresses, address)
+		}
+	}
+	if len(addresses) == 0 {
+		log.Info().Msg("All the sending accounts are already funded")
+		return nil
+	}
+
+	funderAddr, tx, _, err := funder.DeployFunder(tops, c, amount)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to deploy Funder contract")
+		return err
+	}
+	if _, err = bind.WaitDeployed(ctx, c, tx); err != nil {
+		log.Error().Err(err).Msg("Unable to wait for the Funder contract deployment")
+		return err
+	}
+	log.Debug().Stringer("address", funderAddr).Msg("Funder contract deployed")
+
+	total := new(big.Int).Mul(amount, big.NewInt(int64(len(addresses))))
+	if err = util.SendTx(ctx, c, ltp.ECDSAPrivateKey, &funderAddr, total, nil, uint64(30000)); err != nil {
+		log.Error().Err(err).Msg("Unable to fund the Funder contract")
+		return err
+	}
+
+	funderContract, err := funder.NewFunder(funderAddr, c)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to instantiate Funder contract")
+		return err
+	}
+	tx, err = funderContract.BulkFund(tops, addresses)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to bulk fund the sending accounts")
+		return err
+	}
+	receipt, err := bind.WaitMined(ctx, c, tx)
+	if err != nil {
+		return err
+	}
+	if receipt.Status != 1 {
+		return errors.New("the transaction funding the sending accounts failed")
+	}
+	log.Info().Int("count", len(addresses)).Float64("ethAmount", *ltp.SendingAccountsFundingAmount).Msg("Funded sending accounts")
+	return nil
+}
+
+// mintERC20ForSendingAccounts mints tokens for every sending account other than the funding
+// account, which received the initial supply when the contract was deployed.
+func mintERC20ForSendingAccounts(ctx context.Context, c *ethclient.Client, erc20Contract *tokens.ERC20) error {
+	ltp := inputLoadTestParams
+	chainID := new(big.Int).SetUint64(*ltp.ChainID)
+	amount := new(big.Int).Mul(big.NewInt(1000000), big.NewInt(1e18))
+
+	txs := make([]*ethtypes.Transaction, 0, len(sendingAccounts))
+	for _, a := range sendingAccounts {
+		if a.Address == *ltp.FromETHAddress {
+			continue
+		}
+		tops, err := bind.NewKeyedTransactorWithChainID(a.PrivateKey, chainID)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable create transaction signer")
+			return err
+		}
+		tops.Context = ctx
+		tx, err := erc20Contract.Mint(tops, amount)
+		if err != nil {
+			log.Error().Err(err).Stringer("address", a.Address).Msg("Unable to mint ERC20 tokens")
+			return err
+		}
+		txs = append(txs, tx)
+	}
+	for _, tx := range txs {
+		if _, err := bind.WaitMined(ctx, c, tx); err != nil {
+			return err
+		}
+	}
+	log.Debug().Int("count", len(txs)).Msg("Minted ERC20 tokens for the sending accounts")
+	return nil
+}
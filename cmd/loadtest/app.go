@@ -4,8 +4,11 @@ import (
 	"crypto/ecdsa"
 	_ "embed"
 	"fmt"
+	"math"
 	"math/big"
 	"math/rand"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -22,22 +25,29 @@ type (
 	blockSummary struct {
 		Block     *rpctypes.RawBlockResponse
 		Receipts  map[ethcommon.Hash]rpctypes.RawTxReceipt
-		Latencies map[uint64]time.Duration
+		Latencies map[ethcommon.Hash]time.Duration
 	}
 	hexwordReader struct {
 	}
 	loadTestSample struct {
-		GoRoutineID int64
-		RequestID   int64
-		RequestTime time.Time
-		WaitTime    time.Duration // Wait time for transaction to be broadcasted
-		Receipt     string
-		IsError     bool
-		Nonce       uint64
+		GoRoutineID   int64
+		RequestID     int64
+		IntendedTime  time.Time // Scheduled send time in open loop mode
+		RequestTime   time.Time
+		WaitTime      time.Duration // Wait time for transaction to be broadcasted
+		Receipt       string
+		IsError       bool
+		ErrorCategory errorCategory `json:",omitempty"` // Kind of the error of a failed request
+		Nonce         uint64
+		TxHash        ethcommon.Hash
+		Phase         string
+		Mode          loadTestMode
 	}
 	loadTestParams struct {
 		// inputs
 		RPCUrl                        *string
+		RPCURLs                       *[]string
+		RPCStrategy                   *string
 		Requests                      *int64
 		Concurrency                   *int64
 		BatchSize                     *uint64
@@ -55,6 +65,11 @@ type (
 		AdaptiveRateLimitIncrement    *uint64
 		AdaptiveCycleDuration         *uint64
 		AdaptiveBackoffFactor         *float64
+		AdaptiveTarget                *string
+		AdaptiveTargetValue           *float64
+		AdaptiveKp                    *float64
+		AdaptiveKi                    *float64
+		AdaptiveKd                    *float64
 		Modes                         *[]string
 		Function                      *uint64
 		Iterations                    *uint64
@@ -73,15 +88,64 @@ type (
 		LegacyTransactionMode         *bool
 		SendOnly                      *bool
 		RecallLength                  *uint64
+		Coordinator                   *string
+		CoordinatorToken              *string
+		CoordinatorResultsTimeout     *uint64
+		Workers                       *uint64
+		Worker                        *string
+		RecallFiles                   *[]string
+		RecallFileFormat              *string
+		RecallFrom                    *[]string
+		RecallTo                      *[]string
+		RecallSelectors               *[]string
 		ContractAddress               *string
 		ContractCallData              *string
 		ContractCallFunctionSignature *string
+		ContractABI                   *string
+		ContractCallSpec              *string
 		ContractCallFunctionArgs      *[]string
 		ContractCallPayable           *bool
 		InscriptionContent            *string
 		BlobFeeCap                    *uint64
+		BlobCounts                    *[]string
+		SetCodeAuthorizations         *uint64
+		AccessList                    *string
+		AccessListSource              *string
+		AccessListExtraEntries        *uint64
+		MempoolCases                  *[]string
+		MempoolNonceGap               *uint64
+		MempoolReplacementBump        *float64
+		MempoolUnderpricedPercent     *float64
+		MempoolSlots                  *uint64
+		EntryPointAddress             *string
+		AccountFactoryAddress         *string
+		BundlerURL                    *string
+		UserOpDeposit                 *float64
+		StateGrowthSlots              *uint64
+		StateGrowthAccounts           *uint64
+		StateGrowthContracts          *uint64
+		StateGrowthBytesPerBlock      *uint64
+		StateGrowthProofSamples       *uint64
+		BridgeAddress                 *string
+		BridgeDestinationNetworks     *[]uint
+		BridgeDepositTypes            *[]string
+		BridgeForceUpdate             *bool
+		DeploySizes                   *[]uint
+		DeployCodeSizeLimit           *uint64
+		DeployInitcodeSizeLimit       *uint64
+		DeploySelfDestruct            *bool
 		StartNonce                    *uint64
 		GasPriceMultiplier            *float64
+		SendingAccounts               *uint64
+		SendingAccountsFile           *string
+		SendingAccountsFundingAmount  *float64
+		Scenario                      *string
+		PrometheusPort                *uint
+		ArrivalMode                   *string
+		ArrivalDistribution           *string
+		RecordFile                    *string
+		ResultsDir                    *string
+		DrainTimeout                  *uint64
 
 		// Computed
 		CurrentGasPrice       *big.Int
@@ -109,9 +173,6 @@ var (
 	loadTestResutsMutex sync.RWMutex
 	startBlockNumber    uint64
 	finalBlockNumber    uint64
-	startNonce          uint64
-	currentNonce        uint64
-	currentNonceMutex   sync.RWMutex
 	rl                  *rate.Limiter
 
 	hexwords = []byte{
@@ -172,6 +233,8 @@ var LoadtestCmd = &cobra.Command{
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		inputLoadTestParams.RPCUrl = flag_loader.GetRpcUrlFlagValue(cmd)
 		inputLoadTestParams.PrivateKey = flag_loader.GetPrivateKeyFlagValue(cmd)
+		loadTestFlags = cmd.Flags()
+		loadTestLocalFlags = cmd.LocalFlags()
 	},
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		zerolog.DurationFieldUnit = time.Second
@@ -198,6 +261,183 @@ func checkLoadtestFlags() error {
 	if ltp.AdaptiveBackoffFactor != nil && *ltp.AdaptiveBackoffFactor <= 0.0 {
 		return fmt.Errorf("the backoff factor needs to be non-zero positive. Given: %f", *ltp.AdaptiveBackoffFactor)
 	}
+	if ltp.AdaptiveTarget != nil && !slices.Contains(adaptiveTargets, *ltp.AdaptiveTarget) {
+		return fmt.Errorf("unsupported adaptive target: %s", *ltp.AdaptiveTarget)
+	}
+	if ltp.AdaptiveTargetValue != nil && *ltp.AdaptiveTargetValue < 0 {
+		return fmt.Errorf("the adaptive target value can't be negative. Given: %f", *ltp.AdaptiveTargetValue)
+	}
+	if ltp.AdaptiveTarget != nil && *ltp.AdaptiveTarget == adaptiveTargetGasUtilization && *ltp.AdaptiveTargetValue > 1 {
+		return fmt.Errorf("the gas utilization target needs to be a fraction of the gas limit. Given: %f", *ltp.AdaptiveTargetValue)
+	}
+	if ltp.AdaptiveKp != nil && (*ltp.AdaptiveKp < 0 || *ltp.AdaptiveKi < 0 || *ltp.AdaptiveKd < 0) {
+		return fmt.Errorf("the gains of the rate controller can't be negative")
+	}
+
+	if *ltp.SendingAccounts == 0 {
+		return fmt.Errorf("the number of sending accounts needs to be at least one")
+	}
+	if *ltp.SendingAccounts > 1 || *ltp.SendingAccountsFile != "" {
+		if *ltp.StartNonce > 0 {
+			return fmt.Errorf("the starting nonce can't be set when using multiple sending accounts")
+		}
+		if *ltp.CallOnly {
+			return fmt.Errorf("call only mode doesn't send transactions and can't be used with multiple sending accounts")
+		}
+		if *ltp.SendingAccountsFundingAmount < 0 {
+			return fmt.Errorf("the sending accounts funding amount can't be negative. Given: %f", *ltp.SendingAccountsFundingAmount)
+		}
+	}
+
+	if *ltp.Scenario != "" {
+		scenario, err := loadScenario(*ltp.Scenario)
+		if err != nil {
+			return err
+		}
+		loadTestScenarioPlan = scenario
+	}
+
+	if *ltp.SetCodeAuthorizations == 0 {
+		return fmt.Errorf("the number of set code authorizations needs to be at least one")
+	}
+	if *ltp.CallOnly {
+		modes := make([]loadTestMode, 0, len(*ltp.Modes))
+		for _, m := range *ltp.Modes {
+			if mode, err := characterToLoadTestMode(m); err == nil {
+				modes = append(modes, mode)
+			}
+		}
+		if loadTestScenarioPlan != nil {
+			modes = loadTestScenarioPlan.getModes()
+		}
+		if hasMode(loadTestModeSetCode, modes) {
+			return fmt.Errorf("set-code mode sends transactions and can't be used with call only mode")
+		}
+	}
+
+	switch *ltp.AccessList {
+	case accessListAccurate, accessListOverDeclared, accessListEmpty:
+	default:
+		return fmt.Errorf("unsupported access list kind: %s", *ltp.AccessList)
+	}
+	if *ltp.AccessListSource != accessListSourceRPC && *ltp.AccessListSource != accessListSourceSynthetic {
+		return fmt.Errorf("unsupported access list source: %s", *ltp.AccessListSource)
+	}
+
+	if len(*ltp.MempoolCases) == 0 {
+		return fmt.Errorf("at least one mempool case is required")
+	}
+	for _, mempoolCase := range *ltp.MempoolCases {
+		if !slices.Contains(mempoolCases, mempoolCase) {
+			return fmt.Errorf("unsupported mempool case: %s", mempoolCase)
+		}
+	}
+	if *ltp.MempoolNonceGap == 0 || *ltp.MempoolSlots == 0 {
+		return fmt.Errorf("the mempool nonce gap and slots need to be at least one")
+	}
+	if *ltp.MempoolReplacementBump < 0 {
+		return fmt.Errorf("the mempool replacement bump can't be negative. Given: %f", *ltp.MempoolReplacementBump)
+	}
+	if *ltp.MempoolUnderpricedPercent <= 0 || *ltp.MempoolUnderpricedPercent >= 100 {
+		return fmt.Errorf("the mempool underpriced percent needs to be between 0 and 100. Given: %f", *ltp.MempoolUnderpricedPercent)
+	}
+
+	if *ltp.RecallFileFormat != recallFileFormatJSON && *ltp.RecallFileFormat != recallFileFormatProto {
+		return fmt.Errorf("unsupported recall file format: %s", *ltp.RecallFileFormat)
+	}
+	for _, a := range append(append([]string{}, *ltp.RecallFrom...), *ltp.RecallTo...) {
+		if !ethcommon.IsHexAddress(a) {
+			return fmt.Errorf("invalid recall filter address: %s", a)
+		}
+	}
+
+	if !ethcommon.IsHexAddress(*ltp.EntryPointAddress) || !ethcommon.IsHexAddress(*ltp.AccountFactoryAddress) {
+		return fmt.Errorf("the EntryPoint and account factory addresses need to be hex addresses")
+	}
+	if *ltp.UserOpDeposit < 0 {
+		return fmt.Errorf("the user operation deposit can't be negative. Given: %f", *ltp.UserOpDeposit)
+	}
+
+	if *ltp.StateGrowthSlots == 0 && *ltp.StateGrowthAccounts == 0 && *ltp.StateGrowthContracts == 0 {
+		return fmt.Errorf("state growth transactions need to write at least one slot, account or contract")
+	}
+
+	if len(*ltp.BridgeDepositTypes) == 0 {
+		return fmt.Errorf("at least one bridge deposit type is required")
+	}
+	for _, depositType := range *ltp.BridgeDepositTypes {
+		if !slices.Contains(bridgeDepositTypes, depositType) {
+			return fmt.Errorf("unsupported bridge deposit type: %s", depositType)
+		}
+	}
+	for _, network := range *ltp.BridgeDestinationNetworks {
+		if network > math.MaxUint32 {
+			return fmt.Errorf("invalid bridge destination network: %d", network)
+		}
+	}
+
+	if len(*ltp.DeploySizes) == 0 {
+		return fmt.Errorf("at least one deployment size is required")
+	}
+	for _, size := range *ltp.DeploySizes {
+		if size == 0 || size > deployMaxSize {
+			return fmt.Errorf("the deployment sizes need to be between 1 and %d. Given: %d", deployMaxSize, size)
+		}
+	}
+
+	if *ltp.Coordinator != "" && *ltp.Worker != "" {
+		return fmt.Errorf("a load test can't be both a coordinator and a worker")
+	}
+	if (*ltp.Coordinator != "" || *ltp.Worker != "") && *ltp.CoordinatorToken == "" {
+		return fmt.Errorf("a coordinator token is required to run a distributed load test")
+	}
+	if *ltp.Coordinator != "" {
+		if *ltp.Workers == 0 {
+			return fmt.Errorf("the number of workers needs to be at least one")
+		}
+		if *ltp.Concurrency < int64(*ltp.Workers) {
+			return fmt.Errorf("the concurrency needs to be at least the number of workers to be split across them")
+		}
+		if *ltp.Scenario != "" {
+			return fmt.Errorf("scenarios can't be split across workers")
+		}
+	}
+	if *ltp.Worker != "" && !strings.HasPrefix(*ltp.Worker, "http") {
+		return fmt.Errorf("the coordinator URL needs to be an HTTP URL")
+	}
+
+	if *ltp.RPCStrategy != rpcStrategyRoundRobin && *ltp.RPCStrategy != rpcStrategyMirror {
+		return fmt.Errorf("unsupported rpc strategy: %s", *ltp.RPCStrategy)
+	}
+	if len(*ltp.RPCURLs) > 0 {
+		for _, rpcURL := range append([]string{*ltp.RPCUrl}, *ltp.RPCURLs...) {
+			if !strings.HasPrefix(rpcURL, "http") {
+				return fmt.Errorf("sending to several endpoints requires HTTP RPC URLs. Given: %s", rpcURL)
+			}
+			if err := util.ValidateUrl(rpcURL); err != nil {
+				return err
+			}
+		}
+	}
+
+	if *ltp.RecordFile != "" && !strings.HasPrefix(*ltp.RPCUrl, "http") {
+		return fmt.Errorf("recording transactions requires an HTTP RPC URL")
+	}
+
+	switch *ltp.ArrivalMode {
+	case arrivalModeClosed:
+	case arrivalModeOpen:
+		for _, phase := range getLoadTestPhases() {
+			if phase.getRateLimit() == rate.Inf {
+				return fmt.Errorf("the open arrival mode requires a positive rate limit")
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported arrival mode: %s", *ltp.ArrivalMode)
+	}
+	if *ltp.ArrivalDistribution != arrivalDistributionFixed && *ltp.ArrivalDistribution != arrivalDistributionPoisson {
+		return fmt.Errorf("unsupported arrival distribution: %s", *ltp.ArrivalDistribution)
+	}
 
 	return nil
 }
@@ -216,6 +456,9 @@ func initFlags() {
 	ltp.Concurrency = LoadtestCmd.PersistentFlags().Int64P("concurrency", "c", 1, "Number of requests to perform concurrently. Default is one request at a time.")
 	ltp.TimeLimit = LoadtestCmd.PersistentFlags().Int64P("time-limit", "t", -1, "Maximum number of seconds to spend for benchmarking. Use this to benchmark within a fixed total amount of time. Per default there is no time limit.")
 	ltp.PrivateKey = LoadtestCmd.PersistentFlags().String("private-key", codeQualityPrivateKey, "The hex encoded private key that we'll use to send transactions")
+	ltp.SendingAccounts = LoadtestCmd.PersistentFlags().Uint64("sending-accounts", 1, "The number of accounts used to send transactions. When greater than one, the accounts are derived from the private key (or taken from --sending-accounts-file) and funded by the private key account. Go routines are spread across the accounts")
+	ltp.SendingAccountsFile = LoadtestCmd.PersistentFlags().String("sending-accounts-file", "", "The path to a wallets file written by polycli fund to load the sending accounts from")
+	ltp.SendingAccountsFundingAmount = LoadtestCmd.PersistentFlags().Float64("sending-accounts-funding-amount", 1, "The amount of ether each sending account should hold before the load test starts. Accounts with a lower balance are funded by the private key account. Use zero to skip funding")
 	ltp.ChainID = LoadtestCmd.PersistentFlags().Uint64("chain-id", 0, "The chain id for the transactions.")
 	ltp.ToAddress = LoadtestCmd.PersistentFlags().String("to-address", "0xDEADBEEFDEADBEEFDEADBEEFDEADBEEFDEADBEEF", "The address that we're going to send to")
 	ltp.ToRandom = LoadtestCmd.PersistentFlags().Bool("to-random", false, "When doing a transfer test, should we send to random addresses rather than DEADBEEFx5")
@@ -228,6 +471,11 @@ func initFlags() {
 	ltp.AdaptiveRateLimitIncrement = LoadtestCmd.PersistentFlags().Uint64("adaptive-rate-limit-increment", 50, "When using adaptive rate limiting, this flag controls the size of the additive increases.")
 	ltp.AdaptiveCycleDuration = LoadtestCmd.PersistentFlags().Uint64("adaptive-cycle-duration-seconds", 10, "When using adaptive rate limiting, this flag controls how often we check the queue size and adjust the rates")
 	ltp.AdaptiveBackoffFactor = LoadtestCmd.PersistentFlags().Float64("adaptive-backoff-factor", 2, "When using adaptive rate limiting, this flag controls our multiplicative decrease value.")
+	ltp.AdaptiveTarget = LoadtestCmd.PersistentFlags().String("adaptive-target", adaptiveTargetTxPool, "When using adaptive rate limiting, this flag controls what the rate follows (txpool | rpc-latency | inclusion-latency | gas-utilization). The txpool target uses AIMD on the txpool size, and the other targets use a PID controller")
+	ltp.AdaptiveTargetValue = LoadtestCmd.PersistentFlags().Float64("adaptive-target-value", 0, "The setpoint of the PID controller: the p95 latency in seconds for the latency targets, or the fraction of the block gas limit for gas-utilization. Zero uses 0.5s of rpc latency, 10s of inclusion latency or 0.8 of gas utilization")
+	ltp.AdaptiveKp = LoadtestCmd.PersistentFlags().Float64("adaptive-kp", 0.5, "The proportional gain of the PID controller of the adaptive rate limit")
+	ltp.AdaptiveKi = LoadtestCmd.PersistentFlags().Float64("adaptive-ki", 0.1, "The integral gain of the PID controller of the adaptive rate limit")
+	ltp.AdaptiveKd = LoadtestCmd.PersistentFlags().Float64("adaptive-kd", 0.1, "The derivative gain of the PID controller of the adaptive rate limit")
 	ltp.GasPriceMultiplier = LoadtestCmd.PersistentFlags().Float64("gas-price-multiplier", 1, "A multiplier to increase or decrease the gas price")
 	ltp.Iterations = LoadtestCmd.PersistentFlags().Uint64P("iterations", "i", 1, "If we're making contract calls, this controls how many times the contract will execute the instruction in a loop. If we are making ERC721 Mints, this indicates the minting batch size")
 	ltp.Seed = LoadtestCmd.PersistentFlags().Int64("seed", 123456, "A seed for generating random values and addresses")
@@ -240,26 +488,40 @@ func initFlags() {
 	ltp.SummaryOutputMode = LoadtestCmd.PersistentFlags().String("output-mode", "text", "Format mode for summary output (json | text)")
 	ltp.LegacyTransactionMode = LoadtestCmd.PersistentFlags().Bool("legacy", false, "Send a legacy transaction instead of an EIP1559 transaction.")
 	ltp.SendOnly = LoadtestCmd.PersistentFlags().Bool("send-only", false, "Send transactions and load without waiting for it to be mined.")
+	ltp.ArrivalMode = LoadtestCmd.PersistentFlags().String("arrival-mode", arrivalModeClosed, `How requests are scheduled (closed | open). In closed mode, every go routine waits for its request to complete before sending the next one. In open mode, requests are scheduled at --rate-limit regardless of how quickly they complete, and --concurrency limits the number of requests in flight`)
+	ltp.ArrivalDistribution = LoadtestCmd.PersistentFlags().String("arrival-distribution", arrivalDistributionFixed, "The distribution of the request arrivals in open arrival mode (fixed | poisson)")
+	ltp.RecordFile = LoadtestCmd.PersistentFlags().String("record-file", "", "The path to a file where every raw signed transaction is recorded, with its nonce, mode and intended send offset, so that the load can be sent again with 'loadtest replay'. This requires an HTTP RPC URL")
+	ltp.ResultsDir = LoadtestCmd.PersistentFlags().String("results-dir", "", "If set, the summary of the run is saved to this directory with the client version, chain id and flags, so that runs can be compared with 'loadtest compare'")
+	ltp.PrometheusPort = LoadtestCmd.PersistentFlags().Uint("prometheus-port", 0, "If set, the load test metrics are exposed on this port at the /metrics endpoint so that Prometheus can scrape them during the run")
+	ltp.DrainTimeout = LoadtestCmd.PersistentFlags().Uint64("drain-timeout", 30, "The number of seconds to wait for the transactions that were already sent when the load test is interrupted or reaches its time limit. Their final state is reported as mined, failed, dropped or still pending. 0 skips the drain")
 	ltp.BlobFeeCap = LoadtestCmd.Flags().Uint64("blob-fee-cap", 100000, "The blob fee cap, or the maximum blob fee per chunk, in Gwei.")
+	ltp.BlobCounts = LoadtestCmd.Flags().StringSlice("blob-counts", []string{"1"}, "The number of blobs per transaction in blob mode, picked at random. Every value is a count with an optional weight, e.g. 1:6,2:3,6:1")
 
 	// Local flags.
 	ltp.Modes = LoadtestCmd.Flags().StringSliceP("mode", "m", []string{"t"}, `The testing mode to use. It can be multiple like: "c,d,f,t"
 2, erc20 - Send ERC20 tokens
 7, erc721 - Mint ERC721 tokens
+al, access-list - Increment a counter or store bytes with transactions carrying an access list
 b, blob - Send blob transactions
+br, bridge - Send ulxly bridge deposits of ether, ERC20 tokens and messages
 c, call - Call random contract functions
 cc, contract-call - Make contract calls
 d, deploy - Deploy contracts
+ds, deploy-stress - Deploy contracts of random sizes with CREATE2 through a factory, up to and over the code size and initcode limits
 f, function - Call random contract functions
 i, inscription - Send inscription transactions
 inc, increment - Increment a counter
+mp, mempool - Exercise txpool edge cases such as nonce gaps, replacements, underpriced transactions and account slot limits
 pr, random-precompile - Call random precompiled contracts
 px, specific-precompile - Call specific precompiled contracts
-r, random - Random modes (does not include the following modes: blob, call, inscription, recall, rpc, uniswapv3)
+r, random - Random modes (does not include the following modes: access-list, blob, bridge, call, deploy-stress, inscription, mempool, recall, rpc, set-code, state-growth, uniswapv3, userop)
 R, recall - Replay or simulate transactions
 rpc - Call random rpc methods
+sc, set-code - Send EIP-7702 transactions renewing the delegation of accounts to the load test contract and calling it through them
+sg, state-growth - Write fresh storage slots, create new accounts and deploy new contracts to grow the state
 s, store - Store bytes in a dynamic byte array
 t, transaction - Send transactions
+uo, userop - Send ERC-4337 user operations from smart accounts owned by the sending accounts. Requires a pre-deployed EntryPoint and SimpleAccountFactory
 v3, uniswapv3 - Perform UniswapV3 swaps`)
 	ltp.Function = LoadtestCmd.Flags().Uint64P("function", "f", 1, "A specific function to be called if running with --mode f or a specific precompiled contract when running with --mode a")
 	ltp.ByteCount = LoadtestCmd.Flags().Uint64P("byte-count", "b", 1024, "If we're in store mode, this controls how many bytes we'll try to store in our contract")
@@ -268,11 +530,52 @@ v3, uniswapv3 - Perform UniswapV3 swaps`)
 	ltp.ERC721Address = LoadtestCmd.Flags().String("erc721-address", "", "The address of a pre-deployed ERC721 contract")
 	ltp.ForceContractDeploy = LoadtestCmd.Flags().Bool("force-contract-deploy", false, "Some load test modes don't require a contract deployment. Set this flag to true to force contract deployments. This will still respect the --lt-address flags.")
 	ltp.RecallLength = LoadtestCmd.Flags().Uint64("recall-blocks", 50, "The number of blocks that we'll attempt to fetch for recall")
+	ltp.RecallFiles = LoadtestCmd.Flags().StringSlice("recall-files", []string{}, "Files written by dumpblocks to read the recalled transactions from, instead of fetching the latest blocks")
+	ltp.RecallFileFormat = LoadtestCmd.Flags().String("recall-file-format", recallFileFormatJSON, "The format of the recall files, as given to dumpblocks with --mode (json | proto)")
+	ltp.RecallFrom = LoadtestCmd.Flags().StringSlice("recall-from", []string{}, "Only recall the transactions sent by these addresses")
+	ltp.RecallTo = LoadtestCmd.Flags().StringSlice("recall-to", []string{}, "Only recall the transactions sent to these addresses")
+	ltp.RecallSelectors = LoadtestCmd.Flags().StringSlice("recall-selector", []string{}, "Only recall the transactions calling these 4 byte function selectors, e.g. 0xa9059cbb")
 	ltp.ContractAddress = LoadtestCmd.Flags().String("contract-address", "", "The address of the contract that will be used in --mode contract-call. This must be paired up with --mode contract-call and --calldata")
 	ltp.ContractCallData = LoadtestCmd.Flags().String("calldata", "", "The hex encoded calldata passed in. The format is function signature + arguments encoded together. This must be paired up with --mode contract-call and --contract-address")
 	ltp.ContractCallFunctionSignature = LoadtestCmd.Flags().String("function-signature", "", "The contract's function signature that will be called. The format is '<function name>(<types...>)'. This must be paired up with '--mode contract-call' and '--contract-address'. If the function requires parameters you can pass them with '--function-arg <value>'.")
 	ltp.ContractCallFunctionArgs = LoadtestCmd.Flags().StringSlice("function-arg", []string{}, `The arguments that will be passed to a contract function call. This must be paired up with "--mode contract-call" and "--contract-address". Args can be passed multiple times: "--function-arg 'test' --function-arg 999" or comma separated values "--function-arg "test",9". The ordering of the arguments must match the ordering of the function parameters.`)
 	ltp.ContractCallPayable = LoadtestCmd.Flags().Bool("contract-call-payable", false, "Use this flag if the function is payable, the value amount passed will be from --eth-amount. This must be paired up with --mode contract-call and --contract-address")
+	ltp.ContractABI = LoadtestCmd.Flags().String("contract-abi", "", "The path to the ABI of the contract, or to a Foundry or Hardhat artifact holding it. The functions of the contract are called with random arguments in --mode contract-call. This must be paired up with --contract-address")
+	ltp.ContractCallSpec = LoadtestCmd.Flags().String("contract-call-spec", "", "The path to a YAML file giving the weights of the functions called with --contract-abi and constraints on their arguments")
+	ltp.Scenario = LoadtestCmd.Flags().String("scenario", "", "The path to a YAML scenario file describing the phases of the load test. Each phase has its own duration or number of requests, concurrency, rate limit and weighted mix of modes. Phase settings that aren't provided fall back to the corresponding flags")
+	ltp.SetCodeAuthorizations = LoadtestCmd.Flags().Uint64("set-code-authorizations", 1, "The number of authorizations in the authorization list of every transaction. This must be paired up with --mode set-code")
+	ltp.AccessList = LoadtestCmd.Flags().String("access-list", accessListAccurate, "The kind of access list sent in access-list mode (accurate | over-declared | empty). Over-declared lists have extra storage keys and addresses that aren't accessed")
+	ltp.AccessListSource = LoadtestCmd.Flags().String("access-list-source", accessListSourceRPC, "How the access lists are built in access-list mode (rpc | synthetic). The rpc source uses eth_createAccessList and the synthetic source uses the storage layout of the load test contract")
+	ltp.AccessListExtraEntries = LoadtestCmd.Flags().Uint64("access-list-extra-entries", 10, "The number of storage keys and of addresses added to over-declared access lists")
+	ltp.MempoolCases = LoadtestCmd.Flags().StringSlice("mempool-cases", mempoolCases, "The txpool cases picked at random in mempool mode (gap | replace | underpriced | slots)")
+	ltp.MempoolNonceGap = LoadtestCmd.Flags().Uint64("mempool-nonce-gap", 5, "The number of nonces skipped by the future transactions of the gap case in mempool mode")
+	ltp.MempoolReplacementBump = LoadtestCmd.Flags().Float64("mempool-replacement-bump", 10, "The fee increase, in percent, of the replacement transactions in mempool mode")
+	ltp.MempoolUnderpricedPercent = LoadtestCmd.Flags().Float64("mempool-underpriced-percent", 50, "The fee of the underpriced transactions in mempool mode, in percent of the suggested fee")
+	ltp.MempoolSlots = LoadtestCmd.Flags().Uint64("mempool-slots", 64, "The number of future transactions sent at once from an account by the slots case in mempool mode. It should match the number of future transactions that the txpool queues per account, e.g. txpool.accountqueue in geth")
+	ltp.EntryPointAddress = LoadtestCmd.Flags().String("entrypoint-address", defaultEntryPointAddress, "The address of a pre-deployed v0.7 ERC-4337 EntryPoint contract used in userop mode")
+	ltp.AccountFactoryAddress = LoadtestCmd.Flags().String("account-factory-address", defaultAccountFactoryAddress, "The address of a pre-deployed SimpleAccountFactory contract used to create the smart accounts in userop mode")
+	ltp.BundlerURL = LoadtestCmd.Flags().String("bundler-url", "", "The URL of the bundler receiving the user operations with eth_sendUserOperation in userop mode. Without a bundler, the sending accounts bundle the user operations themselves with handleOps")
+	ltp.UserOpDeposit = LoadtestCmd.Flags().Float64("userop-deposit", 0.1, "The minimum amount of ether deposited in the EntryPoint for every smart account in userop mode")
+	ltp.StateGrowthSlots = LoadtestCmd.Flags().Uint64("state-growth-slots", 10, "The number of fresh storage slots written by every transaction in state-growth mode")
+	ltp.StateGrowthAccounts = LoadtestCmd.Flags().Uint64("state-growth-accounts", 1, "The number of new accounts created by every transaction in state-growth mode. Every new account receives 1 wei")
+	ltp.StateGrowthContracts = LoadtestCmd.Flags().Uint64("state-growth-contracts", 1, "The number of new contracts deployed by every transaction in state-growth mode")
+	ltp.StateGrowthBytesPerBlock = LoadtestCmd.Flags().Uint64("state-growth-bytes-per-block", 0, "The estimated amount of new state, in bytes, added per block in state-growth mode. Once it's reached, the transactions wait for the next block. 0 means no limit")
+	ltp.StateGrowthProofSamples = LoadtestCmd.Flags().Uint64("state-growth-proof-samples", 0, "The number of new accounts and storage slots whose eth_getProof depth is sampled at the end of a state-growth run")
+	ltp.BridgeAddress = LoadtestCmd.Flags().String("bridge-address", "", "The address of the ulxly bridge contract that the deposits are sent to in bridge mode")
+	ltp.BridgeDestinationNetworks = LoadtestCmd.Flags().UintSlice("bridge-destination-networks", []uint{}, "The network IDs that the deposits are sent to in bridge mode, picked at random")
+	ltp.BridgeDepositTypes = LoadtestCmd.Flags().StringSlice("bridge-deposit-types", []string{bridgeDepositETH}, "The kinds of deposits picked at random in bridge mode (eth | erc20 | message). The ERC20 deposits bridge the ERC20 token of the load test, and the messages carry --eth-amount along with random metadata")
+	ltp.BridgeForceUpdate = LoadtestCmd.Flags().Bool("bridge-force-update", true, "Update the global exit root with every deposit in bridge mode")
+	ltp.DeploySizes = LoadtestCmd.Flags().UintSlice("deploy-sizes", []uint{1024, 24576, 24577}, "The runtime code sizes, in bytes, of the contracts deployed in deploy-stress mode, picked at random")
+	ltp.DeployCodeSizeLimit = LoadtestCmd.Flags().Uint64("deploy-code-size-limit", 24576, "The largest runtime code size, in bytes, that the chain is expected to accept in deploy-stress mode. Larger contracts are expected to be rejected")
+	ltp.DeployInitcodeSizeLimit = LoadtestCmd.Flags().Uint64("deploy-initcode-size-limit", 49152, "The largest initcode size, in bytes, that the chain is expected to accept in deploy-stress mode. Larger initcodes are expected to be rejected")
+	ltp.DeploySelfDestruct = LoadtestCmd.Flags().Bool("deploy-selfdestruct", false, "Self-destruct the contracts in their constructor in deploy-stress mode, and redeploy them at the same address with the same salt")
+	ltp.RPCURLs = LoadtestCmd.Flags().StringSlice("rpc-urls", []string{}, "Extra RPC endpoints that the transactions are sent to along with --rpc-url. The other requests only go to --rpc-url")
+	ltp.RPCStrategy = LoadtestCmd.Flags().String("rpc-strategy", rpcStrategyRoundRobin, "How the transactions are sent across --rpc-url and --rpc-urls (round-robin | mirror). Mirrored transactions are sent to every endpoint, and the blocks of every endpoint are watched to find which one reports a transaction first")
+	ltp.Coordinator = LoadtestCmd.Flags().String("coordinator", "", "Run as the coordinator of a distributed load test, listening for the workers on this address (e.g. :7000). The coordinator funds the sending accounts of the workers, splits the concurrency and the rate limit across them, and summarizes their results")
+	ltp.CoordinatorToken = LoadtestCmd.Flags().String("coordinator-token", "", "The shared secret that the workers of a distributed load test send to the coordinator. Required with --coordinator and --worker")
+	ltp.CoordinatorResultsTimeout = LoadtestCmd.Flags().Uint64("coordinator-results-timeout", 600, "The time in seconds that the coordinator waits for the results of the workers after --time-limit, or after the start of the load test without a time limit. The results received by then are summarized")
+	ltp.Workers = LoadtestCmd.Flags().Uint64("workers", 1, "The number of workers that the coordinator waits for before starting the load test")
+	ltp.Worker = LoadtestCmd.Flags().String("worker", "", "Run as a worker of a distributed load test, taking the flags from the coordinator at this URL (e.g. http://10.0.0.1:7000). The worker generates --sending-accounts accounts, or reads them from --sending-accounts-file, and the coordinator funds them")
 	ltp.InscriptionContent = LoadtestCmd.Flags().String("inscription-content", `data:,{"p":"erc-20","op":"mint","tick":"TEST","amt":"1"}`, "The inscription content that will be encoded as calldata. This must be paired up with --mode inscription")
 
 	inputLoadTestParams = *ltp
@@ -282,4 +585,6 @@ v3, uniswapv3 - Perform UniswapV3 swaps`)
 
 func initSubCommands() {
 	LoadtestCmd.AddCommand(uniswapV3LoadTestCmd)
+	LoadtestCmd.AddCommand(replayCmd)
+	LoadtestCmd.AddCommand(compareCmd)
 }
@@ -0,0 +1,123 @@
+package loadtest
+
+import (
+	"context"
+	"time"
+
+	"github.com/montanaflynn/stats"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	arrivalModeClosed = "closed"
+	arrivalModeOpen   = "open"
+
+	arrivalDistributionFixed   = "fixed"
+	arrivalDistributionPoisson = "poisson"
+)
+
+// isOpenLoop returns true if the requests are scheduled at the arrival rate rather than sent
+// by go routines waiting on each other's responses.
+func isOpenLoop() bool {
+	return *inputLoadTestParams.ArrivalMode == arrivalModeOpen
+}
+
+// scheduleArrivals produces the intended send times of the requests of an open loop load
+// test. The schedule only depends on the current rate limit and on the arrival distribution,
+// never on how quickly the requests complete. When the go routines fall behind, the intended
+// send times stay on schedule so that the waiting time is accounted for in the latencies. A
+// total of zero schedules requests until the context is done.
+func scheduleArrivals(ctx context.Context, total int64, bufferSize int64) <-chan time.Time {
+	arrivals := make(chan time.Time, bufferSize)
+	go func() {
+		defer close(arrivals)
+		next := time.Now()
+		for n := int64(0); total == 0 || n < total; n = n + 1 {
+			if d := time.Until(next); d > 0 {
+				timer := time.NewTimer(d)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case arrivals <- next:
+			}
+			next = next.Add(getInterArrivalTime())
+		}
+	}()
+	return arrivals
+}
+
+// getInterArrivalTime returns the time until the next request is scheduled. Poisson arrivals
+// have exponentially distributed inter-arrival times with the same mean as fixed arrivals.
+func getInterArrivalTime() time.Duration {
+	interval := float64(time.Second) / float64(rl.Limit())
+	if *inputLoadTestParams.ArrivalDistribution == arrivalDistributionPoisson {
+		interval = interval * randSrc.ExpFloat64()
+	}
+	return time.Duration(interval)
+}
+
+// getStartTime returns the time the request was meant to be sent. In open loop mode, it's the
+// scheduled time, otherwise it's the time the request was actually sent.
+func (s loadTestSample) getStartTime() time.Time {
+	if s.IntendedTime.IsZero() {
+		return s.RequestTime
+	}
+	return s.IntendedTime
+}
+
+// openLoopSummary logs how far behind schedule the requests were sent, and the request
+// latencies corrected for that delay.
+func openLoopSummary(lts []loadTestSample) {
+	sendDelays := make([]float64, 0, len(lts))
+	latencies := make([]float64, 0, len(lts))
+	correctedLatencies := make([]float64, 0, len(lts))
+	for _, s := range lts {
+		if s.IntendedTime.IsZero() {
+			continue
+		}
+		sendDelays = append(sendDelays, s.RequestTime.Sub(s.IntendedTime).Seconds())
+		latencies = append(latencies, s.WaitTime.Seconds())
+		correctedLatencies = append(correctedLatencies, s.RequestTime.Add(s.WaitTime).Sub(s.IntendedTime).Seconds())
+	}
+	if len(sendDelays) == 0 {
+		return
+	}
+
+	meanDelay, _ := stats.Mean(sendDelays)
+	p99Delay, _ := stats.Percentile(sendDelays, 99)
+	maxDelay, _ := stats.Max(sendDelays)
+	log.Info().
+		Str("distribution", *inputLoadTestParams.ArrivalDistribution).
+		Float64("mean", meanDelay).
+		Float64("p99", p99Delay).
+		Float64("max", maxDelay).
+		Msg("Delay between the intended and actual send times")
+
+	for _, l := range []struct {
+		name      string
+		latencies []float64
+	}{
+		{"Uncorrected", latencies},
+		{"Corrected", correctedLatencies},
+	} {
+		mean, _ := stats.Mean(l.latencies)
+		p50, _ := stats.Percentile(l.latencies, 50)
+		p90, _ := stats.Percentile(l.latencies, 90)
+		p99, _ := stats.Percentile(l.latencies, 99)
+		max, _ := stats.Max(l.latencies)
+		log.Info().
+			Float64("mean", mean).
+			Float64("p50", p50).
+			Float64("p90", p90).
+			Float64("p99", p99).
+			Float64("max", max).
+			Msgf("%s Request Latency of Transactions Stats", l.name)
+	}
+}
@@ -1,19 +1,196 @@
 package loadtest
 
 import (
+	"context"
 	cryptorand "crypto/rand"
 	"crypto/sha256"
 	_ "embed"
 	"fmt"
+	"math/big"
 	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/params"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
 
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
+const (
+	// blobFeeHistoryBlocks is the number of blocks requested with eth_feeHistory at once,
+	// which is the most that geth returns.
+	blobFeeHistoryBlocks = 1024
+)
+
+type (
+	// blobLoadTest holds the distribution of the number of blobs per transaction, the number
+	// of transactions sent with every blob count, and the blob usage of the blocks of the run.
+	blobLoadTest struct {
+		counts            []int
+		cumulativeWeights []float64
+
+		sent   map[int]int
+		mutex  sync.Mutex
+		blocks []BlobBlockSummary
+	}
+
+	// BlobBlockSummary holds the blob gas used by a block and its blob base fee in wei.
+	BlobBlockSummary struct {
+		BlockNumber   uint64
+		Blobs         uint64
+		BlobGasUsed   uint64
+		ExcessBlobGas uint64
+		BlobBaseFee   *big.Int
+	}
+
+	// blobFeeHistory is the subset of the eth_feeHistory response about blobs, which the
+	// FeeHistory of ethclient leaves out.
+	blobFeeHistory struct {
+		OldestBlock       hexutil.Uint64 `json:"oldestBlock"`
+		BaseFeePerBlobGas []*hexutil.Big `json:"baseFeePerBlobGas"`
+	}
+)
+
+var blobs *blobLoadTest
+
+// newBlobLoadTest parses the distribution of the number of blobs per transaction. Every value
+// is a blob count with an optional weight, e.g. "2:3", and the weight defaults to 1.
+func newBlobLoadTest(values []string) (*blobLoadTest, error) {
+	b := &blobLoadTest{sent: make(map[int]int)}
+	var total float64
+	for _, value := range values {
+		countValue, weightValue, hasWeight := strings.Cut(strings.TrimSpace(value), ":")
+		count, err := strconv.Atoi(countValue)
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("invalid blob count %q: the count must be a positive integer", value)
+		}
+		weight := 1.0
+		if hasWeight {
+			weight, err = strconv.ParseFloat(weightValue, 64)
+			if err != nil || weight < 0 {
+				return nil, fmt.Errorf("invalid blob count %q: the weight must be a non-negative number", value)
+			}
+		}
+		if weight == 0 {
+			continue
+		}
+		total += weight
+		b.counts = append(b.counts, count)
+		b.cumulativeWeights = append(b.cumulativeWeights, total)
+	}
+	if len(b.counts) == 0 {
+		return nil, fmt.Errorf("at least one blob count needs a positive weight")
+	}
+	return b, nil
+}
+
+// getRandomCount picks the number of blobs of a transaction according to the weights.
+func (b *blobLoadTest) getRandomCount() int {
+	r := randSrc.Float64() * b.cumulativeWeights[len(b.cumulativeWeights)-1]
+	idx := sort.SearchFloat64s(b.cumulativeWeights, r)
+	if idx >= len(b.counts) {
+		idx = len(b.counts) - 1
+	}
+	return b.counts[idx]
+}
+
+func (b *blobLoadTest) recordSent(count int) {
+	b.mutex.Lock()
+	b.sent[count]++
+	b.mutex.Unlock()
+}
+
+// resolve looks up the blob gas used and the blob base fee of the blocks of the run.
+func (b *blobLoadTest) resolve(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) {
+	if finalBlockNumber <= startBlockNumber {
+		return
+	}
+	baseFees := make(map[uint64]*big.Int)
+	for newest := finalBlockNumber; newest > startBlockNumber; {
+		count := min(newest-startBlockNumber, blobFeeHistoryBlocks)
+		var history blobFeeHistory
+		err := rpc.CallContext(ctx, &history, "eth_feeHistory", hexutil.Uint64(count), hexutil.Uint64(newest), []float64{})
+		if err != nil {
+			log.Warn().Err(err).Msg("Unable to get the blob base fees with eth_feeHistory")
+			break
+		}
+		for k, fee := range history.BaseFeePerBlobGas {
+			baseFees[uint64(history.OldestBlock)+uint64(k)] = fee.ToInt()
+		}
+		newest -= count
+	}
+
+	blocks := make([]BlobBlockSummary, 0, finalBlockNumber-startBlockNumber)
+	for n := startBlockNumber + 1; n <= finalBlockNumber; n++ {
+		header, err := c.HeaderByNumber(ctx, new(big.Int).SetUint64(n))
+		if err != nil {
+			log.Error().Err(err).Uint64("blockNumber", n).Msg("Unable to get the block header")
+			return
+		}
+		block := BlobBlockSummary{BlockNumber: n, BlobBaseFee: baseFees[n]}
+		if header.BlobGasUsed != nil {
+			block.BlobGasUsed = *header.BlobGasUsed
+			block.Blobs = block.BlobGasUsed / params.BlobTxBlobGasPerBlob
+		}
+		if header.ExcessBlobGas != nil {
+			block.ExcessBlobGas = *header.ExcessBlobGas
+		}
+		blocks = append(blocks, block)
+	}
+	b.mutex.Lock()
+	b.blocks = blocks
+	b.mutex.Unlock()
+}
+
+func blobLightSummary() {
+	if blobs == nil {
+		return
+	}
+	blobs.mutex.Lock()
+	defer blobs.mutex.Unlock()
+
+	log.Info().Msg("* Blob results")
+	counts := getSortedMapKeys(blobs.sent)
+	var sentBlobs int
+	for _, count := range counts {
+		sentBlobs += count * blobs.sent[count]
+		log.Info().Int("blobs", count).Int("transactions", blobs.sent[count]).Msg("Blob transactions sent")
+	}
+
+	var blobGasUsed, blockBlobs uint64
+	for _, block := range blobs.blocks {
+		event := log.Info().
+			Uint64("blockNumber", block.BlockNumber).
+			Uint64("blobs", block.Blobs).
+			Uint64("blobGasUsed", block.BlobGasUsed).
+			Uint64("excessBlobGas", block.ExcessBlobGas)
+		if block.BlobBaseFee != nil {
+			event = event.Str("blobBaseFee", block.BlobBaseFee.String())
+		}
+		event.Msg("Blob usage of block")
+		blobGasUsed += block.BlobGasUsed
+		blockBlobs += block.Blobs
+	}
+	var blobsPerBlock float64
+	if len(blobs.blocks) > 0 {
+		blobsPerBlock = float64(blockBlobs) / float64(len(blobs.blocks))
+	}
+	log.Info().
+		Int("sentBlobs", sentBlobs).
+		Uint64("blockBlobs", blockBlobs).
+		Uint64("blobGasUsed", blobGasUsed).
+		Float64("blobsPerBlock", blobsPerBlock).
+		Msg("Blob Stats")
+}
+
 type BlobCommitment struct {
 	Blob          kzg4844.Blob
 	Commitment    kzg4844.Commitment
@@ -0,0 +1,83 @@
+package loadtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNewBlobLoadTest tests the parsing of the weighted blob counts of `--blob-counts`.
+func TestNewBlobLoadTest(t *testing.T) {
+	type Test struct {
+		Name              string
+		Values            []string
+		Counts            []int
+		CumulativeWeights []float64
+		ErrMsg            string
+	}
+
+	tests := []Test{
+		{
+			Name:              "single count",
+			Values:            []string{"1"},
+			Counts:            []int{1},
+			CumulativeWeights: []float64{1},
+		},
+		{
+			Name:              "weighted counts",
+			Values:            []string{"1:6", "2:3", "6:1"},
+			Counts:            []int{1, 2, 6},
+			CumulativeWeights: []float64{6, 9, 10},
+		},
+		{
+			Name:              "default and fractional weights",
+			Values:            []string{" 3 ", "4:0.5"},
+			Counts:            []int{3, 4},
+			CumulativeWeights: []float64{1, 1.5},
+		},
+		{
+			Name:              "zero weights are skipped",
+			Values:            []string{"1:0", "2:1"},
+			Counts:            []int{2},
+			CumulativeWeights: []float64{1},
+		},
+		{
+			Name:   "zero count",
+			Values: []string{"0"},
+			ErrMsg: "the count must be a positive integer",
+		},
+		{
+			Name:   "invalid count",
+			Values: []string{"two"},
+			ErrMsg: "the count must be a positive integer",
+		},
+		{
+			Name:   "negative weight",
+			Values: []string{"1:-1"},
+			ErrMsg: "the weight must be a non-negative number",
+		},
+		{
+			Name:   "invalid weight",
+			Values: []string{"1:x"},
+			ErrMsg: "the weight must be a non-negative number",
+		},
+		{
+			Name:   "only zero weights",
+			Values: []string{"1:0"},
+			ErrMsg: "at least one blob count needs a positive weight",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			b, err := newBlobLoadTest(tc.Values)
+			if tc.ErrMsg != "" {
+				assert.ErrorContains(t, err, tc.ErrMsg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Counts, b.counts)
+			assert.Equal(t, tc.CumulativeWeights, b.cumulativeWeights)
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/0xPolygon/polygon-cli/bindings/tokens"
+	"github.com/0xPolygon/polygon-cli/bindings/ulxly"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// bridgeDepositETH bridges ether with bridgeAsset.
+	bridgeDepositETH = "eth"
+	// bridgeDepositERC20 bridges the ERC20 token of the load test with bridgeAsset.
+	bridgeDepositERC20 = "erc20"
+	// bridgeDepositMessage sends a message, along with the ether amount, with bridgeMessage.
+	bridgeDepositMessage = "message"
+
+	bridgeMessageSize = 32
+)
+
+var bridgeDepositTypes = []string{bridgeDepositETH, bridgeDepositERC20, bridgeDepositMessage}
+
+type (
+	// sentBridgeDeposit is a bridge deposit sent during the load test.
+	sentBridgeDeposit struct {
+		Hash               ethcommon.Hash
+		Type               string
+		DestinationNetwork uint32
+	}
+
+	// bridgeLoadTest holds the bridge contract used in bridge mode, the token bridged by the
+	// ERC20 deposits and the deposits that were sent.
+	bridgeLoadTest struct {
+		address ethcommon.Address
+		bridge  *ulxly.Ulxly
+		token   ethcommon.Address
+
+		sent    []sentBridgeDeposit
+		mutex   sync.Mutex
+		summary *BridgeSummary
+	}
+
+	// BridgeSummary holds the number of deposits sent and included by type and by destination
+	// network, and the range of the deposit counts assigned by the bridge, which are the
+	// leaves of its local exit tree that can be claimed on the destination networks.
+	BridgeSummary struct {
+		Sent              int
+		Included          int
+		Failed            int
+		Deposits          int
+		ByType            map[string]int
+		ByNetwork         map[uint32]int
+		FirstDepositCount uint32
+		LastDepositCount  uint32
+	}
+)
+
+var bridgeDeposits *bridgeLoadTest
+
+// initBridgeLoadTest binds the bridge contract. For ERC20 deposits, the ERC20 token of the
+// load test is used, deployed and minted if needed, and every sending account approves the
+// bridge to spend it.
+func initBridgeLoadTest(ctx context.Context, c *ethclient.Client, tops *bind.TransactOpts, cops *bind.CallOpts, erc20Contract *tokens.ERC20, erc20Addr ethcommon.Address) (*bridgeLoadTest, error) {
+	ltp := inputLoadTestParams
+	address := ethcommon.HexToAddress(*ltp.BridgeAddress)
+	code, err := c.CodeAt(ctx, address, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(code) == 0 {
+		return nil, fmt.Errorf("there is no bridge contract at %s", address)
+	}
+	bridge, err := ulxly.NewUlxly(address, c)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to instantiate the bridge contract")
+		return nil, err
+	}
+	b := &bridgeLoadTest{address: address, bridge: bridge}
+
+	if !slices.Contains(*ltp.BridgeDepositTypes, bridgeDepositERC20) {
+		return b, nil
+	}
+	if erc20Contract == nil {
+		erc20Addr, erc20Contract, err = getERC20Contract(ctx, c, tops, cops)
+		if err != nil {
+			return nil, err
+		}
+		if err = mintERC20ForSendingAccounts(ctx, c, erc20Contract); err != nil {
+			return nil, err
+		}
+	}
+	b.token = erc20Addr
+
+	chainID := new(big.Int).SetUint64(*ltp.ChainID)
+	txs := make([]*ethtypes.Transaction, 0, len(sendingAccounts))
+	for _, a := range sendingAccounts {
+		accountTops, err := bind.NewKeyedTransactorWithChainID(a.PrivateKey, chainID)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable create transaction signer")
+			return nil, err
+		}
+		accountTops.Context = ctx
+		tx, err := erc20Contract.Approve(accountTops, address, ethcommon.MaxHash.Big())
+		if err != nil {
+			log.Error().Err(err).Stringer("address", a.Address).Msg("Unable to approve the bridge to spend the ERC20 tokens")
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	for _, tx := range txs {
+		if _, err = bind.WaitMined(ctx, c, tx); err != nil {
+			return nil, err
+		}
+	}
+	log.Debug().Stringer("token", erc20Addr).Int("count", len(txs)).Msg("Approved the bridge to spend the ERC20 tokens")
+	return b, nil
+}
+
+// loadTestBridge sends a bridge deposit of a random type to a random destination network.
+func loadTestBridge(ctx context.Context, c *ethclient.Client, account *loadTestAccount, nonce uint64, b *bridgeLoadTest) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
+	ltp := inputLoadTestParams
+
+	to := ltp.ToETHAddress
+	if *ltp.ToRandom {
+		to = getRandomAddress()
+	}
+	depositTypes := *ltp.BridgeDepositTypes
+	depositType := depositTypes[randSrc.Intn(len(depositTypes))]
+	networks := *ltp.BridgeDestinationNetworks
+	destinationNetwork := uint32(networks[randSrc.Intn(len(networks))])
+	amount := ltp.SendAmount
+	forceUpdate := *ltp.BridgeForceUpdate
+
+	chainID := new(big.Int).SetUint64(*ltp.ChainID)
+	tops, err := bind.NewKeyedTransactorWithChainID(account.PrivateKey, chainID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable create transaction signer")
+		return
+	}
+	tops.Nonce = new(big.Int).SetUint64(nonce)
+	if depositType != bridgeDepositERC20 {
+		tops.Value = amount
+	}
+	tops = configureTransactOpts(ctx, c, tops)
+	tops.NoSend = *ltp.CallOnly
+
+	t1 = time.Now()
+	defer func() { t2 = time.Now() }()
+	var tx *ethtypes.Transaction
+	switch depositType {
+	case bridgeDepositETH:
+		tx, err = b.bridge.BridgeAsset(tops, destinationNetwork, *to, amount, ethcommon.Address{}, forceUpdate, nil)
+	case bridgeDepositERC20:
+		tx, err = b.bridge.BridgeAsset(tops, destinationNetwork, *to, amount, b.token, forceUpdate, nil)
+	case bridgeDepositMessage:
+		metadata := make([]byte, bridgeMessageSize)
+		_, _ = randSrc.Read(metadata)
+		tx, err = b.bridge.BridgeMessage(tops, destinationNetwork, *to, forceUpdate, metadata)
+	}
+	if err != nil {
+		return
+	}
+	if *ltp.CallOnly {
+		_, err = c.CallContract(ctx, txToCallMsg(tx), nil)
+		return
+	}
+	txHash = tx.Hash()
+
+	b.mutex.Lock()
+	b.sent = append(b.sent, sentBridgeDeposit{Hash: txHash, Type: depositType, DestinationNetwork: destinationNetwork})
+	b.mutex.Unlock()
+	return
+}
+
+// resolve looks up the receipts of the deposits and reads the deposit counts of their
+// BridgeEvent logs.
+func (b *bridgeLoadTest) resolve(ctx context.Context, c *ethclient.Client) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	summary := &BridgeSummary{
+		ByType:            make(map[string]int),
+		ByNetwork:         make(map[uint32]int),
+		FirstDepositCount: math.MaxUint32,
+	}
+	for _, deposit := range b.sent {
+		summary.Sent++
+		receipt, err := c.TransactionReceipt(ctx, deposit.Hash)
+		if err != nil {
+			log.Debug().Err(err).Stringer("txHash", deposit.Hash).Msg("Unable to get the receipt of the bridge deposit")
+			continue
+		}
+		if receipt.Status != ethtypes.ReceiptStatusSuccessful {
+			summary.Failed++
+			continue
+		}
+		summary.Included++
+		summary.ByType[deposit.Type]++
+		summary.ByNetwork[deposit.DestinationNetwork]++
+		for _, l := range receipt.Logs {
+			if l.Address != b.address {
+				continue
+			}
+			event, err := b.bridge.ParseBridgeEvent(*l)
+			if err != nil {
+				continue
+			}
+			summary.Deposits++
+			summary.FirstDepositCount = min(summary.FirstDepositCount, event.DepositCount)
+			summary.LastDepositCount = max(summary.LastDepositCount, event.DepositCount)
+		}
+	}
+	if summary.Deposits == 0 {
+		summary.FirstDepositCount = 0
+	}
+	b.summary = summary
+}
+
+func bridgeLightSummary() {
+	if bridgeDeposits == nil || bridgeDeposits.summary == nil {
+		return
+	}
+	s := bridgeDeposits.summary
+	log.Info().
+		Int("sent", s.Sent).
+		Int("included", s.Included).
+		Int("failed", s.Failed).
+		Int("deposits", s.Deposits).
+		Uint32("firstDepositCount", s.FirstDepositCount).
+		Uint32("lastDepositCount", s.LastDepositCount).
+		Msg("Bridge Deposit Stats")
+	for _, depositType := range getSortedMapKeys(s.ByType) {
+		log.Info().Str("type", depositType).Int("included", s.ByType[depositType]).Msg("Bridge deposits by type")
+	}
+	for _, network := range getSortedMapKeys(s.ByNetwork) {
+		log.Info().Uint32("destinationNetwork", network).Int("included", s.ByNetwork[network]).Msg("Bridge deposits by destination network")
+	}
+}
@@ -0,0 +1,169 @@
+package loadtest
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"os"
+	"text/tabwriter"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	//go:embed compareUsage.md
+	compareUsage       string
+	inputCompareParams compareParams
+)
+
+type (
+	compareParams struct {
+		MaxTPSDecrease          *float64
+		MaxGasPerSecondDecrease *float64
+		MaxErrorRateIncrease    *float64
+		MaxLatencyIncrease      *float64
+	}
+
+	// runMetricDelta is the change of a metric between the baseline run and the candidate run.
+	runMetricDelta struct {
+		name           string
+		baseline       float64
+		candidate      float64
+		higherIsBetter bool
+		// relative thresholds are a percentage of the baseline value, the others are in the
+		// unit of the metric. A negative threshold disables the check.
+		relative  bool
+		threshold float64
+	}
+)
+
+var compareCmd = &cobra.Command{
+	Use:          "compare runA runB",
+	Short:        "Compare the results of two load test runs.",
+	Long:         compareUsage,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCompare(args[0], args[1])
+	},
+}
+
+func init() {
+	params := new(compareParams)
+	params.MaxTPSDecrease = compareCmd.Flags().Float64("max-tps-decrease", 5, "The largest allowed decrease of the transactions per second, in percent of the first run. Use a negative value to disable the check")
+	params.MaxGasPerSecondDecrease = compareCmd.Flags().Float64("max-gas-per-second-decrease", 5, "The largest allowed decrease of the gas per second, in percent of the first run. Use a negative value to disable the check")
+	params.MaxErrorRateIncrease = compareCmd.Flags().Float64("max-error-rate-increase", 1, "The largest allowed increase of the error rate, in percentage points. Use a negative value to disable the check")
+	params.MaxLatencyIncrease = compareCmd.Flags().Float64("max-latency-increase", 10, "The largest allowed increase of the latency percentiles, in percent of the first run. Use a negative value to disable the check")
+	inputCompareParams = *params
+}
+
+func runCompare(baselineRun, candidateRun string) error {
+	baseline, err := readRunResult(baselineRun)
+	if err != nil {
+		return err
+	}
+	candidate, err := readRunResult(candidateRun)
+	if err != nil {
+		return err
+	}
+	if baseline.Metadata.ChainID != candidate.Metadata.ChainID {
+		log.Warn().Uint64("baseline", baseline.Metadata.ChainID).Uint64("candidate", candidate.Metadata.ChainID).Msg("The runs were made on different chains")
+	}
+
+	deltas := getRunMetricDeltas(baseline.Summary, candidate.Summary)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Metric\t%s\t%s\tDelta\tStatus\n", baseline.ID, candidate.ID)
+	regressions := 0
+	for _, d := range deltas {
+		status := "ok"
+		if d.isRegression() {
+			status = "REGRESSION"
+			regressions++
+		}
+		fmt.Fprintf(w, "%s\t%.4f\t%.4f\t%s\t%s\n", d.name, d.baseline, d.candidate, d.formatDelta(), status)
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	if regressions > 0 {
+		return fmt.Errorf("%d metrics regressed beyond the allowed thresholds", regressions)
+	}
+	return nil
+}
+
+// getRunMetricDeltas returns the metrics that can be compared between the two runs. The mined
+// transactions per second, the gas per second and the inclusion latencies are skipped unless
+// both runs have them.
+func getRunMetricDeltas(baseline, candidate RunSummary) []runMetricDelta {
+	cp := inputCompareParams
+	deltas := []runMetricDelta{
+		{name: "Transactions per second", baseline: baseline.TransactionsPerSec, candidate: candidate.TransactionsPerSec, higherIsBetter: true, relative: true, threshold: *cp.MaxTPSDecrease},
+	}
+	if baseline.MinedTransactionsPerSec > 0 && candidate.MinedTransactionsPerSec > 0 {
+		deltas = append(deltas, runMetricDelta{name: "Mined transactions per second", baseline: baseline.MinedTransactionsPerSec, candidate: candidate.MinedTransactionsPerSec, higherIsBetter: true, relative: true, threshold: *cp.MaxTPSDecrease})
+	}
+	if baseline.GasPerSecond > 0 && candidate.GasPerSecond > 0 {
+		deltas = append(deltas, runMetricDelta{name: "Gas per second", baseline: baseline.GasPerSecond, candidate: candidate.GasPerSecond, higherIsBetter: true, relative: true, threshold: *cp.MaxGasPerSecondDecrease})
+	}
+	deltas = append(deltas, runMetricDelta{name: "Error rate (%)", baseline: baseline.ErrorRate * 100, candidate: candidate.ErrorRate * 100, threshold: *cp.MaxErrorRateIncrease})
+
+	latencies := []struct {
+		name                string
+		baseline, candidate float64
+	}{
+		{"Request latency p50 (s)", baseline.RequestLatency.P50, candidate.RequestLatency.P50},
+		{"Request latency p90 (s)", baseline.RequestLatency.P90, candidate.RequestLatency.P90},
+		{"Request latency p99 (s)", baseline.RequestLatency.P99, candidate.RequestLatency.P99},
+	}
+	baselineInclusion, baselineHasInclusion := baseline.getOverallInclusionLatency()
+	candidateInclusion, candidateHasInclusion := candidate.getOverallInclusionLatency()
+	if baselineHasInclusion && candidateHasInclusion {
+		latencies = append(latencies, []struct {
+			name                string
+			baseline, candidate float64
+		}{
+			{"Inclusion latency p50 (s)", baselineInclusion.P50, candidateInclusion.P50},
+			{"Inclusion latency p90 (s)", baselineInclusion.P90, candidateInclusion.P90},
+			{"Inclusion latency p99 (s)", baselineInclusion.P99, candidateInclusion.P99},
+		}...)
+	}
+	for _, l := range latencies {
+		deltas = append(deltas, runMetricDelta{name: l.name, baseline: l.baseline, candidate: l.candidate, relative: true, threshold: *cp.MaxLatencyIncrease})
+	}
+	return deltas
+}
+
+// getChange returns how much worse the candidate is than the baseline, in percent of the
+// baseline for relative metrics. A negative change is an improvement.
+func (d runMetricDelta) getChange() float64 {
+	change := d.candidate - d.baseline
+	if d.higherIsBetter {
+		change = -change
+	}
+	if !d.relative {
+		return change
+	}
+	if d.baseline == 0 {
+		if change > 0 {
+			return math.Inf(1)
+		}
+		return 0
+	}
+	return change / math.Abs(d.baseline) * 100
+}
+
+func (d runMetricDelta) isRegression() bool {
+	return d.threshold >= 0 && d.getChange() > d.threshold
+}
+
+func (d runMetricDelta) formatDelta() string {
+	if !d.relative {
+		return fmt.Sprintf("%+.4f", d.candidate-d.baseline)
+	}
+	if d.baseline == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.2f%%", (d.candidate-d.baseline)/math.Abs(d.baseline)*100)
+}
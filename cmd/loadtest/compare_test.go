@@ -0,0 +1,139 @@
+package loadtest
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRunMetricDelta tests the change of a metric between two runs and whether it's a
+// regression.
+func TestRunMetricDelta(t *testing.T) {
+	type Test struct {
+		Name       string
+		Delta      runMetricDelta
+		Change     float64
+		Regression bool
+		Formatted  string
+	}
+
+	tests := []Test{
+		{
+			Name:      "higher is better, decrease within threshold",
+			Delta:     runMetricDelta{baseline: 100, candidate: 96, higherIsBetter: true, relative: true, threshold: 5},
+			Change:    4,
+			Formatted: "-4.00%",
+		},
+		{
+			Name:       "higher is better, decrease beyond threshold",
+			Delta:      runMetricDelta{baseline: 100, candidate: 90, higherIsBetter: true, relative: true, threshold: 5},
+			Change:     10,
+			Regression: true,
+			Formatted:  "-10.00%",
+		},
+		{
+			Name:      "higher is better, increase",
+			Delta:     runMetricDelta{baseline: 100, candidate: 150, higherIsBetter: true, relative: true, threshold: 5},
+			Change:    -50,
+			Formatted: "+50.00%",
+		},
+		{
+			Name:       "lower is better, increase beyond threshold",
+			Delta:      runMetricDelta{baseline: 2, candidate: 3, relative: true, threshold: 10},
+			Change:     50,
+			Regression: true,
+			Formatted:  "+50.00%",
+		},
+		{
+			Name:      "lower is better, decrease",
+			Delta:     runMetricDelta{baseline: 2, candidate: 1, relative: true, threshold: 10},
+			Change:    -50,
+			Formatted: "-50.00%",
+		},
+		{
+			Name:       "absolute increase beyond threshold",
+			Delta:      runMetricDelta{baseline: 1, candidate: 2.5, threshold: 1},
+			Change:     1.5,
+			Regression: true,
+			Formatted:  "+1.5000",
+		},
+		{
+			Name:      "absolute increase within threshold",
+			Delta:     runMetricDelta{baseline: 1, candidate: 1.5, threshold: 1},
+			Change:    0.5,
+			Formatted: "+0.5000",
+		},
+		{
+			Name:       "zero baseline, worse candidate",
+			Delta:      runMetricDelta{baseline: 0, candidate: 1, relative: true, threshold: 10},
+			Change:     math.Inf(1),
+			Regression: true,
+			Formatted:  "n/a",
+		},
+		{
+			Name:      "zero baseline, better candidate",
+			Delta:     runMetricDelta{baseline: 0, candidate: 1, higherIsBetter: true, relative: true, threshold: 10},
+			Change:    0,
+			Formatted: "n/a",
+		},
+		{
+			Name:      "disabled check",
+			Delta:     runMetricDelta{baseline: 100, candidate: 10, higherIsBetter: true, relative: true, threshold: -1},
+			Change:    90,
+			Formatted: "-90.00%",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, tc.Change, tc.Delta.getChange())
+			assert.Equal(t, tc.Regression, tc.Delta.isRegression())
+			assert.Equal(t, tc.Formatted, tc.Delta.formatDelta())
+		})
+	}
+}
+
+// TestGetRunMetricDeltas tests that the optional metrics are only compared when both runs
+// have them.
+func TestGetRunMetricDeltas(t *testing.T) {
+	type Test struct {
+		Name      string
+		Baseline  RunSummary
+		Candidate RunSummary
+		Expected  []string
+	}
+
+	requestMetrics := []string{"Transactions per second", "Error rate (%)", "Request latency p50 (s)", "Request latency p90 (s)", "Request latency p99 (s)"}
+	inclusionMetrics := []string{"Inclusion latency p50 (s)", "Inclusion latency p90 (s)", "Inclusion latency p99 (s)"}
+	summarized := RunSummary{MinedTransactionsPerSec: 10, GasPerSecond: 1e6, InclusionLatencies: []InclusionLatency{{Mode: "all"}}}
+
+	tests := []Test{
+		{
+			Name:     "light summaries",
+			Expected: requestMetrics,
+		},
+		{
+			Name:      "summarized runs",
+			Baseline:  summarized,
+			Candidate: summarized,
+			Expected:  append([]string{"Transactions per second", "Mined transactions per second", "Gas per second"}, append(requestMetrics[1:], inclusionMetrics...)...),
+		},
+		{
+			Name:      "only one summarized run",
+			Baseline:  summarized,
+			Candidate: RunSummary{},
+			Expected:  requestMetrics,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			names := make([]string, 0)
+			for _, d := range getRunMetricDeltas(tc.Baseline, tc.Candidate) {
+				names = append(names, d.name)
+			}
+			assert.Equal(t, tc.Expected, names)
+		})
+	}
+}
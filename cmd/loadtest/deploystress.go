@@ -0,0 +1,317 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	gethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// create2FactoryBin deploys the contract from contracts/src/asm/create2-factory.easm. The
+	// calldata is a salt followed by the initcode of the contract to deploy with CREATE2, and
+	// the call reverts when the deployment fails.
+	create2FactoryBin = "602380600b6000396000f36020360380602060003760003590600034f580601a57600080fd5b60005260206000f3"
+
+	// deployInitcodePrefix copies the runtime code that follows it and returns it. The size of
+	// the runtime code is written into the PUSH3 at offset 1.
+	deployInitcodePrefix = "6200000080600d6000396000f3"
+	// deploySelfDestructInitcodePrefix copies the runtime code that follows it into memory
+	// and self-destructs, so the contract is created and removed in the same transaction.
+	deploySelfDestructInitcodePrefix = "62000000600b60003933ff"
+
+	// deployLimitCodeSize is set when the runtime code is over the EIP-170 limit.
+	deployLimitCodeSize = "code-size"
+	// deployLimitInitcodeSize is set when the initcode is over the EIP-3860 limit.
+	deployLimitInitcodeSize = "initcode-size"
+
+	// deployMaxSize is the largest runtime code that fits the PUSH3 of the initcode.
+	deployMaxSize = 1<<24 - 1
+	// deployGasOverhead covers the opcodes of the factory and of the initcode, on top of the
+	// costs that grow with the size of the contract.
+	deployGasOverhead = 20000
+	// deployMaxCalldataSize is the largest calldata that fits in the 128KB transactions
+	// accepted by the geth txpool, leaving room for the rest of the transaction.
+	deployMaxCalldataSize = 127 * 1024
+)
+
+type (
+	// sentDeployment is a deployment sent during the load test.
+	sentDeployment struct {
+		Hash ethcommon.Hash
+		Size uint64
+	}
+
+	// deployStressLoadTest holds the CREATE2 factory used in deploy-stress mode and the
+	// deployments that were sent.
+	deployStressLoadTest struct {
+		address  ethcommon.Address
+		contract *bind.BoundContract
+
+		sent      []sentDeployment
+		callsOnly map[uint64]*DeployStressSummary
+		mutex     sync.Mutex
+		summaries []DeployStressSummary
+	}
+
+	// DeployStressSummary holds the outcome of the deployments of a runtime code size. The
+	// deployments over a size limit are expected to be rejected, and the others to succeed.
+	DeployStressSummary struct {
+		Size         uint64
+		InitcodeSize uint64
+		Limit        string
+		Sent         int
+		Deployed     int
+		Rejected     int
+	}
+)
+
+var deployStress *deployStressLoadTest
+
+// initDeployStressLoadTest checks that the deployments of every size can be sent, and deploys
+// the CREATE2 factory used to deploy the contracts.
+func initDeployStressLoadTest(ctx context.Context, c *ethclient.Client, tops *bind.TransactOpts) (*deployStressLoadTest, error) {
+	if err := checkDeploySizes(ctx, c); err != nil {
+		return nil, err
+	}
+	address, tx, contract, err := bind.DeployContract(tops, gethabi.ABI{}, ethcommon.FromHex(create2FactoryBin), c)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to deploy the CREATE2 factory")
+		return nil, err
+	}
+	if _, err = bind.WaitDeployed(ctx, c, tx); err != nil {
+		log.Error().Err(err).Msg("Unable to wait for the CREATE2 factory deployment")
+		return nil, err
+	}
+	log.Debug().Stringer("address", address).Msg("CREATE2 factory deployed")
+	return &deployStressLoadTest{
+		address:   address,
+		contract:  contract,
+		callsOnly: make(map[uint64]*DeployStressSummary),
+	}, nil
+}
+
+// checkDeploySizes makes sure that the deployments of every size fit in a block and in the
+// txpool. The nodes reject the larger ones when they're sent, so their nonce would be sent
+// again and again instead of counting them as rejected on chain.
+func checkDeploySizes(ctx context.Context, c *ethclient.Client) error {
+	header, err := c.HeaderByNumber(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get the latest block header")
+		return err
+	}
+	for _, size := range *inputLoadTestParams.DeploySizes {
+		initcodeSize := getDeployInitcodeSize(uint64(size))
+		if gasLimit := getDeployGasLimit(uint64(size), initcodeSize); gasLimit > header.GasLimit {
+			return fmt.Errorf("the deployment of %d bytes needs a gas limit of %d, which is over the block gas limit of %d", size, gasLimit, header.GasLimit)
+		}
+		if calldataSize := 32 + initcodeSize; calldataSize > deployMaxCalldataSize {
+			return fmt.Errorf("the deployment of %d bytes has %d bytes of calldata, which is over the txpool limit of %d", size, calldataSize, deployMaxCalldataSize)
+		}
+	}
+	return nil
+}
+
+// getDeployInitcode returns the initcode deploying a runtime code of the given size. The
+// runtime code starts with STOP, so that it never starts with the 0xEF byte rejected by
+// EIP-3541, and is otherwise random, so that every contract has a different code hash. With
+// `--deploy-selfdestruct`, the runtime code is zeroed so that every deployment of a size
+// has the same address.
+func getDeployInitcode(size uint64) []byte {
+	initcode := ethcommon.FromHex(getDeployInitcodePrefix())
+	var sizeBytes [4]byte
+	binary.BigEndian.PutUint32(sizeBytes[:], uint32(size))
+	copy(initcode[1:4], sizeBytes[1:])
+
+	runtime := make([]byte, size)
+	if !*inputLoadTestParams.DeploySelfDestruct && size > 1 {
+		_, _ = randSrc.Read(runtime[1:])
+	}
+	return append(initcode, runtime...)
+}
+
+func getDeployInitcodePrefix() string {
+	if *inputLoadTestParams.DeploySelfDestruct {
+		return deploySelfDestructInitcodePrefix
+	}
+	return deployInitcodePrefix
+}
+
+// getDeployInitcodeSize returns the size of the initcode deploying a runtime code of the
+// given size.
+func getDeployInitcodeSize(size uint64) uint64 {
+	return uint64(len(getDeployInitcodePrefix())/2) + size
+}
+
+// getDeployLimit returns the size limit that a deployment is over, if any.
+func getDeployLimit(size, initcodeSize uint64) string {
+	ltp := inputLoadTestParams
+	if initcodeSize > *ltp.DeployInitcodeSizeLimit {
+		return deployLimitInitcodeSize
+	}
+	if size > *ltp.DeployCodeSizeLimit && !*ltp.DeploySelfDestruct {
+		return deployLimitCodeSize
+	}
+	return ""
+}
+
+// getDeployGasLimit returns an upper bound of the gas used by a deployment, so that the
+// deployments over the limits, whose gas can't be estimated, are still sent.
+func getDeployGasLimit(size, initcodeSize uint64) uint64 {
+	words := (initcodeSize + 31) / 32
+	memory := 3*words + words*words/512
+	// The transaction, the calldata, CREATE2 and the memory of the factory and of the
+	// initcode, then the copies, the hash and the EIP-3860 cost of the initcode.
+	gas := 21000 + 16*(32+initcodeSize) + 32000 + 2*memory + 14*words + 200*size + deployGasOverhead
+	// CREATE2 only passes 63/64 of the remaining gas to the initcode.
+	return gas + gas/63
+}
+
+// loadTestDeployStress deploys a contract of a random size with CREATE2 through the factory.
+// The deployment uses a random salt, or always the same one with `--deploy-selfdestruct`.
+func loadTestDeployStress(ctx context.Context, c *ethclient.Client, account *loadTestAccount, nonce uint64, d *deployStressLoadTest) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
+	ltp := inputLoadTestParams
+
+	sizes := *ltp.DeploySizes
+	size := uint64(sizes[randSrc.Intn(len(sizes))])
+	initcode := getDeployInitcode(size)
+	initcodeSize := uint64(len(initcode))
+	var salt ethcommon.Hash
+	if !*ltp.DeploySelfDestruct {
+		_, _ = randSrc.Read(salt[:])
+	}
+	data := append(salt.Bytes(), initcode...)
+
+	chainID := new(big.Int).SetUint64(*ltp.ChainID)
+	tops, err := bind.NewKeyedTransactorWithChainID(account.PrivateKey, chainID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable create transaction signer")
+		return
+	}
+	tops.Nonce = new(big.Int).SetUint64(nonce)
+	tops.GasLimit = getDeployGasLimit(size, initcodeSize)
+	tops = configureTransactOpts(ctx, c, tops)
+
+	t1 = time.Now()
+	defer func() { t2 = time.Now() }()
+	if *ltp.CallOnly {
+		msg := transactOptsToCallMsg(tops)
+		msg.To = &d.address
+		msg.Data = data
+		_, err = c.CallContract(ctx, msg, nil)
+		if err != nil {
+			switch getErrorCategory(err) {
+			case errorCategoryTransport, errorCategoryTimeout, errorCategoryPoolFull:
+				return
+			}
+		}
+		// A rejection at a size limit is the expected result of the call.
+		if d.recordCall(size, initcodeSize, err == nil) {
+			err = nil
+		}
+		return
+	}
+
+	var tx *ethtypes.Transaction
+	tx, err = d.contract.RawTransact(tops, data)
+	if err != nil {
+		return
+	}
+	txHash = tx.Hash()
+
+	d.mutex.Lock()
+	d.sent = append(d.sent, sentDeployment{Hash: txHash, Size: size})
+	d.mutex.Unlock()
+	return
+}
+
+// recordCall counts the outcome of a deployment in call only mode, and returns whether a
+// rejected deployment was over a size limit.
+func (d *deployStressLoadTest) recordCall(size, initcodeSize uint64, deployed bool) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	s, ok := d.callsOnly[size]
+	if !ok {
+		s = &DeployStressSummary{Size: size, InitcodeSize: initcodeSize, Limit: getDeployLimit(size, initcodeSize)}
+		d.callsOnly[size] = s
+	}
+	s.Sent++
+	if deployed {
+		s.Deployed++
+		return false
+	}
+	s.Rejected++
+	return s.Limit != ""
+}
+
+// resolve looks up the receipts of the deployments and counts the deployed and rejected
+// contracts of every size.
+func (d *deployStressLoadTest) resolve(ctx context.Context, c *ethclient.Client) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	summaries := make(map[uint64]*DeployStressSummary)
+	for size, s := range d.callsOnly {
+		summaries[size] = s
+	}
+	for _, deployment := range d.sent {
+		s, ok := summaries[deployment.Size]
+		if !ok {
+			initcodeSize := getDeployInitcodeSize(deployment.Size)
+			s = &DeployStressSummary{Size: deployment.Size, InitcodeSize: initcodeSize, Limit: getDeployLimit(deployment.Size, initcodeSize)}
+			summaries[deployment.Size] = s
+		}
+		s.Sent++
+		receipt, err := c.TransactionReceipt(ctx, deployment.Hash)
+		if err != nil {
+			log.Debug().Err(err).Stringer("txHash", deployment.Hash).Msg("Unable to get the receipt of the deployment")
+			continue
+		}
+		if receipt.Status == ethtypes.ReceiptStatusSuccessful {
+			s.Deployed++
+		} else {
+			s.Rejected++
+		}
+	}
+
+	d.summaries = make([]DeployStressSummary, 0, len(summaries))
+	for _, s := range summaries {
+		d.summaries = append(d.summaries, *s)
+	}
+	sort.Slice(d.summaries, func(i, j int) bool {
+		return d.summaries[i].Size < d.summaries[j].Size
+	})
+}
+
+func deployStressLightSummary() {
+	if deployStress == nil || len(deployStress.summaries) == 0 {
+		return
+	}
+	log.Info().Stringer("factory", deployStress.address).Msg("* Deployment results")
+	for _, s := range deployStress.summaries {
+		log.Info().
+			Uint64("size", s.Size).
+			Uint64("initcodeSize", s.InitcodeSize).
+			Str("limit", s.Limit).
+			Int("sent", s.Sent).
+			Int("deployed", s.Deployed).
+			Int("rejected", s.Rejected).
+			Msg("Deployment Stats")
+		switch {
+		case s.Limit != "" && s.Deployed > 0:
+			log.Warn().Uint64("size", s.Size).Str("limit", s.Limit).Int("deployed", s.Deployed).Msg("Contracts over a size limit were deployed")
+		case s.Limit == "" && s.Rejected > 0:
+			log.Warn().Uint64("size", s.Size).Int("rejected", s.Rejected).Msg("Contracts within the size limits were rejected")
+		}
+	}
+}
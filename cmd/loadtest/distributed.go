@@ -0,0 +1,483 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/pflag"
+)
+
+// workerStartDelay gives the workers time to receive their assignment so that they all start at
+// the same time.
+const workerStartDelay = 5 * time.Second
+
+// coordinatorOnlyFlags aren't forwarded to the workers, either because they're specific to a
+// process or because the coordinator splits them across the workers.
+var coordinatorOnlyFlags = []string{
+	"coordinator",
+	"coordinator-token",
+	"coordinator-results-timeout",
+	"workers",
+	"worker",
+	"rpc-url",
+	"rpc-urls",
+	"private-key",
+	"concurrency",
+	"rate-limit",
+	"sending-accounts",
+	"sending-accounts-file",
+	"summarize",
+	"results-dir",
+	"prometheus-port",
+	"record-file",
+}
+
+type (
+	// workerRegistration is sent by a worker to register with the coordinator. It only carries
+	// the addresses of the sending accounts of the worker, which the coordinator funds.
+	workerRegistration struct {
+		Addresses []ethcommon.Address
+	}
+
+	// workerAssignment is the share of the load test given to a worker by the coordinator.
+	workerAssignment struct {
+		ID          int
+		StartTime   time.Time
+		Flags       map[string][]string
+		Concurrency int64
+		RateLimit   float64
+	}
+
+	// workerResults holds the samples recorded by a worker.
+	workerResults struct {
+		ID      int
+		Samples []loadTestSample
+	}
+
+	// loadTestCoordinator hands out the assignments and collects the results of the workers.
+	loadTestCoordinator struct {
+		assignments []workerAssignment
+		addresses   [][]ethcommon.Address
+		reported    []bool
+		registered  int
+		// allRegistered is closed once every worker has registered, and ready once their
+		// sending accounts are funded.
+		allRegistered chan struct{}
+		ready         chan struct{}
+		results       chan workerResults
+		mutex         sync.Mutex
+	}
+)
+
+// loadTestLocalFlags are the flags defined by the loadtest command, without the flags inherited
+// from the root command.
+var loadTestLocalFlags *pflag.FlagSet
+
+// workerPrivateKeys are the keys of the sending accounts of a worker. They never leave the
+// worker, which only registers their addresses with the coordinator.
+var workerPrivateKeys []*ecdsa.PrivateKey
+
+// runCoordinator waits for the workers to register, funds their sending accounts, then merges the
+// samples they send back into a single summary.
+func runCoordinator(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) error {
+	ltp := inputLoadTestParams
+	workers := int(*ltp.Workers)
+
+	tops, err := bind.NewKeyedTransactorWithChainID(ltp.ECDSAPrivateKey, new(big.Int).SetUint64(*ltp.ChainID))
+	if err != nil {
+		log.Error().Err(err).Msg("Unable create transaction signer")
+		return err
+	}
+	tops = configureTransactOpts(ctx, c, tops)
+	tops.GasLimit = 0
+	tops.GasPrice = nil
+	tops.GasFeeCap = nil
+	tops.GasTipCap = nil
+
+	coordinator := &loadTestCoordinator{
+		assignments:   getWorkerAssignments(workers),
+		addresses:     make([][]ethcommon.Address, workers),
+		reported:      make([]bool, workers),
+		allRegistered: make(chan struct{}),
+		ready:         make(chan struct{}),
+		results:       make(chan workerResults, workers),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/register", coordinator.handleRegister)
+	mux.HandleFunc("/results", coordinator.handleResults)
+	server := &http.Server{Addr: *ltp.Coordinator, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("Coordinator server failed")
+		}
+	}()
+	defer server.Close()
+	log.Info().Str("address", *ltp.Coordinator).Int("workers", workers).Msg("Waiting for the workers to register")
+
+	select {
+	case <-coordinator.allRegistered:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	addresses := make([]ethcommon.Address, 0)
+	for _, a := range coordinator.addresses {
+		addresses = append(addresses, a...)
+	}
+	if err = fundSendingAccounts(ctx, c, tops, addresses); err != nil {
+		log.Error().Err(err).Msg("Unable to fund the sending accounts of the workers")
+		return err
+	}
+	startTime := time.Now().Add(workerStartDelay)
+	for i := range coordinator.assignments {
+		coordinator.assignments[i].StartTime = startTime
+	}
+	close(coordinator.ready)
+
+	startBlockNumber, err = c.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+	if !*ltp.CallOnly {
+		inclusions = startInclusionTracker(ctx, c, rpc, startBlockNumber)
+		// The hashes of the transactions are only known once the workers report back.
+		inclusions.trackSenders(addresses)
+	}
+
+	results := coordinator.collectResults(ctx, startTime)
+	loadTestResutsMutex.Lock()
+	loadTestResults = results
+	loadTestResutsMutex.Unlock()
+
+	// The results received before the load test was stopped are still summarized.
+	ctx = context.Background()
+	finalBlockNumber, err = c.BlockNumber(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to retrieve final block number")
+	}
+	return finishLoadTest(ctx, c, rpc)
+}
+
+// collectResults waits for the results of every worker until `--coordinator-results-timeout`
+// after the end of `--time-limit`, or after the start of the load test without a time limit. The
+// results received until then are returned when some workers don't report back in time or the
+// load test is stopped.
+func (lc *loadTestCoordinator) collectResults(ctx context.Context, startTime time.Time) []loadTestSample {
+	ltp := inputLoadTestParams
+	deadline := startTime.Add(time.Duration(*ltp.TimeLimit)*time.Second + time.Duration(*ltp.CoordinatorResultsTimeout)*time.Second)
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	results := make([]loadTestSample, 0)
+	goRoutineOffsets := getGoRoutineOffsets(lc.assignments)
+	received := 0
+collect:
+	for received < len(lc.assignments) {
+		select {
+		case r := <-lc.results:
+			log.Info().Int("worker", r.ID).Int("samples", len(r.Samples)).Msg("Received the results of a worker")
+			for _, s := range r.Samples {
+				s.GoRoutineID += goRoutineOffsets[r.ID]
+				results = append(results, s)
+			}
+			received++
+		case <-timer.C:
+			log.Warn().Int("received", received).Int("workers", len(lc.assignments)).Msg("Timed out waiting for the results of the workers")
+			break collect
+		case <-ctx.Done():
+			log.Warn().Int("received", received).Int("workers", len(lc.assignments)).Msg("Stopped waiting for the results of the workers")
+			break collect
+		}
+	}
+	slices.SortFunc(results, func(a, b loadTestSample) int {
+		return a.RequestTime.Compare(b.RequestTime)
+	})
+	return results
+}
+
+// getWorkerAssignments splits the concurrency and the rate limit evenly across the workers. The
+// other flags set on the coordinator are forwarded as they are.
+func getWorkerAssignments(workers int) []workerAssignment {
+	ltp := inputLoadTestParams
+	flags := getForwardedFlags()
+
+	assignments := make([]workerAssignment, workers)
+	for i := range assignments {
+		a := workerAssignment{
+			ID:          i,
+			Flags:       flags,
+			Concurrency: *ltp.Concurrency / int64(workers),
+			RateLimit:   *ltp.RateLimit,
+		}
+		if int64(i) < *ltp.Concurrency%int64(workers) {
+			a.Concurrency++
+		}
+		if *ltp.RateLimit > 0 {
+			a.RateLimit = *ltp.RateLimit / float64(workers)
+		}
+		assignments[i] = a
+	}
+	return assignments
+}
+
+// getForwardedFlags returns the loadtest flags set on the coordinator that the workers need.
+func getForwardedFlags() map[string][]string {
+	flags := make(map[string][]string)
+	loadTestLocalFlags.Visit(func(f *pflag.Flag) {
+		if slices.Contains(coordinatorOnlyFlags, f.Name) {
+			return
+		}
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			flags[f.Name] = sv.GetSlice()
+		} else {
+			flags[f.Name] = []string{f.Value.String()}
+		}
+	})
+	return flags
+}
+
+// getGoRoutineOffsets returns the offsets that keep the go routine ids of the workers distinct.
+func getGoRoutineOffsets(assignments []workerAssignment) map[int]int64 {
+	offsets := make(map[int]int64, len(assignments))
+	var offset int64
+	for _, a := range assignments {
+		offsets[a.ID] = offset
+		offset += a.Concurrency
+	}
+	return offsets
+}
+
+// authorize checks that a request of a worker carries the `--coordinator-token`.
+func authorize(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(*inputLoadTestParams.CoordinatorToken)) != 1 {
+		log.Warn().Str("remoteAddr", r.RemoteAddr).Msg("Rejected a request with an invalid token")
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// handleRegister gives the next assignment to a worker. The response is held until all the workers
+// have registered and their sending accounts are funded so that they start together.
+func (lc *loadTestCoordinator) handleRegister(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	var registration workerRegistration
+	if err := json.NewDecoder(r.Body).Decode(&registration); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(registration.Addresses) == 0 {
+		http.Error(w, "no sending accounts", http.StatusBadRequest)
+		return
+	}
+	if slices.Contains(registration.Addresses, *inputLoadTestParams.FromETHAddress) {
+		http.Error(w, "a sending account is the same as the funding account", http.StatusBadRequest)
+		return
+	}
+
+	lc.mutex.Lock()
+	if lc.registered == len(lc.assignments) {
+		lc.mutex.Unlock()
+		http.Error(w, "all the workers are already registered", http.StatusConflict)
+		return
+	}
+	id := lc.registered
+	lc.registered++
+	lc.addresses[id] = registration.Addresses
+	log.Info().Int("worker", id).Int("sendingAccounts", len(registration.Addresses)).Str("remoteAddr", r.RemoteAddr).Msg("Worker registered")
+	if lc.registered == len(lc.assignments) {
+		close(lc.allRegistered)
+	}
+	lc.mutex.Unlock()
+
+	select {
+	case <-lc.ready:
+	case <-r.Context().Done():
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(lc.assignments[id]); err != nil {
+		log.Error().Err(err).Int("worker", id).Msg("Unable to send the assignment")
+	}
+}
+
+// handleResults receives the samples recorded by a worker.
+func (lc *loadTestCoordinator) handleResults(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	var results workerResults
+	if err := json.NewDecoder(r.Body).Decode(&results); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lc.mutex.Lock()
+	if results.ID < 0 || results.ID >= lc.registered || lc.reported[results.ID] {
+		lc.mutex.Unlock()
+		http.Error(w, "unexpected results", http.StatusConflict)
+		return
+	}
+	lc.reported[results.ID] = true
+	lc.mutex.Unlock()
+	lc.results <- results
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getWorkerPrivateKeys returns the keys of the sending accounts of a worker. They're read from
+// `--sending-accounts-file` if it's given, so that the funds left in the accounts can be recovered,
+// and generated otherwise.
+func getWorkerPrivateKeys() ([]*ecdsa.PrivateKey, error) {
+	ltp := inputLoadTestParams
+	count := max(*ltp.SendingAccounts, 1)
+	if *ltp.SendingAccountsFile != "" {
+		return loadSendingAccountsFile(*ltp.SendingAccountsFile, count)
+	}
+	privateKeys := make([]*ecdsa.PrivateKey, 0, count)
+	for range count {
+		pk, err := ethcrypto.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		privateKeys = append(privateKeys, pk)
+	}
+	return privateKeys, nil
+}
+
+// postToCoordinator sends a request with the `--coordinator-token` to the coordinator.
+func postToCoordinator(ctx context.Context, path string, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, *inputLoadTestParams.Worker+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+*inputLoadTestParams.CoordinatorToken)
+	return http.DefaultClient.Do(req)
+}
+
+// joinCoordinator registers the addresses of the sending accounts of the worker with the
+// coordinator and applies its assignment. It returns once the load test is due to start.
+func joinCoordinator(ctx context.Context) (*workerAssignment, error) {
+	ltp := inputLoadTestParams
+	privateKeys, err := getWorkerPrivateKeys()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get the sending accounts of the worker")
+		return nil, err
+	}
+	registration := workerRegistration{Addresses: make([]ethcommon.Address, 0, len(privateKeys))}
+	for _, pk := range privateKeys {
+		address := ethcrypto.PubkeyToAddress(pk.PublicKey)
+		log.Info().Stringer("address", address).Msg("Sending account of the worker")
+		registration.Addresses = append(registration.Addresses, address)
+	}
+
+	log.Info().Str("coordinator", *ltp.Worker).Msg("Registering with the coordinator")
+	resp, err := postToCoordinator(ctx, "/register", registration)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to register with the coordinator")
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the coordinator refused the registration: %s", resp.Status)
+	}
+	var assignment workerAssignment
+	if err = json.NewDecoder(resp.Body).Decode(&assignment); err != nil {
+		return nil, err
+	}
+
+	for name, values := range assignment.Flags {
+		f := loadTestFlags.Lookup(name)
+		if f == nil {
+			return nil, fmt.Errorf("the coordinator forwarded an unknown flag: %s", name)
+		}
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			if err = sv.Replace(values); err != nil {
+				return nil, err
+			}
+			f.Changed = true
+		} else if err = loadTestFlags.Set(name, values[0]); err != nil {
+			return nil, err
+		}
+	}
+	*ltp.Concurrency = assignment.Concurrency
+	*ltp.RateLimit = assignment.RateLimit
+	*ltp.SendingAccountsFile = ""
+	*ltp.ShouldProduceSummary = false
+	*ltp.ResultsDir = ""
+	workerPrivateKeys = privateKeys
+	// The first sending account is also used to deploy the contracts.
+	*ltp.PrivateKey = hex.EncodeToString(ethcrypto.FromECDSA(privateKeys[0]))
+	*ltp.SendingAccounts = uint64(len(privateKeys))
+	if err = checkLoadtestFlags(); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Int("worker", assignment.ID).
+		Int("sendingAccounts", len(privateKeys)).
+		Int64("concurrency", assignment.Concurrency).
+		Float64("rateLimit", assignment.RateLimit).
+		Time("startTime", assignment.StartTime).
+		Msg("Received the assignment of the coordinator")
+	select {
+	case <-time.After(time.Until(assignment.StartTime)):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &assignment, nil
+}
+
+// reportToCoordinator sends the samples recorded by the worker to the coordinator.
+func reportToCoordinator(ctx context.Context, assignment *workerAssignment) error {
+	loadTestResutsMutex.Lock()
+	results := workerResults{ID: assignment.ID, Samples: loadTestResults}
+	loadTestResutsMutex.Unlock()
+	resp, err := postToCoordinator(ctx, "/results", results)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("the coordinator refused the results: %s", resp.Status)
+	}
+	log.Info().Int("samples", len(results.Samples)).Msg("Sent the results to the coordinator")
+	return nil
+}
+
+// getWorkerSendingAccounts returns the sending accounts of a worker. They're already funded by
+// the coordinator.
+func getWorkerSendingAccounts() []*loadTestAccount {
+	accounts := make([]*loadTestAccount, 0, len(workerPrivateKeys))
+	for _, pk := range workerPrivateKeys {
+		accounts = append(accounts, newLoadTestAccount(pk))
+	}
+	return accounts
+}
@@ -0,0 +1,180 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	drainPollInterval = 2 * time.Second
+	drainBatchSize    = 100
+)
+
+type (
+	// sentTransactions is the set of transactions sent during the load test whose final state
+	// is still unknown.
+	sentTransactions struct {
+		outstanding map[ethcommon.Hash]struct{}
+		mutex       sync.Mutex
+	}
+
+	// drainReceipt is the part of a receipt needed to tell mined and failed transactions apart.
+	drainReceipt struct {
+		Status hexutil.Uint64 `json:"status"`
+	}
+
+	// DrainSummary holds the final state of the transactions sent before the load test was
+	// stopped: mined, failed on chain, dropped by the node, or still pending once the drain
+	// timeout was reached.
+	DrainSummary struct {
+		Sent    int
+		Mined   int
+		Failed  int
+		Dropped int
+		Pending int
+	}
+)
+
+var sentTxs = &sentTransactions{outstanding: make(map[ethcommon.Hash]struct{})}
+
+func (s *sentTransactions) add(txHash ethcommon.Hash) {
+	s.mutex.Lock()
+	s.outstanding[txHash] = struct{}{}
+	s.mutex.Unlock()
+}
+
+func (s *sentTransactions) getOutstanding() []ethcommon.Hash {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	hashes := make([]ethcommon.Hash, 0, len(s.outstanding))
+	for txHash := range s.outstanding {
+		hashes = append(hashes, txHash)
+	}
+	return hashes
+}
+
+func (s *sentTransactions) remove(txHashes []ethcommon.Hash) {
+	s.mutex.Lock()
+	for _, txHash := range txHashes {
+		delete(s.outstanding, txHash)
+	}
+	s.mutex.Unlock()
+}
+
+// drainTransactions waits for the receipts of the transactions sent during the load test until
+// all of them are resolved or the context is done. A transaction without a receipt that the
+// node doesn't know anymore is dropped, and the transactions that are left when the context is
+// done are still pending.
+func drainTransactions(ctx context.Context, rpc *ethrpc.Client) *DrainSummary {
+	summary := &DrainSummary{}
+	summary.Sent = len(sentTxs.getOutstanding())
+	if summary.Sent == 0 {
+		return summary
+	}
+	log.Info().Int("transactions", summary.Sent).Msg("Draining the transactions sent during the load test")
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		// The transactions sent by the requests that were in flight when the load test was
+		// stopped are picked up by the next round.
+		hashes := sentTxs.getOutstanding()
+		for start := 0; start < len(hashes) && ctx.Err() == nil; start += drainBatchSize {
+			batch := hashes[start:min(start+drainBatchSize, len(hashes))]
+			resolved := getDrainedTransactions(ctx, rpc, batch, summary)
+			sentTxs.remove(resolved)
+		}
+		if len(sentTxs.getOutstanding()) == 0 {
+			break
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	pending := sentTxs.getOutstanding()
+	summary.Pending = len(pending)
+	summary.Sent = summary.Mined + summary.Failed + summary.Dropped + summary.Pending
+	for _, txHash := range pending {
+		log.Debug().Stringer("txHash", txHash).Msg("Transaction still pending after the drain")
+	}
+	return summary
+}
+
+// getDrainedTransactions looks up the receipts of a batch of transactions, and the transactions
+// themselves when there is no receipt, and returns the hashes of the ones whose final state is
+// known.
+func getDrainedTransactions(ctx context.Context, rpc *ethrpc.Client, hashes []ethcommon.Hash, summary *DrainSummary) []ethcommon.Hash {
+	receipts := make([]*drainReceipt, len(hashes))
+	receiptElems := make([]ethrpc.BatchElem, len(hashes))
+	for k, txHash := range hashes {
+		receiptElems[k] = ethrpc.BatchElem{Method: "eth_getTransactionReceipt", Args: []any{txHash}, Result: &receipts[k]}
+	}
+	if err := rpc.BatchCallContext(ctx, receiptElems); err != nil {
+		log.Debug().Err(err).Msg("Unable to get the receipts of the drained transactions")
+		return nil
+	}
+
+	resolved := make([]ethcommon.Hash, 0, len(hashes))
+	missing := make([]ethcommon.Hash, 0)
+	for k, txHash := range hashes {
+		switch {
+		case receiptElems[k].Error != nil:
+			log.Debug().Err(receiptElems[k].Error).Stringer("txHash", txHash).Msg("Unable to get the receipt of the drained transaction")
+		case receipts[k] == nil:
+			missing = append(missing, txHash)
+		case uint64(receipts[k].Status) == ethtypes.ReceiptStatusSuccessful:
+			summary.Mined++
+			resolved = append(resolved, txHash)
+		default:
+			summary.Failed++
+			resolved = append(resolved, txHash)
+		}
+	}
+	if len(missing) == 0 {
+		return resolved
+	}
+
+	txs := make([]*json.RawMessage, len(missing))
+	txElems := make([]ethrpc.BatchElem, len(missing))
+	for k, txHash := range missing {
+		txElems[k] = ethrpc.BatchElem{Method: "eth_getTransactionByHash", Args: []any{txHash}, Result: &txs[k]}
+	}
+	if err := rpc.BatchCallContext(ctx, txElems); err != nil {
+		log.Debug().Err(err).Msg("Unable to get the drained transactions")
+		return resolved
+	}
+	for k, txHash := range missing {
+		if txElems[k].Error == nil && txs[k] == nil {
+			log.Debug().Stringer("txHash", txHash).Msg("Transaction dropped by the node")
+			summary.Dropped++
+			resolved = append(resolved, txHash)
+		}
+	}
+	return resolved
+}
+
+func drainLightSummary(s *DrainSummary) {
+	if s == nil || s.Sent == 0 {
+		return
+	}
+	log.Info().
+		Int("sent", s.Sent).
+		Int("mined", s.Mined).
+		Int("failed", s.Failed).
+		Int("dropped", s.Dropped).
+		Int("pending", s.Pending).
+		Msg("Final transaction states")
+}
@@ -0,0 +1,321 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/montanaflynn/stats"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// rpcStrategyRoundRobin sends every transaction to the next endpoint.
+	rpcStrategyRoundRobin = "round-robin"
+	// rpcStrategyMirror sends every transaction to all the endpoints.
+	rpcStrategyMirror = "mirror"
+)
+
+type (
+	// rpcEndpoint is an RPC endpoint that transactions are sent to, along with the latency
+	// and the errors of the sends.
+	rpcEndpoint struct {
+		URL *url.URL
+
+		sent      int
+		latencies []float64
+		errors    map[string]int
+		mutex     sync.Mutex
+
+		// tracker watches the blocks of the endpoint in mirror mode.
+		client  *ethclient.Client
+		rpc     *ethrpc.Client
+		tracker *inclusionTracker
+	}
+
+	// rpcEndpointsTransport is an http.RoundTripper that sends the eth_sendRawTransaction
+	// requests to several endpoints, either one after the other or to all of them. Every other
+	// request goes to `--rpc-url`, which is the first endpoint.
+	rpcEndpointsTransport struct {
+		base       http.RoundTripper
+		httpClient *http.Client
+		strategy   string
+		endpoints  []*rpcEndpoint
+		next       atomic.Uint64
+	}
+
+	jsonRPCErrorResponse struct {
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	// RPCEndpointSummary holds the latency percentiles, in seconds, and the errors of the
+	// transactions sent to an endpoint. In mirror mode, it also counts the transactions that
+	// the endpoint reported first in a block and the percentiles of its lag behind the first
+	// endpoint.
+	RPCEndpointSummary struct {
+		URL       string
+		Sent      int
+		Errors    map[string]int
+		P50       float64
+		P90       float64
+		P99       float64
+		FirstSeen int
+		LagP50    float64
+		LagP90    float64
+	}
+)
+
+var rpcEndpoints *rpcEndpointsTransport
+
+// newRPCEndpointsTransport creates the transport sending the transactions to `--rpc-url` and
+// to the extra endpoints. The block watchers of mirror mode dial the endpoints with the
+// given client, which doesn't go through this transport.
+func newRPCEndpointsTransport(base http.RoundTripper, httpClient *http.Client) (*rpcEndpointsTransport, error) {
+	ltp := inputLoadTestParams
+	urls := append([]string{*ltp.RPCUrl}, *ltp.RPCURLs...)
+	t := &rpcEndpointsTransport{
+		base:       base,
+		httpClient: httpClient,
+		strategy:   *ltp.RPCStrategy,
+		endpoints:  make([]*rpcEndpoint, 0, len(urls)),
+	}
+	for _, rawURL := range urls {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		t.endpoints = append(t.endpoints, &rpcEndpoint{URL: u, errors: make(map[string]int)})
+	}
+	return t, nil
+}
+
+func (t *rpcEndpointsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil {
+		return t.base.RoundTrip(req)
+	}
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Batches aren't split, so they always go to the first endpoint.
+	var request jsonRPCRequest
+	if json.Unmarshal(body, &request) != nil || request.Method != "eth_sendRawTransaction" {
+		return t.base.RoundTrip(req)
+	}
+
+	if t.strategy == rpcStrategyMirror {
+		return t.mirror(req, body)
+	}
+	e := t.endpoints[(t.next.Add(1)-1)%uint64(len(t.endpoints))]
+	return e.send(t.base, req, body)
+}
+
+// mirror sends the request to all the endpoints at once and returns the response of the
+// first endpoint.
+func (t *rpcEndpointsTransport) mirror(req *http.Request, body []byte) (*http.Response, error) {
+	responses := make([]*http.Response, len(t.endpoints))
+	errs := make([]error, len(t.endpoints))
+	var wg sync.WaitGroup
+	for k, e := range t.endpoints {
+		wg.Add(1)
+		go func(k int, e *rpcEndpoint) {
+			defer wg.Done()
+			responses[k], errs[k] = e.send(t.base, req, body)
+		}(k, e)
+	}
+	wg.Wait()
+	return responses[0], errs[0]
+}
+
+// send forwards the request to the endpoint and records its latency and its error, if any.
+// The body of the response is read to find JSON-RPC errors, and then restored.
+func (e *rpcEndpoint) send(base http.RoundTripper, req *http.Request, body []byte) (*http.Response, error) {
+	u := *e.URL
+	r := req.Clone(req.Context())
+	r.URL = &u
+	r.Host = u.Host
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+
+	start := time.Now()
+	resp, err := base.RoundTrip(r)
+	var reason string
+	if err != nil {
+		reason = err.Error()
+	} else {
+		respBody, readErr := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(respBody))
+		var rpcResponse jsonRPCErrorResponse
+		switch {
+		case readErr != nil:
+			reason = readErr.Error()
+		case resp.StatusCode != http.StatusOK:
+			reason = resp.Status
+		case json.Unmarshal(respBody, &rpcResponse) == nil && rpcResponse.Error != nil:
+			reason = rpcResponse.Error.Message
+		}
+	}
+	latency := time.Since(start)
+
+	e.mutex.Lock()
+	e.sent++
+	if reason != "" {
+		e.errors[reason]++
+	} else {
+		e.latencies = append(e.latencies, latency.Seconds())
+	}
+	e.mutex.Unlock()
+	return resp, err
+}
+
+// startTrackers starts watching the blocks of every endpoint other than the first one, which
+// is watched by the inclusion tracker of the load test.
+func (t *rpcEndpointsTransport) startTrackers(ctx context.Context, startBlockNumber uint64) error {
+	if t.strategy != rpcStrategyMirror {
+		return nil
+	}
+	for _, e := range t.endpoints[1:] {
+		rpc, err := ethrpc.DialOptions(ctx, e.URL.String(), ethrpc.WithHTTPClient(t.httpClient))
+		if err != nil {
+			log.Error().Err(err).Str("url", e.URL.Redacted()).Msg("Unable to dial rpc")
+			return err
+		}
+		e.rpc = rpc
+		e.client = ethclient.NewClient(rpc)
+		e.tracker = startInclusionTracker(ctx, e.client, rpc, startBlockNumber)
+	}
+	return nil
+}
+
+// track adds a transaction sent by the load test to the transactions tracked on every
+// endpoint.
+func (t *rpcEndpointsTransport) track(hash ethcommon.Hash) {
+	for _, e := range t.endpoints[1:] {
+		if e.tracker != nil {
+			e.tracker.track(hash)
+		}
+	}
+}
+
+// stopTrackers stops watching the blocks of the endpoints.
+func (t *rpcEndpointsTransport) stopTrackers(ctx context.Context) {
+	for _, e := range t.endpoints {
+		if e.tracker == nil {
+			continue
+		}
+		e.tracker.stop(ctx, e.client, e.rpc)
+		e.rpc.Close()
+	}
+}
+
+// getSummaries returns the stats of every endpoint. In mirror mode, the successfully sent
+// transactions are looked up in the blocks seen by every endpoint to find which endpoint
+// reported them first.
+func (t *rpcEndpointsTransport) getSummaries(lts []loadTestSample) []RPCEndpointSummary {
+	summaries := make([]RPCEndpointSummary, 0, len(t.endpoints))
+	for _, e := range t.endpoints {
+		e.mutex.Lock()
+		s := RPCEndpointSummary{URL: e.URL.Redacted(), Sent: e.sent, Errors: make(map[string]int, len(e.errors))}
+		for reason, count := range e.errors {
+			s.Errors[reason] = count
+		}
+		s.P50, _ = stats.Percentile(e.latencies, 50)
+		s.P90, _ = stats.Percentile(e.latencies, 90)
+		s.P99, _ = stats.Percentile(e.latencies, 99)
+		e.mutex.Unlock()
+		summaries = append(summaries, s)
+	}
+	if t.strategy != rpcStrategyMirror || inclusions == nil {
+		return summaries
+	}
+
+	trackers := make([]*inclusionTracker, len(t.endpoints))
+	trackers[0] = inclusions
+	for k, e := range t.endpoints[1:] {
+		trackers[k+1] = e.tracker
+	}
+	lags := make([][]float64, len(t.endpoints))
+	for _, sample := range lts {
+		if sample.IsError || sample.TxHash == (ethcommon.Hash{}) {
+			continue
+		}
+		seenTimes := make([]time.Time, len(trackers))
+		first := -1
+		for k, tracker := range trackers {
+			if tracker == nil {
+				continue
+			}
+			seenTime, seen := tracker.getSeenTime(sample.TxHash)
+			if !seen {
+				continue
+			}
+			seenTimes[k] = seenTime
+			if first == -1 || seenTime.Before(seenTimes[first]) {
+				first = k
+			}
+		}
+		if first == -1 {
+			continue
+		}
+		summaries[first].FirstSeen++
+		for k, seenTime := range seenTimes {
+			if !seenTime.IsZero() {
+				lags[k] = append(lags[k], seenTime.Sub(seenTimes[first]).Seconds())
+			}
+		}
+	}
+	for k := range summaries {
+		summaries[k].LagP50, _ = stats.Percentile(lags[k], 50)
+		summaries[k].LagP90, _ = stats.Percentile(lags[k], 90)
+	}
+	return summaries
+}
+
+func rpcEndpointsLightSummary(lts []loadTestSample) {
+	if rpcEndpoints == nil {
+		return
+	}
+	log.Info().Str("strategy", rpcEndpoints.strategy).Msg("* RPC endpoint results")
+	for _, s := range rpcEndpoints.getSummaries(lts) {
+		var errorCount int
+		for _, count := range s.Errors {
+			errorCount += count
+		}
+		event := log.Info().
+			Str("url", s.URL).
+			Int("sent", s.Sent).
+			Int("errors", errorCount).
+			Float64("p50", s.P50).
+			Float64("p90", s.P90).
+			Float64("p99", s.P99)
+		if rpcEndpoints.strategy == rpcStrategyMirror {
+			event = event.Int("firstSeen", s.FirstSeen).Float64("lagP50", s.LagP50).Float64("lagP90", s.LagP90)
+		}
+		event.Msg("RPC Endpoint Stats")
+
+		reasons := make([]string, 0, len(s.Errors))
+		for reason := range s.Errors {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			log.Info().Str("url", s.URL).Str("reason", reason).Int("count", s.Errors[reason]).Msg("RPC Endpoint errors")
+		}
+	}
+}
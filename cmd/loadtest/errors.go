@@ -0,0 +1,224 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+)
+
+// errorCategory is the kind of an error returned while sending a request. The category
+// decides whether the nonce of the request is sent again.
+type errorCategory string
+
+const (
+	// errorCategoryNonce is returned when the nonce is already used by a transaction in the
+	// txpool or in a block.
+	errorCategoryNonce errorCategory = "nonce"
+	// errorCategoryNonceGap is returned when the nonce is ahead of the account nonce, e.g.
+	// because a lower nonce sent by the same account hasn't reached the txpool yet.
+	errorCategoryNonceGap errorCategory = "nonce-gap"
+	// errorCategoryFee is returned when the transaction doesn't pay enough, or when the
+	// account can't pay for it.
+	errorCategoryFee errorCategory = "fee"
+	// errorCategoryPoolFull is returned when the txpool or the RPC endpoint doesn't accept
+	// more transactions for now.
+	errorCategoryPoolFull errorCategory = "pool-full"
+	// errorCategoryGas is returned when the gas limit of the transaction is too low or too
+	// high, including the zk counters of cdk-erigon.
+	errorCategoryGas errorCategory = "gas"
+	// errorCategoryRevert is returned when the transaction reverts while estimating its gas.
+	errorCategoryRevert errorCategory = "revert"
+	// errorCategoryTransport is returned when the RPC endpoint couldn't be reached.
+	errorCategoryTransport errorCategory = "transport"
+	// errorCategoryTimeout is returned when the RPC endpoint didn't answer in time.
+	errorCategoryTimeout errorCategory = "timeout"
+	// errorCategoryUnknown is returned for errors that don't match any category.
+	errorCategoryUnknown errorCategory = "unknown"
+
+	// jsonRPCCodeReverted is the error code of reverted calls used by all the clients.
+	jsonRPCCodeReverted = 3
+	// jsonRPCCodeLimitExceeded is the EIP-1474 error code of requests exceeding a limit.
+	jsonRPCCodeLimitExceeded = -32005
+
+	poolFullBackoff  = time.Second
+	transportBackoff = 100 * time.Millisecond
+)
+
+// errorRetryPolicy describes what a sender does after a request failed with an error of a
+// category.
+type errorRetryPolicy struct {
+	// retry sends the next request with the same nonce, so that no nonce gap is left behind.
+	retry bool
+	// retryCallOnly also retries in call only mode, where the nonce is the index of the
+	// recalled transaction and a failure on chain shouldn't be sent again.
+	retryCallOnly bool
+	// backoff is how long the sender waits before sending the next request.
+	backoff time.Duration
+}
+
+var errorRetryPolicies = map[errorCategory]errorRetryPolicy{
+	errorCategoryNonce:     {},
+	errorCategoryNonceGap:  {retry: true},
+	errorCategoryFee:       {retry: true, retryCallOnly: true},
+	errorCategoryPoolFull:  {retry: true, backoff: poolFullBackoff},
+	errorCategoryGas:       {retry: true},
+	errorCategoryRevert:    {retry: true},
+	errorCategoryTransport: {retry: true, backoff: transportBackoff},
+	errorCategoryTimeout:   {retry: true},
+	errorCategoryUnknown:   {retry: true},
+}
+
+// errorMessagePatterns maps the lowercase error messages of geth, bor, erigon, reth and
+// cdk-erigon to the categories. The categories are matched in order, since some of the
+// messages contain each other, e.g. "replacement transaction underpriced".
+var errorMessagePatterns = []struct {
+	category errorCategory
+	patterns []string
+}{
+	{errorCategoryNonce, []string{
+		"nonce too low",
+		"invalid nonce",
+		"already known",
+		"already imported",
+		"known transaction",
+		"replacement transaction underpriced",
+		"could not replace existing",
+		"future transaction tries to replace pending",
+	}},
+	{errorCategoryNonceGap, []string{
+		"nonce too high",
+		"nonce too distant",
+	}},
+	{errorCategoryPoolFull, []string{
+		"pool is full",
+		"txpool full",
+		"pool size",
+		"account limit exceeded",
+		"exceeds max account slots",
+		"transaction limit reached",
+		"spammer",
+		"too many requests",
+		"rate limit",
+	}},
+	{errorCategoryGas, []string{
+		"intrinsic gas too low",
+		"exceeds block gas limit",
+		"gas limit exceeds",
+		"gas limit reached",
+		"gas required exceeds allowance",
+		"gas uint64 overflow",
+		"insufficient gas",
+		"out of gas",
+		"out of counters",
+		"not enough keccak counters",
+		"not enough step counters",
+		"resource_exhausted",
+	}},
+	{errorCategoryFee, []string{
+		"underpriced",
+		"fee cap less than block base fee",
+		"max fee per gas less than block base fee",
+		"max fee per blob gas less than block blob gas fee",
+		"max priority fee per gas higher than max fee per gas",
+		"fee too low",
+		"price too low",
+		"tip too low",
+		"exceeds the configured cap",
+		"insufficient funds",
+	}},
+	{errorCategoryRevert, []string{
+		"execution reverted",
+		"invalid opcode",
+	}},
+	{errorCategoryTimeout, []string{
+		"timeout",
+		"timed out",
+		"deadline exceeded",
+	}},
+	{errorCategoryTransport, []string{
+		"connection refused",
+		"connection reset",
+		"broken pipe",
+		"no such host",
+		"eof",
+	}},
+}
+
+// getErrorCategory classifies an error returned while sending a request. The JSON-RPC error
+// codes are used when they are specific enough, and the message otherwise, since most of the
+// clients return all the txpool errors with the same code.
+func getErrorCategory(err error) errorCategory {
+	var rpcErr ethrpc.Error
+	if errors.As(err, &rpcErr) {
+		switch rpcErr.ErrorCode() {
+		case jsonRPCCodeReverted:
+			return errorCategoryRevert
+		case jsonRPCCodeLimitExceeded:
+			return errorCategoryPoolFull
+		}
+		return getErrorMessageCategory(rpcErr.Error())
+	}
+
+	var httpErr ethrpc.HTTPError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return errorCategoryTimeout
+	case errors.As(err, &httpErr):
+		if httpErr.StatusCode == http.StatusTooManyRequests {
+			return errorCategoryPoolFull
+		}
+		return errorCategoryTransport
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return errorCategoryTimeout
+		}
+		return errorCategoryTransport
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return errorCategoryTransport
+	}
+	return getErrorMessageCategory(err.Error())
+}
+
+func getErrorMessageCategory(msg string) errorCategory {
+	msg = strings.ToLower(msg)
+	for _, p := range errorMessagePatterns {
+		for _, pattern := range p.patterns {
+			if strings.Contains(msg, pattern) {
+				return p.category
+			}
+		}
+	}
+	return errorCategoryUnknown
+}
+
+// getErrorCategoryCounts returns the number of errors of every category.
+func getErrorCategoryCounts(lts []loadTestSample) map[string]int {
+	counts := make(map[string]int)
+	for _, s := range lts {
+		if s.IsError {
+			counts[string(s.ErrorCategory)]++
+		}
+	}
+	return counts
+}
+
+func errorCategoryLightSummary(lts []loadTestSample) {
+	counts := getErrorCategoryCounts(lts)
+	categories := make([]string, 0, len(counts))
+	for category := range counts {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		log.Info().Str("category", category).Int("count", counts[category]).Msg("Num errors by category")
+	}
+}
@@ -0,0 +1,72 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+type testRPCError struct {
+	code int
+	msg  string
+}
+
+func (e testRPCError) Error() string  { return e.msg }
+func (e testRPCError) ErrorCode() int { return e.code }
+
+// TestGetErrorCategory tests the classification of the errors returned by the clients.
+func TestGetErrorCategory(t *testing.T) {
+	type Test struct {
+		Name     string
+		Err      error
+		Expected errorCategory
+	}
+
+	tests := []Test{
+		{Name: "nonce too low", Err: errors.New("nonce too low: next nonce 5, tx nonce 4"), Expected: errorCategoryNonce},
+		{Name: "already known", Err: errors.New("already known"), Expected: errorCategoryNonce},
+		{Name: "replacement underpriced", Err: errors.New("replacement transaction underpriced"), Expected: errorCategoryNonce},
+		{Name: "nonce too high", Err: errors.New("nonce too high"), Expected: errorCategoryNonceGap},
+		{Name: "nonce too distant", Err: errors.New("nonce too distant"), Expected: errorCategoryNonceGap},
+		{Name: "underpriced", Err: errors.New("transaction underpriced"), Expected: errorCategoryFee},
+		{Name: "base fee", Err: errors.New("max fee per gas less than block base fee"), Expected: errorCategoryFee},
+		{Name: "insufficient funds", Err: errors.New("insufficient funds for gas * price + value"), Expected: errorCategoryFee},
+		{Name: "txpool full", Err: errors.New("txpool is full"), Expected: errorCategoryPoolFull},
+		{Name: "intrinsic gas", Err: errors.New("intrinsic gas too low"), Expected: errorCategoryGas},
+		{Name: "zk counters", Err: errors.New("not enough keccak counters to continue the execution"), Expected: errorCategoryGas},
+		{Name: "reverted message", Err: errors.New("execution reverted"), Expected: errorCategoryRevert},
+		{Name: "reverted code", Err: testRPCError{code: jsonRPCCodeReverted, msg: "execution reverted: paused"}, Expected: errorCategoryRevert},
+		{Name: "limit exceeded code", Err: testRPCError{code: jsonRPCCodeLimitExceeded, msg: "request limit"}, Expected: errorCategoryPoolFull},
+		{Name: "generic code", Err: testRPCError{code: -32000, msg: "Nonce Too Low"}, Expected: errorCategoryNonce},
+		{Name: "deadline", Err: fmt.Errorf("send: %w", context.DeadlineExceeded), Expected: errorCategoryTimeout},
+		{Name: "http 429", Err: ethrpc.HTTPError{StatusCode: http.StatusTooManyRequests}, Expected: errorCategoryPoolFull},
+		{Name: "http 502", Err: ethrpc.HTTPError{StatusCode: http.StatusBadGateway}, Expected: errorCategoryTransport},
+		{Name: "eof", Err: io.ErrUnexpectedEOF, Expected: errorCategoryTransport},
+		{Name: "connection refused", Err: errors.New("dial tcp 127.0.0.1:8545: connect: connection refused"), Expected: errorCategoryTransport},
+		{Name: "unknown", Err: errors.New("something else"), Expected: errorCategoryUnknown},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, getErrorCategory(tc.Err))
+		})
+	}
+}
+
+// TestErrorRetryPolicies makes sure every category has a policy, and that only the used
+// nonces take a new nonce.
+func TestErrorRetryPolicies(t *testing.T) {
+	for _, p := range errorMessagePatterns {
+		_, ok := errorRetryPolicies[p.category]
+		assert.True(t, ok, "missing policy for %s", p.category)
+	}
+	for category, policy := range errorRetryPolicies {
+		assert.Equal(t, category != errorCategoryNonce, policy.retry, "retry policy of %s", category)
+	}
+}
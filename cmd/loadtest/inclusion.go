@@ -0,0 +1,367 @@
+package loadtest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/montanaflynn/stats"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// inclusionPollInterval is how often the block watcher polls for new blocks when the RPC
+	// endpoint doesn't support newHeads subscriptions.
+	inclusionPollInterval = 250 * time.Millisecond
+	// inclusionRecentBlocks is how many blocks the transactions that aren't tracked yet are
+	// kept for, in case they were included before the request that sent them returned.
+	inclusionRecentBlocks = 64
+)
+
+type (
+	// inclusionTracker watches the new blocks during the load test and records when the
+	// transactions sent by the load test were first seen in a block. The other transactions
+	// are only kept for a few blocks.
+	inclusionTracker struct {
+		lastBlock uint64
+		// firstSeen holds the tracked transactions that were seen in a block.
+		firstSeen map[ethcommon.Hash]inclusion
+		// pending holds the tracked transactions that weren't seen in a block yet.
+		pending map[ethcommon.Hash]struct{}
+		// recent holds the other transactions of the last blocks, by block number.
+		recent       map[ethcommon.Hash]inclusion
+		recentBlocks map[uint64][]ethcommon.Hash
+		// blockSeen holds when every block was seen.
+		blockSeen map[uint64]time.Time
+		// senders, when set, restricts the tracked transactions to the ones they sent.
+		senders map[ethcommon.Address]struct{}
+		mutex   sync.RWMutex
+		cancel  context.CancelFunc
+		done    chan struct{}
+	}
+	inclusion struct {
+		BlockNumber uint64
+		SeenTime    time.Time
+	}
+	inclusionBlock struct {
+		Number       hexutil.Uint64   `json:"number"`
+		Transactions []ethcommon.Hash `json:"transactions"`
+	}
+	inclusionFullBlock struct {
+		Number       hexutil.Uint64 `json:"number"`
+		Transactions []struct {
+			Hash ethcommon.Hash    `json:"hash"`
+			From ethcommon.Address `json:"from"`
+		} `json:"transactions"`
+	}
+
+	// InclusionLatency holds the percentiles, in seconds, of the time between sending a
+	// transaction and seeing it in a block.
+	InclusionLatency struct {
+		Mode     string
+		Included int
+		Missing  int
+		P50      float64
+		P90      float64
+		P99      float64
+		P999     float64
+	}
+)
+
+var inclusions *inclusionTracker
+
+// startInclusionTracker starts watching the blocks that follow the given block number.
+func startInclusionTracker(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client, startBlockNumber uint64) *inclusionTracker {
+	ctx, cancel := context.WithCancel(ctx)
+	t := &inclusionTracker{
+		lastBlock:    startBlockNumber,
+		firstSeen:    make(map[ethcommon.Hash]inclusion),
+		pending:      make(map[ethcommon.Hash]struct{}),
+		recent:       make(map[ethcommon.Hash]inclusion),
+		recentBlocks: make(map[uint64][]ethcommon.Hash),
+		blockSeen:    make(map[uint64]time.Time),
+		cancel:       cancel,
+		done:         make(chan struct{}),
+	}
+	go t.watch(ctx, c, rpc)
+	return t
+}
+
+// watch follows the chain head with a newHeads subscription, or by polling the block number
+// if subscriptions aren't supported.
+func (t *inclusionTracker) watch(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) {
+	defer close(t.done)
+
+	heads := make(chan *ethtypes.Header)
+	var subErr <-chan error
+	var poll <-chan time.Time
+	sub, err := c.SubscribeNewHead(ctx, heads)
+	if err != nil {
+		log.Debug().Err(err).Msg("Unable to subscribe to new heads. Polling for new blocks instead")
+		ticker := time.NewTicker(inclusionPollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	} else {
+		defer sub.Unsubscribe()
+		subErr = sub.Err()
+	}
+
+	for {
+		var head uint64
+		select {
+		case <-ctx.Done():
+			return
+		case h := <-heads:
+			head = h.Number.Uint64()
+		case err = <-subErr:
+			log.Warn().Err(err).Msg("The newHeads subscription failed. Polling for new blocks instead")
+			subErr = nil
+			ticker := time.NewTicker(inclusionPollInterval)
+			defer ticker.Stop()
+			poll = ticker.C
+			continue
+		case <-poll:
+			head, err = c.BlockNumber(ctx)
+			if err != nil {
+				log.Trace().Err(err).Msg("Unable to get the block number")
+				continue
+			}
+		}
+		if err = t.catchUp(ctx, rpc, head); err != nil {
+			log.Trace().Err(err).Msg("Unable to fetch the new blocks")
+		}
+	}
+}
+
+// catchUp records the transactions of every block up to the given head.
+func (t *inclusionTracker) catchUp(ctx context.Context, rpc *ethrpc.Client, head uint64) error {
+	for t.lastBlock < head {
+		number := t.lastBlock + 1
+		hashes, fromSenders, err := t.getBlockTransactions(ctx, rpc, number)
+		if err != nil {
+			return err
+		}
+		i := inclusion{BlockNumber: number, SeenTime: time.Now()}
+		t.mutex.Lock()
+		t.blockSeen[number] = i.SeenTime
+		for _, hash := range hashes {
+			t.see(hash, i, fromSenders)
+		}
+		if number > inclusionRecentBlocks {
+			old := number - inclusionRecentBlocks
+			for _, hash := range t.recentBlocks[old] {
+				delete(t.recent, hash)
+			}
+			delete(t.recentBlocks, old)
+		}
+		t.mutex.Unlock()
+		t.lastBlock = number
+	}
+	return nil
+}
+
+// getBlockTransactions returns the hashes of the transactions of a block. When the tracker is
+// restricted to some senders, only their transactions are returned, and they're tracked.
+func (t *inclusionTracker) getBlockTransactions(ctx context.Context, rpc *ethrpc.Client, number uint64) (hashes []ethcommon.Hash, tracked bool, err error) {
+	t.mutex.RLock()
+	senders := t.senders
+	t.mutex.RUnlock()
+	if senders == nil {
+		var block inclusionBlock
+		err = rpc.CallContext(ctx, &block, "eth_getBlockByNumber", hexutil.EncodeUint64(number), false)
+		return block.Transactions, false, err
+	}
+
+	var block inclusionFullBlock
+	if err = rpc.CallContext(ctx, &block, "eth_getBlockByNumber", hexutil.EncodeUint64(number), true); err != nil {
+		return nil, true, err
+	}
+	hashes = make([]ethcommon.Hash, 0, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		if _, isSender := senders[tx.From]; isSender {
+			hashes = append(hashes, tx.Hash)
+		}
+	}
+	return hashes, true, nil
+}
+
+// see records a transaction seen in a block. The transactions that aren't tracked yet are
+// kept with the recent blocks.
+func (t *inclusionTracker) see(hash ethcommon.Hash, i inclusion, tracked bool) {
+	if _, seen := t.firstSeen[hash]; seen {
+		return
+	}
+	if _, isPending := t.pending[hash]; isPending || tracked {
+		t.firstSeen[hash] = i
+		delete(t.pending, hash)
+		return
+	}
+	if _, seen := t.recent[hash]; !seen {
+		t.recent[hash] = i
+		t.recentBlocks[i.BlockNumber] = append(t.recentBlocks[i.BlockNumber], hash)
+	}
+}
+
+// track adds a transaction sent by the load test to the transactions whose inclusion is
+// recorded.
+func (t *inclusionTracker) track(hash ethcommon.Hash) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if _, seen := t.firstSeen[hash]; seen {
+		return
+	}
+	if i, seen := t.recent[hash]; seen {
+		t.firstSeen[hash] = i
+		delete(t.recent, hash)
+		return
+	}
+	t.pending[hash] = struct{}{}
+}
+
+// trackSenders restricts the tracked transactions to the ones sent by the given accounts, for
+// when the hashes of the transactions aren't known while they're sent, e.g. on the
+// coordinator of a distributed load test.
+func (t *inclusionTracker) trackSenders(senders []ethcommon.Address) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.senders = make(map[ethcommon.Address]struct{}, len(senders))
+	for _, sender := range senders {
+		t.senders[sender] = struct{}{}
+	}
+}
+
+// trackBlockInclusion records that a transaction that wasn't tracked, e.g. the bundle
+// transaction of a UserOperation sent by a bundler, was included in the given block. It
+// returns when the block was seen.
+func (t *inclusionTracker) trackBlockInclusion(hash ethcommon.Hash, blockNumber uint64) (time.Time, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if i, seen := t.firstSeen[hash]; seen {
+		return i.SeenTime, true
+	}
+	seenTime, seen := t.blockSeen[blockNumber]
+	if !seen {
+		return time.Time{}, false
+	}
+	t.firstSeen[hash] = inclusion{BlockNumber: blockNumber, SeenTime: seenTime}
+	return seenTime, true
+}
+
+// trackInclusion tracks a transaction sent by the load test with the inclusion trackers of
+// the load test and of the endpoints.
+func trackInclusion(hash ethcommon.Hash) {
+	if inclusions != nil {
+		inclusions.track(hash)
+	}
+	if rpcEndpoints != nil {
+		rpcEndpoints.track(hash)
+	}
+}
+
+// stop stops the block watcher once the blocks up to the current head have been processed.
+func (t *inclusionTracker) stop(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) {
+	t.cancel()
+	<-t.done
+	head, err := c.BlockNumber(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get the block number")
+		return
+	}
+	if err = t.catchUp(ctx, rpc, head); err != nil {
+		log.Error().Err(err).Msg("Unable to fetch the last blocks")
+	}
+}
+
+// isIncluded returns true if the transaction has been seen in a block.
+func (t *inclusionTracker) isIncluded(hash ethcommon.Hash) bool {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	_, seen := t.firstSeen[hash]
+	return seen
+}
+
+// getSeenTime returns when the transaction was first seen in a block.
+func (t *inclusionTracker) getSeenTime(hash ethcommon.Hash) (time.Time, bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	i, seen := t.firstSeen[hash]
+	return i.SeenTime, seen
+}
+
+// getInclusionLatency returns how long the transaction of the sample took to be seen in a block.
+// In open loop mode, the latency is measured from the intended send time.
+func (t *inclusionTracker) getInclusionLatency(s loadTestSample) (time.Duration, bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	i, seen := t.firstSeen[s.TxHash]
+	if !seen {
+		return 0, false
+	}
+	return i.SeenTime.Sub(s.getStartTime()), true
+}
+
+// getInclusionLatencies returns the inclusion latency percentiles of the successfully sent
+// transactions for every mode. When several modes are used, the percentiles across all the
+// modes are added at the end.
+func getInclusionLatencies(lts []loadTestSample) []InclusionLatency {
+	if inclusions == nil {
+		return nil
+	}
+	latencies := make(map[string][]float64)
+	missing := make(map[string]int)
+	for _, s := range lts {
+		if s.IsError || s.TxHash == (ethcommon.Hash{}) {
+			continue
+		}
+		mode := s.Mode.String()
+		latency, included := inclusions.getInclusionLatency(s)
+		if !included {
+			missing[mode] += 1
+			continue
+		}
+		latencies[mode] = append(latencies[mode], latency.Seconds())
+	}
+
+	modes := make([]string, 0)
+	for mode := range latencies {
+		modes = append(modes, mode)
+	}
+	for mode := range missing {
+		if _, hasLatencies := latencies[mode]; !hasLatencies {
+			modes = append(modes, mode)
+		}
+	}
+	sort.Strings(modes)
+
+	inclusionLatencies := make([]InclusionLatency, 0, len(modes)+1)
+	allLatencies := make([]float64, 0)
+	var allMissing int
+	for _, mode := range modes {
+		inclusionLatencies = append(inclusionLatencies, newInclusionLatency(mode, latencies[mode], missing[mode]))
+		allLatencies = append(allLatencies, latencies[mode]...)
+		allMissing += missing[mode]
+	}
+	if len(modes) > 1 {
+		inclusionLatencies = append(inclusionLatencies, newInclusionLatency("all", allLatencies, allMissing))
+	}
+	return inclusionLatencies
+}
+
+func newInclusionLatency(mode string, latencies []float64, missing int) InclusionLatency {
+	il := InclusionLatency{
+		Mode:     mode,
+		Included: len(latencies),
+		Missing:  missing,
+	}
+	il.P50, _ = stats.Percentile(latencies, 50)
+	il.P90, _ = stats.Percentile(latencies, 90)
+	il.P99, _ = stats.Percentile(latencies, 99)
+	il.P999, _ = stats.Percentile(latencies, 99.9)
+	return il
+}
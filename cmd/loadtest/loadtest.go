@@ -2,6 +2,7 @@ package loadtest
 
 import (
 	"context"
+	"crypto/ecdsa"
 	_ "embed"
 	"encoding/hex"
 	"encoding/json"
@@ -31,6 +32,7 @@ import (
 	"github.com/0xPolygon/polygon-cli/util"
 
 	ethereum "github.com/ethereum/go-ethereum"
+	gethabi "github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
@@ -55,21 +57,28 @@ const (
 	// You can also use `make gen-loadtest-modes`.
 	loadTestModeERC20 loadTestMode = iota
 	loadTestModeERC721
+	loadTestModeAccessList
 	loadTestModeBlob
+	loadTestModeBridge
 	loadTestModeCall
 	loadTestModeContractCall
 	loadTestModeDeploy
+	loadTestModeDeployStress
 	loadTestModeFunction
 	loadTestModeInscription
 	loadTestModeIncrement
+	loadTestModeMempool
 	loadTestModeRandomPrecompiledContract
 	loadTestModeSpecificPrecompiledContract
 	loadTestModeRandom
 	loadTestModeRecall
 	loadTestModeRPC
+	loadTestModeSetCode
+	loadTestModeStateGrowth
 	loadTestModeStore
 	loadTestModeTransaction
 	loadTestModeUniswapV3
+	loadTestModeUserOp
 
 	codeQualitySeed       = "code code code code code code code code code code code quality"
 	codeQualityPrivateKey = "42b6e34dc21598a807dc19d7784c71b2a7a01f6480dc6f58258f78e539f1a1fa"
@@ -81,20 +90,28 @@ func characterToLoadTestMode(mode string) (loadTestMode, error) {
 		return loadTestModeERC20, nil
 	case "7", "erc721":
 		return loadTestModeERC721, nil
+	case "al", "access-list":
+		return loadTestModeAccessList, nil
 	case "b", "blob":
 		return loadTestModeBlob, nil
+	case "br", "bridge":
+		return loadTestModeBridge, nil
 	case "c", "call":
 		return loadTestModeCall, nil
 	case "cc", "contract-call":
 		return loadTestModeContractCall, nil
 	case "d", "deploy":
 		return loadTestModeDeploy, nil
+	case "ds", "deploy-stress":
+		return loadTestModeDeployStress, nil
 	case "f", "function":
 		return loadTestModeFunction, nil
 	case "i", "inscription":
 		return loadTestModeInscription, nil
 	case "inc", "increment":
 		return loadTestModeIncrement, nil
+	case "mp", "mempool":
+		return loadTestModeMempool, nil
 	case "pr", "random-precompile":
 		return loadTestModeRandomPrecompiledContract, nil
 	case "px", "specific-precompile":
@@ -105,46 +122,61 @@ func characterToLoadTestMode(mode string) (loadTestMode, error) {
 		return loadTestModeRecall, nil
 	case "rpc":
 		return loadTestModeRPC, nil
+	case "sc", "set-code":
+		return loadTestModeSetCode, nil
+	case "sg", "state-growth":
+		return loadTestModeStateGrowth, nil
 	case "s", "store":
 		return loadTestModeStore, nil
 	case "t", "transaction":
 		return loadTestModeTransaction, nil
 	case "v3", "uniswapv3":
 		return loadTestModeUniswapV3, nil
+	case "uo", "userop":
+		return loadTestModeUserOp, nil
 	default:
 		return 0, fmt.Errorf("unrecognized load test mode: %s", mode)
 	}
 }
 
 func getRandomMode() loadTestMode {
-	// Does not include the following modes: blob, call, inscription, recall, rpc, uniswapv3
+	// Does not include the following modes: access-list, blob, bridge, call, deploy-stress, inscription, mempool, recall, rpc, set-code, state-growth, uniswapv3, userop
 	modes := []loadTestMode{
 		loadTestModeERC20,
 		loadTestModeERC721,
+		// loadTestModeAccessList,
 		// loadTestModeBlob,
+		// loadTestModeBridge,
 		// loadTestModeCall,
 		loadTestModeContractCall,
 		loadTestModeDeploy,
+		// loadTestModeDeployStress,
 		loadTestModeFunction,
 		// loadTestModeInscription,
 		loadTestModeIncrement,
+		// loadTestModeMempool,
 		loadTestModeRandomPrecompiledContract,
 		loadTestModeSpecificPrecompiledContract,
 		// loadTestModeRandom,
 		// loadTestModeRecall,
 		// loadTestModeRPC,
+		// loadTestModeSetCode,
+		// loadTestModeStateGrowth,
 		loadTestModeStore,
 		loadTestModeTransaction,
 		// loadTestModeUniswapV3,
+		// loadTestModeUserOp,
 	}
 	return modes[randSrc.Intn(len(modes))]
 }
 
 func modeRequiresLoadTestContract(m loadTestMode) bool {
-	if m == loadTestModeCall ||
+	if m == loadTestModeAccessList ||
+		m == loadTestModeCall ||
 		m == loadTestModeFunction ||
 		m == loadTestModeIncrement ||
 		m == loadTestModeRandom ||
+		m == loadTestModeSetCode ||
 		m == loadTestModeStore ||
 		m == loadTestModeRandomPrecompiledContract ||
 		m == loadTestModeSpecificPrecompiledContract {
@@ -258,8 +290,8 @@ func initializeLoadTestParams(ctx context.Context, c *ethclient.Client) error {
 		return errors.New("max priority fee per gas higher than max fee per gas")
 	}
 
-	if *inputLoadTestParams.AdaptiveRateLimit && *inputLoadTestParams.CallOnly {
-		return errors.New("the adaptive rate limit is based on the pending transaction pool. It doesn't use this feature while also using call only")
+	if *inputLoadTestParams.AdaptiveRateLimit && *inputLoadTestParams.CallOnly && *inputLoadTestParams.AdaptiveTarget != adaptiveTargetRPCLatency {
+		return errors.New("the adaptive rate limit is based on the pending transaction pool or on the blocks. It can only target the rpc latency while also using call only")
 	}
 
 	contractAddr := ethcommon.HexToAddress(*inputLoadTestParams.ContractAddress)
@@ -290,15 +322,20 @@ func initializeLoadTestParams(ctx context.Context, c *ethclient.Client) error {
 		inputLoadTestParams.ParsedModes = append(inputLoadTestParams.ParsedModes, parsedMode)
 	}
 
+	// When a scenario is used, the checks below apply to every mode of the scenario.
+	if loadTestScenarioPlan != nil {
+		inputLoadTestParams.ParsedModes = loadTestScenarioPlan.getModes()
+	}
+
 	// Logic checking input parameters for specific conditions such as multiple inputs.
-	if len(modes) > 1 {
+	if len(inputLoadTestParams.ParsedModes) > 1 {
 		inputLoadTestParams.MultiMode = true
 		if !hasUniqueModes(inputLoadTestParams.ParsedModes) {
 			return errors.New("Duplicate modes detected, check input modes for duplicates")
 		}
 	} else {
 		inputLoadTestParams.MultiMode = false
-		inputLoadTestParams.Mode, _ = characterToLoadTestMode((*inputLoadTestParams.Modes)[0])
+		inputLoadTestParams.Mode = inputLoadTestParams.ParsedModes[0]
 	}
 	if hasMode(loadTestModeRandom, inputLoadTestParams.ParsedModes) && inputLoadTestParams.MultiMode {
 		return errors.New("random mode can't be used in combinations with any other modes")
@@ -309,15 +346,35 @@ func initializeLoadTestParams(ctx context.Context, c *ethclient.Client) error {
 		log.Trace().Msg("Setting call only mode since we're doing RPC testing")
 		*inputLoadTestParams.CallOnly = true
 	}
-	if hasMode(loadTestModeContractCall, inputLoadTestParams.ParsedModes) && (*inputLoadTestParams.ContractAddress == "" || (*inputLoadTestParams.ContractCallData == "" && *inputLoadTestParams.ContractCallFunctionSignature == "")) {
-		return errors.New("`--contract-call` requires both a `--contract-address` and calldata, either with `--calldata` or `--function-signature --function-arg` flags.")
+	if hasMode(loadTestModeContractCall, inputLoadTestParams.ParsedModes) && (*inputLoadTestParams.ContractAddress == "" || (*inputLoadTestParams.ContractCallData == "" && *inputLoadTestParams.ContractCallFunctionSignature == "" && *inputLoadTestParams.ContractABI == "")) {
+		return errors.New("`--contract-call` requires both a `--contract-address` and calldata, either with `--calldata`, `--function-signature --function-arg` or `--contract-abi` flags.")
+	}
+	if *inputLoadTestParams.ContractABI != "" && (*inputLoadTestParams.ContractCallData != "" || *inputLoadTestParams.ContractCallFunctionSignature != "") {
+		return errors.New("`--contract-abi` can't be used along with `--calldata` or `--function-signature`")
 	}
-	if *inputLoadTestParams.CallOnly && *inputLoadTestParams.AdaptiveRateLimit {
+	if *inputLoadTestParams.CallOnly && *inputLoadTestParams.AdaptiveRateLimit && *inputLoadTestParams.AdaptiveTarget != adaptiveTargetRPCLatency {
 		return errors.New("using call only with adaptive rate limit doesn't make sense")
 	}
 	if hasMode(loadTestModeBlob, inputLoadTestParams.ParsedModes) && inputLoadTestParams.MultiMode {
 		return errors.New("Blob mode should only be used by itself. Blob mode will take significantly longer than other transactions to finalize, and the address will be reserved, preventing other transactions form being made.")
 	}
+	if hasMode(loadTestModeUniswapV3, inputLoadTestParams.ParsedModes) && (*inputLoadTestParams.SendingAccounts > 1 || *inputLoadTestParams.SendingAccountsFile != "") {
+		return errors.New("uniswapv3 mode only supports a single sending account")
+	}
+	if hasMode(loadTestModeBridge, inputLoadTestParams.ParsedModes) {
+		if !ethcommon.IsHexAddress(*inputLoadTestParams.BridgeAddress) {
+			return errors.New("bridge mode requires the hex address of the bridge contract with `--bridge-address`")
+		}
+		if len(*inputLoadTestParams.BridgeDestinationNetworks) == 0 {
+			return errors.New("bridge mode requires at least one destination network with `--bridge-destination-networks`")
+		}
+	}
+
+	if hasMode(loadTestModeUserOp, inputLoadTestParams.ParsedModes) {
+		if err = checkUserOpContracts(ctx, c); err != nil {
+			return err
+		}
+	}
 
 	randSrc = rand.New(rand.NewSource(*inputLoadTestParams.Seed))
 
@@ -325,9 +382,6 @@ func initializeLoadTestParams(ctx context.Context, c *ethclient.Client) error {
 }
 
 func initNonce(ctx context.Context, c *ethclient.Client) error {
-	currentNonceMutex.Lock()
-	defer currentNonceMutex.Unlock()
-
 	var err error
 	startBlockNumber, err = c.BlockNumber(ctx)
 	if err != nil {
@@ -335,71 +389,118 @@ func initNonce(ctx context.Context, c *ethclient.Client) error {
 		return err
 	}
 
-	// Get pending nonce to be prevent nonce collision (if tx from same sender is already present)
-	currentNonce, err = c.PendingNonceAt(ctx, *inputLoadTestParams.FromETHAddress)
-	if err != nil {
-		log.Error().Err(err).Msg("Unable to get account nonce")
-		return err
+	var startNonce uint64
+	if inputLoadTestParams.StartNonce != nil {
+		startNonce = *inputLoadTestParams.StartNonce
 	}
-
-	if inputLoadTestParams.StartNonce != nil && *inputLoadTestParams.StartNonce > 0 {
-		currentNonce = *inputLoadTestParams.StartNonce
+	for _, account := range sendingAccounts {
+		if err = account.initNonce(ctx, c, startNonce); err != nil {
+			return err
+		}
+		log.Info().Stringer("address", account.Address).Uint64("startNonce", account.StartNonce).Msg("setting the starting nonce")
 	}
 
-	log.Info().Uint64("startNonce", startNonce).Msg("setting the starting nonce")
-
-	startNonce = currentNonce
 	return nil
 }
 
 func completeLoadTest(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) error {
-	log.Debug().Uint64("startNonce", startNonce).Uint64("lastNonce", currentNonce).Msg("Finished main load test loop")
+	transactionsSent := getSentTransactionCount()
+	log.Debug().Uint64("transactionsSent", transactionsSent).Int("sendingAccounts", len(sendingAccounts)).Msg("Finished main load test loop")
 	if *inputLoadTestParams.SendOnly {
-		log.Info().Uint64("transactionsSent", currentNonce-startNonce).Msg("SendOnly mode enabled - skipping wait period and summarization")
+		log.Info().Uint64("transactionsSent", transactionsSent).Msg("SendOnly mode enabled - skipping wait period and summarization")
 		return nil
 	}
 	log.Debug().Msg("Waiting for remaining transactions to be completed and mined")
 
 	var err error
-	finalBlockNumber, err = waitForFinalBlock(ctx, c, rpc, startBlockNumber, startNonce, currentNonce)
+	finalBlockNumber, err = waitForFinalBlock(ctx, c, rpc, startBlockNumber)
 	if err != nil {
 		log.Error().Err(err).Msg("There was an issue waiting for all transactions to be mined")
 	}
+	return finishLoadTest(ctx, c, rpc)
+}
+
+// finishLoadTest stops the trackers, resolves the results of the modes, then summarizes and
+// saves the load test. It's called once the final block is known, whether the load test ran to
+// completion or was stopped.
+func finishLoadTest(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) error {
+	if inclusions != nil {
+		inclusions.stop(ctx, c, rpc)
+	}
+	if rpcEndpoints != nil {
+		rpcEndpoints.stopTrackers(ctx)
+	}
+	if userOps != nil {
+		userOps.resolve(ctx, c)
+	}
+	if stateGrowth != nil {
+		stateGrowth.resolve(ctx, c, rpc)
+	}
+	if blobs != nil {
+		blobs.resolve(ctx, c, rpc)
+	}
+	if bridgeDeposits != nil {
+		bridgeDeposits.resolve(ctx, c)
+	}
+	if deployStress != nil {
+		deployStress.resolve(ctx, c)
+	}
 	if len(loadTestResults) == 0 {
 		return errors.New("no transactions observed")
 	}
 
 	startTime := loadTestResults[0].RequestTime
 	endTime := time.Now()
-	log.Debug().Uint64("currentNonce", currentNonce).Uint64("final block number", finalBlockNumber).Msg("Got final block number")
+	log.Debug().Uint64("final block number", finalBlockNumber).Msg("Got final block number")
 
 	if *inputLoadTestParams.CallOnly {
 		log.Info().Msg("CallOnly mode enabled - blocks aren't mined")
 		lightSummary(loadTestResults, startTime, endTime, rl)
+		saveRunResult(ctx, c, rpc, startTime, endTime)
 		return nil
 	}
 
 	if *inputLoadTestParams.ShouldProduceSummary {
-		err = summarizeTransactions(ctx, c, rpc, startBlockNumber, startNonce, finalBlockNumber, currentNonce)
-		if err != nil {
+		if err := summarizeTransactions(ctx, c, rpc, startBlockNumber, finalBlockNumber); err != nil {
 			log.Error().Err(err).Msg("There was an issue creating the load test summary")
 		}
 	}
 	lightSummary(loadTestResults, startTime, endTime, rl)
+	saveRunResult(ctx, c, rpc, startTime, endTime)
 
 	return nil
 }
 
+// saveRunResult writes the results of the run if a results directory was given.
+func saveRunResult(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client, startTime, endTime time.Time) {
+	if *inputLoadTestParams.ResultsDir == "" {
+		return
+	}
+	if err := writeRunResult(ctx, c, rpc, loadTestResults, startTime, endTime); err != nil {
+		log.Error().Err(err).Msg("Unable to save the run results")
+	}
+}
+
 // runLoadTest initiates and runs the entire load test process, including initialization,
 // the main load test loop, and the completion steps. It takes a context for cancellation signals.
 // The function returns an error if there are issues during the load test process.
 func runLoadTest(ctx context.Context) error {
 	log.Info().Msg("Starting Load Test")
 
-	// Configure the overall time limit for the load test.
+	// A worker of a distributed load test gets its settings from the coordinator.
+	var assignment *workerAssignment
+	if *inputLoadTestParams.Worker != "" {
+		var err error
+		if assignment, err = joinCoordinator(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Configure the overall time limit for the load test. The coordinator of a distributed load
+	// test waits for the results of the workers instead.
 	timeLimit := *inputLoadTestParams.TimeLimit
 	var overallTimer *time.Timer
-	if timeLimit > 0 {
+	if timeLimit > 0 && *inputLoadTestParams.Coordinator == "" {
 		overallTimer = time.NewTimer(time.Duration(timeLimit) * time.Second)
 	} else {
 		overallTimer = new(time.Timer)
@@ -407,6 +508,9 @@ func runLoadTest(ctx context.Context) error {
 
 	// connLimit is the value we'll use to configure the connection limit within the http transport
 	connLimit := 2 * int(*inputLoadTestParams.Concurrency)
+	if loadTestScenarioPlan != nil {
+		connLimit = 2 * int(loadTestScenarioPlan.getMaxConcurrency())
+	}
 	// Most of these transport options are defaults. We might want to make this configurable from the CLI at some point.
 	// The goal here is to avoid opening a ton of connections that go idle then get closed and eventually exhausting
 	// client-side connections.
@@ -418,6 +522,29 @@ func runLoadTest(ctx context.Context) error {
 	goHttpClient := &http.Client{
 		Transport: transport,
 	}
+	if *inputLoadTestParams.RecordFile != "" {
+		var err error
+		recorder, err = newTxRecorder(*inputLoadTestParams.RecordFile)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to create the record file")
+			return err
+		}
+		defer func() {
+			if closeErr := recorder.close(); closeErr != nil {
+				log.Error().Err(closeErr).Msg("Unable to close the record file")
+			}
+		}()
+		goHttpClient.Transport = &recordingTransport{base: transport, recorder: recorder}
+	}
+	if len(*inputLoadTestParams.RPCURLs) > 0 {
+		var err error
+		rpcEndpoints, err = newRPCEndpointsTransport(goHttpClient.Transport, &http.Client{Transport: transport})
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to parse the rpc urls")
+			return err
+		}
+		goHttpClient.Transport = rpcEndpoints
+	}
 	rpcOption := ethrpc.WithHTTPClient(goHttpClient)
 	rpc, err := ethrpc.DialOptions(ctx, *inputLoadTestParams.RPCUrl, rpcOption)
 	if err != nil {
@@ -428,6 +555,10 @@ func runLoadTest(ctx context.Context) error {
 	rpc.SetHeader("Accept-Encoding", "identity")
 	ec := ethclient.NewClient(rpc)
 
+	if *inputLoadTestParams.PrometheusPort > 0 {
+		startMetricsServer(*inputLoadTestParams.PrometheusPort)
+	}
+
 	// Define the main loop function.
 	// Make sure to define any logic associated to the load test (initialization, main load test loop
 	// or completion steps) in this function in order to handle cancellation signals properly.
@@ -437,10 +568,18 @@ func runLoadTest(ctx context.Context) error {
 			return err
 		}
 
+		if *inputLoadTestParams.Coordinator != "" {
+			return runCoordinator(ctx, ec, rpc)
+		}
+
 		if err = mainLoop(ctx, ec, rpc); err != nil {
 			log.Error().Err(err).Msg("Error during the main load test loop")
 			return err
 		}
+		// The load test was stopped, and it's drained and summarized by stopLoadTest.
+		if ctx.Err() != nil {
+			return nil
+		}
 
 		if err = completeLoadTest(ctx, ec, rpc); err != nil {
 			log.Error().Err(err).Msg("Encountered error while wrapping up loadtest")
@@ -470,40 +609,66 @@ func runLoadTest(ctx context.Context) error {
 	select {
 	case <-overallTimer.C:
 		log.Info().Msg("Time's up")
+		stopLoadTest(ec, rpc, cancel)
 	case <-sigCh:
 		log.Info().Msg("Interrupted.. Stopping load test")
-		if *inputLoadTestParams.ShouldProduceSummary {
-			finalBlockNumber, err = ec.BlockNumber(ctx)
-			if err != nil {
-				log.Error().Err(err).Msg("Unable to retrieve final block number")
-			}
-			err = summarizeTransactions(ctx, ec, rpc, startBlockNumber, startNonce, finalBlockNumber, currentNonce)
-			if err != nil {
-				log.Error().Err(err).Msg("There was an issue creating the load test summary")
-			}
-		} else {
-			if len(loadTestResults) > 0 {
-				lightSummary(loadTestResults, loadTestResults[0].RequestTime, time.Now(), rl)
+		if *inputLoadTestParams.Coordinator != "" {
+			// The coordinator summarizes the results it already received.
+			cancel()
+			if err = <-errCh; err != nil {
+				log.Error().Err(err).Msg("Encountered error while wrapping up loadtest")
 			}
+			break
 		}
-		cancel()
+		stopLoadTest(ec, rpc, cancel)
 	case err = <-errCh:
 		if err != nil {
 			log.Fatal().Err(err).Msg("Received critical error while running load test")
 		}
 	}
+	if assignment != nil {
+		reportCtx, reportCancel := context.WithTimeout(context.Background(), time.Minute)
+		defer reportCancel()
+		if err = reportToCoordinator(reportCtx, assignment); err != nil {
+			log.Error().Err(err).Msg("Unable to send the results to the coordinator")
+		}
+	}
 	log.Info().Msg("Finished")
 	return nil
 }
 
-func updateRateLimit(ctx context.Context, rl *rate.Limiter, rpc *ethrpc.Client, nonceGetter func() (uint64, error), steadyStateQueueSize uint64, rateLimitIncrement uint64, cycleDuration time.Duration, backoff float64) {
+// stopLoadTest stops sending requests, waits up to `--drain-timeout` for the transactions that
+// were already sent, and wraps up the load test so far like a completed one.
+func stopLoadTest(ec *ethclient.Client, rpc *ethrpc.Client, cancel context.CancelFunc) {
+	cancel()
+	ctx := context.Background()
+	ltp := inputLoadTestParams
+
+	var drainSummary *DrainSummary
+	if !*ltp.CallOnly && !*ltp.SendOnly && *ltp.DrainTimeout > 0 {
+		drainCtx, drainCancel := context.WithTimeout(ctx, time.Duration(*ltp.DrainTimeout)*time.Second)
+		drainSummary = drainTransactions(drainCtx, rpc)
+		drainCancel()
+	}
+
+	var err error
+	finalBlockNumber, err = ec.BlockNumber(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to retrieve final block number")
+	}
+	if err = finishLoadTest(ctx, ec, rpc); err != nil {
+		log.Error().Err(err).Msg("Encountered error while wrapping up loadtest")
+	}
+	drainLightSummary(drainSummary)
+}
+
+func updateRateLimit(ctx context.Context, rl *rate.Limiter, rpc *ethrpc.Client, nonceLagGetter func() (uint64, error), steadyStateQueueSize uint64, rateLimitIncrement uint64, cycleDuration time.Duration, backoff float64) {
 	tryTxPool := true
 	ticker := time.NewTicker(cycleDuration)
 	defer ticker.Stop()
 	for {
 		select {
 		case <-ticker.C:
-			var adaptiveNonce uint64
 			var txPoolSize uint64
 			var err error
 			var pendingTx uint64
@@ -516,18 +681,11 @@ func updateRateLimit(ctx context.Context, rl *rate.Limiter, rpc *ethrpc.Client,
 			if err != nil {
 				tryTxPool = false
 				log.Warn().Err(err).Msg("Error getting txpool size. Falling back to latest nonce and disabling txpool check")
-				adaptiveNonce, err = nonceGetter()
+				txPoolSize, err = nonceLagGetter()
 				if err != nil {
 					log.Error().Err(err).Msg("Error getting nonce from rpc")
 					return
 				}
-				currentNonceMutex.RLock()
-				if currentNonce < adaptiveNonce {
-					txPoolSize = 0
-				} else {
-					txPoolSize = currentNonce - adaptiveNonce
-				}
-				currentNonceMutex.RUnlock()
 			} else {
 				txPoolSize = pendingTx + queuedTx
 			}
@@ -552,8 +710,6 @@ func mainLoop(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) erro
 	ltp := inputLoadTestParams
 	log.Trace().Interface("Input Params", ltp).Msg("Params")
 
-	routines := *ltp.Concurrency
-	requests := *ltp.Requests
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
 	privateKey := ltp.ECDSAPrivateKey
 	mode := ltp.Mode
@@ -563,16 +719,24 @@ func mainLoop(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) erro
 	if *ltp.RateLimit <= 0.0 {
 		rl = nil
 	}
+	if loadTestScenarioPlan != nil {
+		// The rate limit of every phase is applied when the phase starts.
+		rl = rate.NewLimiter(rate.Inf, 1)
+	}
 	rateLimitCtx, cancel := context.WithCancel(ctx)
 
-	nonceGetter := func(ec *ethclient.Client, fromAddress ethcommon.Address) func() (uint64, error) {
-		return func() (uint64, error) {
-			return ec.NonceAt(ctx, fromAddress, nil)
-		}
-	}(c, *ltp.FromETHAddress)
+	nonceLagGetter := func() (uint64, error) {
+		return getSendingAccountsNonceLag(ctx, c, nil)
+	}
 	defer cancel()
 	if *ltp.AdaptiveRateLimit && rl != nil {
-		go updateRateLimit(rateLimitCtx, rl, rpc, nonceGetter, steadyStateTxPoolSize, adaptiveRateLimitIncrement, time.Duration(*ltp.AdaptiveCycleDuration)*time.Second, *ltp.AdaptiveBackoffFactor)
+		cycleDuration := time.Duration(*ltp.AdaptiveCycleDuration) * time.Second
+		if *ltp.AdaptiveTarget == adaptiveTargetTxPool {
+			go updateRateLimit(rateLimitCtx, rl, rpc, nonceLagGetter, steadyStateTxPoolSize, adaptiveRateLimitIncrement, cycleDuration, *ltp.AdaptiveBackoffFactor)
+		} else {
+			rateControl = newRateController()
+			go rateControl.run(rateLimitCtx, c, rl, cycleDuration)
+		}
 	}
 
 	tops, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
@@ -589,6 +753,11 @@ func mainLoop(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) erro
 	}
 	cops := new(bind.CallOpts)
 
+	if err = initSendingAccounts(ctx, c, tops); err != nil {
+		log.Error().Err(err).Msg("Unable to initialize the sending accounts")
+		return err
+	}
+
 	// deploy and instantiate the load tester contract
 	var ltAddr ethcommon.Address
 	var ltContract *tester.LoadTester
@@ -608,6 +777,9 @@ func mainLoop(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) erro
 			return err
 		}
 		log.Debug().Str("erc20Addr", erc20Addr.String()).Msg("Obtained erc 20 contract address")
+		if err = mintERC20ForSendingAccounts(ctx, c, erc20Contract); err != nil {
+			return err
+		}
 	}
 
 	var erc721Addr ethcommon.Address
@@ -656,8 +828,16 @@ func mainLoop(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) erro
 			Msg("Retrieved recent indexed activity")
 	}
 
+	if hasMode(loadTestModeContractCall, ltp.ParsedModes) && *ltp.ContractABI != "" {
+		contractCalls, err = newABICallGenerator(*ltp.ContractABI, *ltp.ContractCallSpec)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to load the contract ABI")
+			return err
+		}
+	}
+
 	var uniswapV3Config uniswapv3loadtest.UniswapV3Config
-	var poolConfig uniswapv3loadtest.PoolConfig
+	var uniswapV3Pools []uniswapv3loadtest.PoolConfig
 	if hasMode(loadTestModeUniswapV3, ltp.ParsedModes) {
 		uniswapAddresses := uniswapv3loadtest.UniswapV3Addresses{
 			FactoryV3:                          ethcommon.HexToAddress(*uniswapv3LoadTestParams.UniswapFactoryV3),
@@ -674,7 +854,61 @@ func mainLoop(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) erro
 			SwapRouter02:                       ethcommon.HexToAddress(*uniswapv3LoadTestParams.UniswapSwapRouter),
 			WETH9:                              ethcommon.HexToAddress(*uniswapv3LoadTestParams.WETH9),
 		}
-		uniswapV3Config, poolConfig, err = initUniswapV3Loadtest(ctx, c, tops, cops, uniswapAddresses, *ltp.FromETHAddress)
+		uniswapV3Config, uniswapV3Pools, err = initUniswapV3Loadtest(ctx, c, tops, cops, uniswapAddresses, *ltp.FromETHAddress)
+		if err != nil {
+			return err
+		}
+	}
+
+	if hasMode(loadTestModeUserOp, ltp.ParsedModes) {
+		userOps, err = initUserOpLoadTest(ctx, c, tops)
+		if err != nil {
+			return err
+		}
+	}
+
+	if hasMode(loadTestModeSetCode, ltp.ParsedModes) {
+		setCodeAuthorities, err = initSetCodeLoadTest(ctx, c, tops, ltAddr)
+		if err != nil {
+			return err
+		}
+	}
+
+	if hasMode(loadTestModeStateGrowth, ltp.ParsedModes) {
+		stateGrowth, err = initStateGrowthLoadTest(ctx, c, tops)
+		if err != nil {
+			return err
+		}
+	}
+
+	if hasMode(loadTestModeBlob, ltp.ParsedModes) {
+		blobs, err = newBlobLoadTest(*ltp.BlobCounts)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to parse the blob counts")
+			return err
+		}
+	}
+
+	if hasMode(loadTestModeBridge, ltp.ParsedModes) {
+		bridgeDeposits, err = initBridgeLoadTest(ctx, c, tops, cops, erc20Contract, erc20Addr)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to set up the bridge deposits")
+			return err
+		}
+	}
+
+	if hasMode(loadTestModeDeployStress, ltp.ParsedModes) {
+		deployStress, err = initDeployStressLoadTest(ctx, c, tops)
+		if err != nil {
+			return err
+		}
+	}
+
+	if hasMode(loadTestModeRandomPrecompiledContract, ltp.ParsedModes) || hasMode(loadTestModeRandom, ltp.ParsedModes) {
+		precompiledContracts = getActivePrecompiledContracts(ctx, c, ltp.ECDSAPrivateKey)
+	}
+	if hasMode(loadTestModeSpecificPrecompiledContract, ltp.ParsedModes) || hasMode(loadTestModeRandomPrecompiledContract, ltp.ParsedModes) || hasMode(loadTestModeRandom, ltp.ParsedModes) {
+		precompileCaller, err = getPrecompileCaller(ctx, c, tops)
 		if err != nil {
 			return err
 		}
@@ -685,115 +919,162 @@ func mainLoop(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) erro
 	if err != nil {
 		return err
 	}
-	log.Debug().Uint64("currentNonce", currentNonce).Msg("Starting main load test loop")
-	var wg sync.WaitGroup
-	for i = 0; i < routines; i = i + 1 {
-		log.Trace().Int64("routine", i).Msg("Starting Thread")
-		wg.Add(1)
-		go func(i int64) {
-			var j int64
-			var startReq time.Time
-			var endReq time.Time
-			var retryForNonce bool = false
-			var myNonceValue uint64
-			var tErr error
-			var ltTxHash ethcommon.Hash
-			for j = 0; j < requests; j = j + 1 {
-				if rl != nil {
-					tErr = rl.Wait(ctx)
-					if tErr != nil {
-						log.Error().Err(tErr).Msg("Encountered a rate limiting error")
-					}
-				}
-
-				if retryForNonce {
-					retryForNonce = false
-				} else {
-					currentNonceMutex.Lock()
-					myNonceValue = currentNonce
-					currentNonce = currentNonce + 1
-					currentNonceMutex.Unlock()
-				}
-
-				localMode := mode
-				// if there are multiple modes, iterate through them, 'r' mode is supported here
-				if ltp.MultiMode {
-					localMode = ltp.ParsedModes[int(i+j)%(len(ltp.ParsedModes))]
-				}
-				// if we're doing random, we'll just pick one based on the current index
-				if localMode == loadTestModeRandom {
-					localMode = getRandomMode()
-				}
-				switch localMode {
-				case loadTestModeERC20:
-					startReq, endReq, ltTxHash, tErr = loadTestERC20(ctx, c, myNonceValue, erc20Contract, ltAddr)
-				case loadTestModeERC721:
-					startReq, endReq, ltTxHash, tErr = loadTestERC721(ctx, c, myNonceValue, erc721Contract, ltAddr)
-				case loadTestModeBlob:
-					startReq, endReq, ltTxHash, tErr = loadTestBlob(ctx, c, myNonceValue)
-				case loadTestModeContractCall:
-					startReq, endReq, ltTxHash, tErr = loadTestContractCall(ctx, c, myNonceValue)
-				case loadTestModeDeploy:
-					startReq, endReq, ltTxHash, tErr = loadTestDeploy(ctx, c, myNonceValue)
-				case loadTestModeFunction, loadTestModeCall:
-					startReq, endReq, ltTxHash, tErr = loadTestFunction(ctx, c, myNonceValue, ltContract)
-				case loadTestModeInscription:
-					startReq, endReq, ltTxHash, tErr = loadTestInscription(ctx, c, myNonceValue)
-				case loadTestModeIncrement:
-					startReq, endReq, ltTxHash, tErr = loadTestIncrement(ctx, c, myNonceValue, ltContract)
-				case loadTestModeRandomPrecompiledContract:
-					startReq, endReq, ltTxHash, tErr = loadTestCallPrecompiledContract(ctx, c, myNonceValue, ltContract, false)
-				case loadTestModeSpecificPrecompiledContract:
-					startReq, endReq, ltTxHash, tErr = loadTestCallPrecompiledContract(ctx, c, myNonceValue, ltContract, true)
-				case loadTestModeRecall:
-					startReq, endReq, ltTxHash, tErr = loadTestRecall(ctx, c, myNonceValue, recallTransactions[int(currentNonce)%len(recallTransactions)])
-				case loadTestModeRPC:
-					startReq, endReq, tErr = loadTestRPC(ctx, c, myNonceValue, indexedActivity)
-				case loadTestModeStore:
-					startReq, endReq, ltTxHash, tErr = loadTestStore(ctx, c, myNonceValue, ltContract)
-				case loadTestModeTransaction:
-					startReq, endReq, ltTxHash, tErr = loadTestTransaction(ctx, c, myNonceValue)
-				case loadTestModeUniswapV3:
-					swapAmountIn := big.NewInt(int64(*uniswapv3LoadTestParams.SwapAmountInput))
-					startReq, endReq, ltTxHash, tErr = runUniswapV3Loadtest(ctx, c, myNonceValue, uniswapV3Config, poolConfig, swapAmountIn)
-				default:
-					log.Error().Str("mode", mode.String()).Msg("We've arrived at a load test mode that we don't recognize")
-				}
-				recordSample(i, j, tErr, startReq, endReq, myNonceValue)
-				if tErr != nil {
-					log.Error().Err(tErr).Uint64("nonce", myNonceValue).Int64("request time", endReq.Sub(startReq).Milliseconds()).Msg("Recorded an error while sending transactions")
-					// The nonce is used to index the recalled transactions in call-only mode. We don't want to retry a transaction if it legit failed on the chain
-					if !*ltp.CallOnly {
-						retryForNonce = true
+	if !*ltp.CallOnly {
+		inclusions = startInclusionTracker(ctx, c, rpc, startBlockNumber)
+		if rpcEndpoints != nil {
+			if err = rpcEndpoints.startTrackers(ctx, startBlockNumber); err != nil {
+				return err
+			}
+		}
+	}
+	if ltMetrics != nil {
+		go pollMetrics(ctx, c, rpc)
+	}
+	log.Debug().Int("sendingAccounts", len(sendingAccounts)).Msg("Starting main load test loop")
+	if recorder != nil {
+		recorder.startLoad(time.Now())
+	}
+	for _, phase := range getLoadTestPhases() {
+		startLoadTestPhase(phase)
+		phaseCtx, phaseCancel := phase.getContext(ctx)
+		var arrivals <-chan time.Time
+		if isOpenLoop() {
+			arrivals = scheduleArrivals(phaseCtx, phase.Requests*phase.Concurrency, phase.Concurrency)
+		}
+		var wg sync.WaitGroup
+		for i = 0; i < phase.Concurrency; i = i + 1 {
+			log.Trace().Int64("routine", i).Msg("Starting Thread")
+			wg.Add(1)
+			go func(i int64) {
+				var j int64
+				var startReq time.Time
+				var endReq time.Time
+				var retryForNonce bool = false
+				var myNonceValue uint64
+				var tErr error
+				var ltTxHash ethcommon.Hash
+				var intendedTime time.Time
+				account := getSendingAccount(i)
+				// in open loop mode, the scheduler decides how many requests are sent
+				for j = 0; arrivals != nil || phase.Requests == 0 || j < phase.Requests; j = j + 1 {
+					if phaseCtx.Err() != nil {
+						break
 					}
-					if strings.Contains(tErr.Error(), "replacement transaction underpriced") && retryForNonce {
-						retryForNonce = false
+					if arrivals != nil {
+						var scheduled bool
+						intendedTime, scheduled = <-arrivals
+						if !scheduled {
+							break
+						}
+					} else if rl != nil {
+						tErr = rl.Wait(phaseCtx)
+						if tErr != nil && phase.Duration > 0 {
+							// The phase ends before the next request can be sent.
+							break
+						}
+						if tErr != nil {
+							log.Error().Err(tErr).Msg("Encountered a rate limiting error")
+						}
 					}
-					if strings.Contains(tErr.Error(), "transaction underpriced") && retryForNonce {
-						retryForNonce = false
+
+					ltTxHash = ethcommon.Hash{}
+					localMode := mode
+					// scenario phases pick a mode according to the weights of their mode mix
+					if loadTestScenarioPlan != nil {
+						localMode = phase.getRandomMode()
+					} else if ltp.MultiMode {
+						// if there are multiple modes, iterate through them, 'r' mode is supported here
+						localMode = ltp.ParsedModes[int(i+j)%(len(ltp.ParsedModes))]
 					}
-					if strings.Contains(tErr.Error(), "nonce too low") && retryForNonce {
-						retryForNonce = false
+					// if we're doing random, we'll just pick one based on the current index
+					if localMode == loadTestModeRandom {
+						localMode = getRandomMode()
 					}
-					if strings.Contains(tErr.Error(), "already known") && retryForNonce {
+
+					// The user operations sent to a bundler don't use a nonce of the sending
+					// account, so a nonce left to retry is kept for the next request.
+					usesNonce := !isBundledUserOp(localMode)
+					if !usesNonce {
+						myNonceValue = 0
+					} else if retryForNonce {
 						retryForNonce = false
+					} else {
+						myNonceValue = account.nextNonce()
 					}
-					if strings.Contains(tErr.Error(), "could not replace existing") && retryForNonce {
-						retryForNonce = false
+					switch localMode {
+					case loadTestModeERC20:
+						startReq, endReq, ltTxHash, tErr = loadTestERC20(ctx, c, account, myNonceValue, erc20Contract, ltAddr)
+					case loadTestModeERC721:
+						startReq, endReq, ltTxHash, tErr = loadTestERC721(ctx, c, account, myNonceValue, erc721Contract, ltAddr)
+					case loadTestModeAccessList:
+						startReq, endReq, ltTxHash, tErr = loadTestAccessList(ctx, c, rpc, account, myNonceValue, ltAddr)
+					case loadTestModeBlob:
+						startReq, endReq, ltTxHash, tErr = loadTestBlob(ctx, c, account, myNonceValue)
+					case loadTestModeBridge:
+						startReq, endReq, ltTxHash, tErr = loadTestBridge(ctx, c, account, myNonceValue, bridgeDeposits)
+					case loadTestModeContractCall:
+						startReq, endReq, ltTxHash, tErr = loadTestContractCall(ctx, c, account, myNonceValue)
+					case loadTestModeDeploy:
+						startReq, endReq, ltTxHash, tErr = loadTestDeploy(ctx, c, account, myNonceValue)
+					case loadTestModeDeployStress:
+						startReq, endReq, ltTxHash, tErr = loadTestDeployStress(ctx, c, account, myNonceValue, deployStress)
+					case loadTestModeFunction, loadTestModeCall:
+						startReq, endReq, ltTxHash, tErr = loadTestFunction(ctx, c, account, myNonceValue, ltContract)
+					case loadTestModeInscription:
+						startReq, endReq, ltTxHash, tErr = loadTestInscription(ctx, c, account, myNonceValue)
+					case loadTestModeIncrement:
+						startReq, endReq, ltTxHash, tErr = loadTestIncrement(ctx, c, account, myNonceValue, ltContract)
+					case loadTestModeMempool:
+						startReq, endReq, ltTxHash, tErr = loadTestMempool(ctx, c, rpc, account, myNonceValue)
+					case loadTestModeRandomPrecompiledContract:
+						startReq, endReq, ltTxHash, tErr = loadTestCallPrecompiledContract(ctx, c, account, myNonceValue, ltContract, false)
+					case loadTestModeSpecificPrecompiledContract:
+						startReq, endReq, ltTxHash, tErr = loadTestCallPrecompiledContract(ctx, c, account, myNonceValue, ltContract, true)
+					case loadTestModeRecall:
+						startReq, endReq, ltTxHash, tErr = loadTestRecall(ctx, c, account, myNonceValue, recallTransactions[int(myNonceValue)%len(recallTransactions)])
+					case loadTestModeRPC:
+						startReq, endReq, tErr = loadTestRPC(ctx, c, myNonceValue, indexedActivity)
+					case loadTestModeSetCode:
+						startReq, endReq, ltTxHash, tErr = loadTestSetCode(ctx, c, account, myNonceValue, setCodeAuthorities)
+					case loadTestModeStateGrowth:
+						startReq, endReq, ltTxHash, tErr = loadTestStateGrowth(ctx, c, account, myNonceValue, stateGrowth)
+					case loadTestModeStore:
+						startReq, endReq, ltTxHash, tErr = loadTestStore(ctx, c, account, myNonceValue, ltContract)
+					case loadTestModeTransaction:
+						startReq, endReq, ltTxHash, tErr = loadTestTransaction(ctx, c, account, myNonceValue)
+					case loadTestModeUniswapV3:
+						swapAmountIn := big.NewInt(int64(*uniswapv3LoadTestParams.SwapAmountInput))
+						startReq, endReq, ltTxHash, tErr = runUniswapV3Loadtest(ctx, c, account, myNonceValue, uniswapV3Config, uniswapV3Pools, swapAmountIn)
+					case loadTestModeUserOp:
+						startReq, endReq, ltTxHash, tErr = loadTestUserOp(ctx, c, account, myNonceValue, userOps)
+					default:
+						log.Error().Str("mode", mode.String()).Msg("We've arrived at a load test mode that we don't recognize")
 					}
-					if strings.Contains(tErr.Error(), "fee cap less than block base fee") {
-						retryForNonce = true
+					category := recordSample(i, j, tErr, intendedTime, startReq, endReq, myNonceValue, ltTxHash, phase.Name, localMode)
+					if tErr != nil {
+						log.Error().Err(tErr).Str("category", string(category)).Uint64("nonce", myNonceValue).Int64("request time", endReq.Sub(startReq).Milliseconds()).Msg("Recorded an error while sending transactions")
+						// The nonce is used to index the recalled transactions in call-only mode. We don't want to retry a transaction if it legit failed on the chain
+						policy := errorRetryPolicies[category]
+						if usesNonce {
+							retryForNonce = policy.retry && (!*ltp.CallOnly || policy.retryCallOnly)
+						}
+						if policy.backoff > 0 {
+							select {
+							case <-time.After(policy.backoff):
+							case <-phaseCtx.Done():
+							}
+						}
 					}
 
+					log.Trace().Stringer("txhash", ltTxHash).Uint64("nonce", myNonceValue).Int64("routine", i).Str("mode", localMode.String()).Int64("request", j).Msg("Request")
 				}
-
-				log.Trace().Stringer("txhash", ltTxHash).Uint64("nonce", myNonceValue).Int64("routine", i).Str("mode", localMode.String()).Int64("request", j).Msg("Request")
-			}
-			wg.Done()
-		}(i)
+				wg.Done()
+			}(i)
+		}
+		log.Trace().Msg("Finished starting go routines. Waiting..")
+		wg.Wait()
+		phaseCancel()
 	}
-	log.Trace().Msg("Finished starting go routines. Waiting..")
-	wg.Wait()
 	cancel()
 	if *ltp.CallOnly {
 		return nil
@@ -896,7 +1177,7 @@ func getERC721Contract(ctx context.Context, c *ethclient.Client, tops *bind.Tran
 	return
 }
 
-func loadTestTransaction(ctx context.Context, c *ethclient.Client, nonce uint64) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
+func loadTestTransaction(ctx context.Context, c *ethclient.Client, account *loadTestAccount, nonce uint64) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
 	ltp := inputLoadTestParams
 
 	to := ltp.ToETHAddress
@@ -906,7 +1187,7 @@ func loadTestTransaction(ctx context.Context, c *ethclient.Client, nonce uint64)
 
 	amount := ltp.SendAmount
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
+	privateKey := account.PrivateKey
 
 	tops, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
 	if err != nil {
@@ -940,7 +1221,7 @@ func loadTestTransaction(ctx context.Context, c *ethclient.Client, nonce uint64)
 		tx = ethtypes.NewTx(dynamicFeeTx)
 	}
 
-	stx, err := tops.Signer(*ltp.FromETHAddress, tx)
+	stx, err := tops.Signer(account.Address, tx)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to sign transaction")
 		return
@@ -1063,14 +1344,14 @@ func getSuggestedGasPrices(ctx context.Context, c *ethclient.Client) (*big.Int,
 }
 
 // TODO - in the future it might be more interesting if this mode takes input or random contracts to be deployed
-func loadTestDeploy(ctx context.Context, c *ethclient.Client, nonce uint64) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
+func loadTestDeploy(ctx context.Context, c *ethclient.Client, account *loadTestAccount, nonce uint64) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
 	var tops *bind.TransactOpts
 	var tx *ethtypes.Transaction
 
 	ltp := inputLoadTestParams
 
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
+	privateKey := account.PrivateKey
 
 	tops, err = bind.NewKeyedTransactorWithChainID(privateKey, chainID)
 	if err != nil {
@@ -1105,14 +1386,14 @@ func getCurrentLoadTestFunction() uint64 {
 	}
 	return tester.GetRandomOPCode()
 }
-func loadTestFunction(ctx context.Context, c *ethclient.Client, nonce uint64, ltContract *tester.LoadTester) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
+func loadTestFunction(ctx context.Context, c *ethclient.Client, account *loadTestAccount, nonce uint64, ltContract *tester.LoadTester) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
 	var tops *bind.TransactOpts
 	var tx *ethtypes.Transaction
 
 	ltp := inputLoadTestParams
 
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
+	privateKey := account.PrivateKey
 	iterations := ltp.Iterations
 	f := getCurrentLoadTestFunction()
 
@@ -1143,19 +1424,19 @@ func loadTestFunction(ctx context.Context, c *ethclient.Client, nonce uint64, lt
 	return
 }
 
-func loadTestCallPrecompiledContract(ctx context.Context, c *ethclient.Client, nonce uint64, ltContract *tester.LoadTester, useSelectedAddress bool) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
+func loadTestCallPrecompiledContract(ctx context.Context, c *ethclient.Client, account *loadTestAccount, nonce uint64, ltContract *tester.LoadTester, useSelectedAddress bool) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
 	var f int
 	var tops *bind.TransactOpts
 	var tx *ethtypes.Transaction
 	ltp := inputLoadTestParams
 
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
+	privateKey := account.PrivateKey
 	iterations := ltp.Iterations
 	if useSelectedAddress {
 		f = int(*ltp.Function)
 	} else {
-		f = tester.GetRandomPrecompiledContractAddress()
+		f = getRandomPrecompiledContract()
 	}
 
 	tops, err = bind.NewKeyedTransactorWithChainID(privateKey, chainID)
@@ -1170,14 +1451,14 @@ func loadTestCallPrecompiledContract(ctx context.Context, c *ethclient.Client, n
 	defer func() { t2 = time.Now() }()
 	if *ltp.CallOnly {
 		tops.NoSend = true
-		tx, err = tester.CallPrecompiledContracts(f, ltContract, tops, *iterations, privateKey)
+		tx, err = tester.CallPrecompiledContracts(f, ltContract, precompileCaller, tops, *iterations, privateKey)
 		if err != nil {
 			return
 		}
 		msg := txToCallMsg(tx)
 		_, err = c.CallContract(ctx, msg, nil)
 	} else {
-		tx, err = tester.CallPrecompiledContracts(f, ltContract, tops, *iterations, privateKey)
+		tx, err = tester.CallPrecompiledContracts(f, ltContract, precompileCaller, tops, *iterations, privateKey)
 		if err == nil && tx != nil {
 			txHash = tx.Hash()
 		}
@@ -1185,13 +1466,65 @@ func loadTestCallPrecompiledContract(ctx context.Context, c *ethclient.Client, n
 	return
 }
 
-func loadTestIncrement(ctx context.Context, c *ethclient.Client, nonce uint64, ltContract *tester.LoadTester) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
+// precompiledContracts are the precompiled contracts that are called in random precompile mode.
+var precompiledContracts []int
+
+// precompileCaller calls the precompiled contracts that the load tester contract doesn't know
+// about, so that they're called `--iterations` times as well.
+var precompileCaller *bind.BoundContract
+
+func getPrecompileCaller(ctx context.Context, c *ethclient.Client, tops *bind.TransactOpts) (*bind.BoundContract, error) {
+	address, tx, contract, err := tester.DeployPrecompileCaller(tops, c)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to deploy the precompile caller contract")
+		return nil, err
+	}
+	if _, err = bind.WaitDeployed(ctx, c, tx); err != nil {
+		log.Error().Err(err).Msg("Unable to wait for the precompile caller contract deployment")
+		return nil, err
+	}
+	log.Debug().Stringer("address", address).Msg("Precompile caller contract deployed")
+	return contract, nil
+}
+
+// getActivePrecompiledContracts returns the precompiled contracts of the random mode that
+// return a result on the chain, since the newer ones aren't enabled on every chain.
+func getActivePrecompiledContracts(ctx context.Context, c *ethclient.Client, privateKey *ecdsa.PrivateKey) []int {
+	active := make([]int, 0)
+	for _, address := range tester.GetPrecompiledContractAddresses() {
+		inputData, err := tester.GetPrecompiledContractInput(address, privateKey)
+		if err != nil {
+			log.Error().Err(err).Int("address", address).Msg("Unable to generate the precompiled contract input")
+			continue
+		}
+		account := tester.GetPrecompiledContractAccount(address)
+		output, err := c.CallContract(ctx, ethereum.CallMsg{To: &account, Data: inputData}, nil)
+		if err != nil || len(output) == 0 {
+			log.Warn().Err(err).Int("address", address).Msg("The precompiled contract isn't available. It won't be called in random mode")
+			continue
+		}
+		active = append(active, address)
+	}
+	return active
+}
+
+// getRandomPrecompiledContract picks one of the available precompiled contracts. All of them
+// are candidates if none of them could be checked.
+func getRandomPrecompiledContract() int {
+	addresses := precompiledContracts
+	if len(addresses) == 0 {
+		addresses = tester.GetPrecompiledContractAddresses()
+	}
+	return addresses[randSrc.Intn(len(addresses))]
+}
+
+func loadTestIncrement(ctx context.Context, c *ethclient.Client, account *loadTestAccount, nonce uint64, ltContract *tester.LoadTester) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
 	var tops *bind.TransactOpts
 	var tx *ethtypes.Transaction
 	ltp := inputLoadTestParams
 
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
+	privateKey := account.PrivateKey
 
 	tops, err = bind.NewKeyedTransactorWithChainID(privateKey, chainID)
 	if err != nil {
@@ -1220,14 +1553,109 @@ func loadTestIncrement(ctx context.Context, c *ethclient.Client, nonce uint64, l
 	return
 }
 
-func loadTestStore(ctx context.Context, c *ethclient.Client, nonce uint64, ltContract *tester.LoadTester) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
+// loadTestAccessList calls the increment or the store function of the load test contract with
+// a transaction carrying an access list. In legacy mode, EIP-2930 transactions are sent,
+// otherwise EIP-1559 transactions with an access list are sent.
+func loadTestAccessList(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client, account *loadTestAccount, nonce uint64, ltAddress ethcommon.Address) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
+	ltp := inputLoadTestParams
+
+	chainID := new(big.Int).SetUint64(*ltp.ChainID)
+	privateKey := account.PrivateKey
+
+	tops, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable create transaction signer")
+		return
+	}
+	tops = configureTransactOpts(ctx, c, tops)
+
+	ltABI, err := tester.LoadTesterMetaData.GetAbi()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get the load test contract abi")
+		return
+	}
+	var data, inputData []byte
+	method := "inc"
+	if randSrc.Intn(2) == 0 {
+		method = "store"
+		inputData = make([]byte, *ltp.ByteCount)
+		_, _ = hexwordRead(inputData)
+		data, err = ltABI.Pack(method, inputData)
+	} else {
+		data, err = ltABI.Pack(method)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("method", method).Msg("Unable to pack the load test contract call")
+		return
+	}
+
+	msg := ethereum.CallMsg{From: account.Address, To: &ltAddress, Data: data}
+	accessList, err := getAccessList(ctx, c, rpc, msg, method, inputData)
+	if err != nil {
+		log.Error().Err(err).Str("method", method).Msg("Unable to get the access list")
+		return
+	}
+	msg.AccessList = accessList
+	if tops.GasLimit == 0 {
+		tops.GasLimit, err = c.EstimateGas(ctx, msg)
+		if err != nil {
+			log.Error().Err(err).Str("method", method).Msg("Unable to estimate gas")
+			return
+		}
+	}
+
+	var tx *ethtypes.Transaction
+	if *ltp.LegacyTransactionMode {
+		tx = ethtypes.NewTx(&ethtypes.AccessListTx{
+			ChainID:    chainID,
+			Nonce:      nonce,
+			GasPrice:   tops.GasPrice,
+			Gas:        tops.GasLimit,
+			To:         &ltAddress,
+			Value:      big.NewInt(0),
+			Data:       data,
+			AccessList: accessList,
+		})
+	} else {
+		tx = ethtypes.NewTx(&ethtypes.DynamicFeeTx{
+			ChainID:    chainID,
+			Nonce:      nonce,
+			GasTipCap:  tops.GasTipCap,
+			GasFeeCap:  tops.GasFeeCap,
+			Gas:        tops.GasLimit,
+			To:         &ltAddress,
+			Value:      big.NewInt(0),
+			Data:       data,
+			AccessList: accessList,
+		})
+	}
+
+	stx, err := tops.Signer(account.Address, tx)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to sign transaction")
+		return
+	}
+
+	txHash = stx.Hash()
+
+	t1 = time.Now()
+	defer func() { t2 = time.Now() }()
+	if *ltp.CallOnly {
+		_, err = c.CallContract(ctx, txToCallMsg(stx), nil)
+	} else {
+		err = c.SendTransaction(ctx, stx)
+	}
+	return
+}
+
+func loadTestStore(ctx context.Context, c *ethclient.Client, account *loadTestAccount, nonce uint64, ltContract *tester.LoadTester) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
 	var tops *bind.TransactOpts
 	var tx *ethtypes.Transaction
 
 	ltp := inputLoadTestParams
 
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
+	privateKey := account.PrivateKey
 
 	tops, err = bind.NewKeyedTransactorWithChainID(privateKey, chainID)
 	if err != nil {
@@ -1258,7 +1686,7 @@ func loadTestStore(ctx context.Context, c *ethclient.Client, nonce uint64, ltCon
 	return
 }
 
-func loadTestERC20(ctx context.Context, c *ethclient.Client, nonce uint64, erc20Contract *tokens.ERC20, ltAddress ethcommon.Address) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
+func loadTestERC20(ctx context.Context, c *ethclient.Client, account *loadTestAccount, nonce uint64, erc20Contract *tokens.ERC20, ltAddress ethcommon.Address) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
 	var tops *bind.TransactOpts
 	var tx *ethtypes.Transaction
 	ltp := inputLoadTestParams
@@ -1270,7 +1698,7 @@ func loadTestERC20(ctx context.Context, c *ethclient.Client, nonce uint64, erc20
 	amount := ltp.SendAmount
 
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
+	privateKey := account.PrivateKey
 
 	tops, err = bind.NewKeyedTransactorWithChainID(privateKey, chainID)
 	if err != nil {
@@ -1300,7 +1728,7 @@ func loadTestERC20(ctx context.Context, c *ethclient.Client, nonce uint64, erc20
 	return
 }
 
-func loadTestERC721(ctx context.Context, c *ethclient.Client, nonce uint64, erc721Contract *tokens.ERC721, ltAddress ethcommon.Address) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
+func loadTestERC721(ctx context.Context, c *ethclient.Client, account *loadTestAccount, nonce uint64, erc721Contract *tokens.ERC721, ltAddress ethcommon.Address) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
 	var tops *bind.TransactOpts
 	var tx *ethtypes.Transaction
 
@@ -1313,7 +1741,7 @@ func loadTestERC721(ctx context.Context, c *ethclient.Client, nonce uint64, erc7
 	}
 
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
+	privateKey := account.PrivateKey
 
 	tops, err = bind.NewKeyedTransactorWithChainID(privateKey, chainID)
 	if err != nil {
@@ -1343,14 +1771,14 @@ func loadTestERC721(ctx context.Context, c *ethclient.Client, nonce uint64, erc7
 	return
 }
 
-func loadTestRecall(ctx context.Context, c *ethclient.Client, nonce uint64, originalTx rpctypes.PolyTransaction) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
+func loadTestRecall(ctx context.Context, c *ethclient.Client, account *loadTestAccount, nonce uint64, originalTx rpctypes.PolyTransaction) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
 	var tops *bind.TransactOpts
 	var stx *ethtypes.Transaction
 
 	ltp := inputLoadTestParams
 
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
+	privateKey := account.PrivateKey
 
 	tops, err = bind.NewKeyedTransactorWithChainID(privateKey, chainID)
 	if err != nil {
@@ -1361,7 +1789,7 @@ func loadTestRecall(ctx context.Context, c *ethclient.Client, nonce uint64, orig
 	tops = configureTransactOpts(ctx, c, tops)
 	tx := rawTransactionToNewTx(originalTx, nonce, tops.GasPrice, tops.GasTipCap)
 
-	stx, err = tops.Signer(*ltp.FromETHAddress, tx)
+	stx, err = tops.Signer(account.Address, tx)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to sign transaction")
 		return
@@ -1510,7 +1938,7 @@ func loadTestRPC(ctx context.Context, c *ethclient.Client, nonce uint64, ia *Ind
 	return
 }
 
-func loadTestContractCall(ctx context.Context, c *ethclient.Client, nonce uint64) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
+func loadTestContractCall(ctx context.Context, c *ethclient.Client, account *loadTestAccount, nonce uint64) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
 	var tops *bind.TransactOpts
 	var calldata []byte
 	var stx *ethtypes.Transaction
@@ -1520,7 +1948,7 @@ func loadTestContractCall(ctx context.Context, c *ethclient.Client, nonce uint64
 	to := ltp.ContractETHAddress
 
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
+	privateKey := account.PrivateKey
 
 	tops, err = bind.NewKeyedTransactorWithChainID(privateKey, chainID)
 	if err != nil {
@@ -1537,25 +1965,38 @@ func loadTestContractCall(ctx context.Context, c *ethclient.Client, nonce uint64
 
 	var stringCallData string
 
-	if *inputLoadTestParams.ContractCallData == "" && *inputLoadTestParams.ContractCallFunctionSignature == "" {
-		log.Error().Err(fmt.Errorf("Missing calldata for function call"))
-		return
-	}
-
-	if *inputLoadTestParams.ContractCallData != "" {
-		stringCallData = *inputLoadTestParams.ContractCallData
-	} else {
-		stringCallData, err = abi.AbiEncode(*inputLoadTestParams.ContractCallFunctionSignature, *inputLoadTestParams.ContractCallFunctionArgs)
+	if contractCalls != nil {
+		var method gethabi.Method
+		method, calldata, err = contractCalls.getRandomCall()
 		if err != nil {
-			log.Error().Err(err).Msg("Failed to encode calldata")
+			log.Error().Err(err).Str("function", method.Sig).Msg("Failed to encode calldata")
+			return
+		}
+		// Sending value to a function that isn't payable would revert.
+		if !method.Payable {
+			amount = big.NewInt(0)
+		}
+	} else {
+		if *inputLoadTestParams.ContractCallData == "" && *inputLoadTestParams.ContractCallFunctionSignature == "" {
+			log.Error().Err(fmt.Errorf("Missing calldata for function call"))
 			return
 		}
-	}
 
-	calldata, err = hex.DecodeString(strings.TrimPrefix(stringCallData, "0x"))
-	if err != nil {
-		log.Error().Err(err).Msg("Unable to decode calldata string")
-		return
+		if *inputLoadTestParams.ContractCallData != "" {
+			stringCallData = *inputLoadTestParams.ContractCallData
+		} else {
+			stringCallData, err = abi.AbiEncode(*inputLoadTestParams.ContractCallFunctionSignature, *inputLoadTestParams.ContractCallFunctionArgs)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to encode calldata")
+				return
+			}
+		}
+
+		calldata, err = hex.DecodeString(strings.TrimPrefix(stringCallData, "0x"))
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to decode calldata string")
+			return
+		}
 	}
 
 	if tops.GasLimit == 0 {
@@ -1599,7 +2040,7 @@ func loadTestContractCall(ctx context.Context, c *ethclient.Client, nonce uint64
 	}
 	log.Trace().Interface("tx", tx).Msg("Contract call data")
 
-	stx, err = tops.Signer(*ltp.FromETHAddress, tx)
+	stx, err = tops.Signer(account.Address, tx)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to sign transaction")
 		return
@@ -1617,17 +2058,17 @@ func loadTestContractCall(ctx context.Context, c *ethclient.Client, nonce uint64
 	return
 }
 
-func loadTestInscription(ctx context.Context, c *ethclient.Client, nonce uint64) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
+func loadTestInscription(ctx context.Context, c *ethclient.Client, account *loadTestAccount, nonce uint64) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
 	var tops *bind.TransactOpts
 	var tx *ethtypes.Transaction
 	var stx *ethtypes.Transaction
 
 	ltp := inputLoadTestParams
 
-	to := ltp.FromETHAddress
+	to := &account.Address
 
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
+	privateKey := account.PrivateKey
 
 	tops, err = bind.NewKeyedTransactorWithChainID(privateKey, chainID)
 	if err != nil {
@@ -1679,7 +2120,7 @@ func loadTestInscription(ctx context.Context, c *ethclient.Client, nonce uint64)
 	}
 	log.Trace().Interface("tx", tx).Msg("Contract call data")
 
-	stx, err = tops.Signer(*ltp.FromETHAddress, tx)
+	stx, err = tops.Signer(account.Address, tx)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to sign transaction")
 		return
@@ -1696,7 +2137,7 @@ func loadTestInscription(ctx context.Context, c *ethclient.Client, nonce uint64)
 	return
 }
 
-func loadTestBlob(ctx context.Context, c *ethclient.Client, nonce uint64) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
+func loadTestBlob(ctx context.Context, c *ethclient.Client, account *loadTestAccount, nonce uint64) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
 	var stx *ethtypes.Transaction
 
 	ltp := inputLoadTestParams
@@ -1708,7 +2149,7 @@ func loadTestBlob(ctx context.Context, c *ethclient.Client, nonce uint64) (t1 ti
 
 	amount := ltp.SendAmount
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
+	privateKey := account.PrivateKey
 
 	gasLimit := uint64(21000)
 	gasPrice, gasTipCap := getSuggestedGasPrices(ctx, c)
@@ -1727,7 +2168,7 @@ func loadTestBlob(ctx context.Context, c *ethclient.Client, nonce uint64) (t1 ti
 		Value:      uint256.NewInt(amount.Uint64()),
 		Data:       nil,
 		AccessList: nil,
-		BlobHashes: make([]common.Hash, 0),
+		BlobHashes: make([]ethcommon.Hash, 0),
 		Sidecar: &ethtypes.BlobTxSidecar{
 			Blobs:       make([]kzg4844.Blob, 0),
 			Commitments: make([]kzg4844.Commitment, 0),
@@ -1740,10 +2181,13 @@ func loadTestBlob(ctx context.Context, c *ethclient.Client, nonce uint64) (t1 ti
 	// createBlob() is called to commit the randomly generated byte slice with KZG.
 	// generateBlobCommitment() will do the same for the Commitment and Proof.
 	// Append all the blob related computed values to the blobTx struct.
-	err = appendBlobCommitment(&blobTx)
-	if err != nil {
-		log.Error().Err(err).Msg("Unable to parse blob")
-		return
+	blobCount := blobs.getRandomCount()
+	for range blobCount {
+		err = appendBlobCommitment(&blobTx)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to parse blob")
+			return
+		}
 	}
 	tx := ethtypes.NewTx(&blobTx)
 
@@ -1763,22 +2207,41 @@ func loadTestBlob(ctx context.Context, c *ethclient.Client, nonce uint64) (t1 ti
 	} else {
 		err = c.SendTransaction(ctx, stx)
 	}
+	if err == nil {
+		blobs.recordSent(blobCount)
+	}
 	return
 }
 
-func recordSample(goRoutineID, requestID int64, err error, start, end time.Time, nonce uint64) {
+// recordSample adds the outcome of a request to the results, and returns the category of its
+// error, if any.
+func recordSample(goRoutineID, requestID int64, err error, intended, start, end time.Time, nonce uint64, txHash ethcommon.Hash, phase string, mode loadTestMode) errorCategory {
 	s := loadTestSample{}
 	s.GoRoutineID = goRoutineID
 	s.RequestID = requestID
+	s.IntendedTime = intended
 	s.RequestTime = start
 	s.WaitTime = end.Sub(start)
 	s.Nonce = nonce
+	s.TxHash = txHash
+	s.Phase = phase
+	s.Mode = mode
 	if err != nil {
 		s.IsError = true
+		s.ErrorCategory = getErrorCategory(err)
 	}
 	loadTestResutsMutex.Lock()
 	loadTestResults = append(loadTestResults, s)
 	loadTestResutsMutex.Unlock()
+	if err == nil && txHash != (ethcommon.Hash{}) && !*inputLoadTestParams.CallOnly && !isBundledUserOp(mode) {
+		sentTxs.add(txHash)
+		trackInclusion(txHash)
+	}
+	recordSampleMetrics(s, err)
+	if recorder != nil {
+		recorder.recordSample(s)
+	}
+	return s.ErrorCategory
 }
 
 func hexwordRead(b []byte) (int, error) {
@@ -1847,12 +2310,12 @@ func configureTransactOpts(ctx context.Context, c *ethclient.Client, tops *bind.
 	return tops
 }
 
-func waitForFinalBlock(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client, startBlockNumber, startNonce, endNonce uint64) (uint64, error) {
+func waitForFinalBlock(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client, startBlockNumber uint64) (uint64, error) {
 	ltp := inputLoadTestParams
 	var err error
 	var lastBlockNumber uint64
-	var prevNonceForFinalBlock uint64
-	var currentNonceForFinalBlock uint64
+	var prevPendingForFinalBlock uint64
+	var pendingForFinalBlock uint64
 	var initialWaitCount = 20
 	var maxWaitCount = initialWaitCount
 	for {
@@ -1863,17 +2326,17 @@ func waitForFinalBlock(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Cli
 		if *ltp.CallOnly {
 			return lastBlockNumber, nil
 		}
-		currentNonceForFinalBlock, err = c.NonceAt(ctx, *ltp.FromETHAddress, new(big.Int).SetUint64(lastBlockNumber))
+		pendingForFinalBlock, err = getSendingAccountsNonceLag(ctx, c, new(big.Int).SetUint64(lastBlockNumber))
 		if err != nil {
 			return 0, err
 		}
-		if currentNonceForFinalBlock < endNonce && maxWaitCount > 0 {
-			log.Trace().Uint64("endNonce", endNonce).Uint64("currentNonceForFinalBlock", currentNonceForFinalBlock).Uint64("prevNonceForFinalBlock", prevNonceForFinalBlock).Msg("Not all transactions have been mined. Waiting")
+		if pendingForFinalBlock > 0 && maxWaitCount > 0 {
+			log.Trace().Uint64("pendingForFinalBlock", pendingForFinalBlock).Uint64("prevPendingForFinalBlock", prevPendingForFinalBlock).Msg("Not all transactions have been mined. Waiting")
 			time.Sleep(5 * time.Second)
-			if currentNonceForFinalBlock == prevNonceForFinalBlock {
-				maxWaitCount = maxWaitCount - 1 // only decrement if currentNonceForFinalBlock doesn't progress
+			if pendingForFinalBlock == prevPendingForFinalBlock {
+				maxWaitCount = maxWaitCount - 1 // only decrement if pendingForFinalBlock doesn't progress
 			}
-			prevNonceForFinalBlock = currentNonceForFinalBlock
+			prevPendingForFinalBlock = pendingForFinalBlock
 			log.Trace().Int("Remaining Attempts", maxWaitCount).Msg("Retrying...")
 			continue
 		}
@@ -1883,7 +2346,7 @@ func waitForFinalBlock(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Cli
 		break
 	}
 
-	log.Trace().Uint64("currentNonceForFinalBlock", currentNonceForFinalBlock).Uint64("startblock", startBlockNumber).Uint64("endblock", lastBlockNumber).Msg("It looks like all transactions have been mined")
+	log.Trace().Uint64("startblock", startBlockNumber).Uint64("endblock", lastBlockNumber).Msg("It looks like all transactions have been mined")
 	return lastBlockNumber, nil
 }
 
@@ -10,26 +10,33 @@ func _() {
 	var x [1]struct{}
 	_ = x[loadTestModeERC20-0]
 	_ = x[loadTestModeERC721-1]
-	_ = x[loadTestModeBlob-2]
-	_ = x[loadTestModeCall-3]
-	_ = x[loadTestModeContractCall-4]
-	_ = x[loadTestModeDeploy-5]
-	_ = x[loadTestModeFunction-6]
-	_ = x[loadTestModeInscription-7]
-	_ = x[loadTestModeIncrement-8]
-	_ = x[loadTestModeRandomPrecompiledContract-9]
-	_ = x[loadTestModeSpecificPrecompiledContract-10]
-	_ = x[loadTestModeRandom-11]
-	_ = x[loadTestModeRecall-12]
-	_ = x[loadTestModeRPC-13]
-	_ = x[loadTestModeStore-14]
-	_ = x[loadTestModeTransaction-15]
-	_ = x[loadTestModeUniswapV3-16]
+	_ = x[loadTestModeAccessList-2]
+	_ = x[loadTestModeBlob-3]
+	_ = x[loadTestModeBridge-4]
+	_ = x[loadTestModeCall-5]
+	_ = x[loadTestModeContractCall-6]
+	_ = x[loadTestModeDeploy-7]
+	_ = x[loadTestModeDeployStress-8]
+	_ = x[loadTestModeFunction-9]
+	_ = x[loadTestModeInscription-10]
+	_ = x[loadTestModeIncrement-11]
+	_ = x[loadTestModeMempool-12]
+	_ = x[loadTestModeRandomPrecompiledContract-13]
+	_ = x[loadTestModeSpecificPrecompiledContract-14]
+	_ = x[loadTestModeRandom-15]
+	_ = x[loadTestModeRecall-16]
+	_ = x[loadTestModeRPC-17]
+	_ = x[loadTestModeSetCode-18]
+	_ = x[loadTestModeStateGrowth-19]
+	_ = x[loadTestModeStore-20]
+	_ = x[loadTestModeTransaction-21]
+	_ = x[loadTestModeUniswapV3-22]
+	_ = x[loadTestModeUserOp-23]
 }
 
-const _loadTestMode_name = "loadTestModeERC20loadTestModeERC721loadTestModeBlobloadTestModeCallloadTestModeContractCallloadTestModeDeployloadTestModeFunctionloadTestModeInscriptionloadTestModeIncrementloadTestModeRandomPrecompiledContractloadTestModeSpecificPrecompiledContractloadTestModeRandomloadTestModeRecallloadTestModeRPCloadTestModeStoreloadTestModeTransactionloadTestModeUniswapV3"
+const _loadTestMode_name = "loadTestModeERC20loadTestModeERC721loadTestModeAccessListloadTestModeBlobloadTestModeBridgeloadTestModeCallloadTestModeContractCallloadTestModeDeployloadTestModeDeployStressloadTestModeFunctionloadTestModeInscriptionloadTestModeIncrementloadTestModeMempoolloadTestModeRandomPrecompiledContractloadTestModeSpecificPrecompiledContractloadTestModeRandomloadTestModeRecallloadTestModeRPCloadTestModeSetCodeloadTestModeStateGrowthloadTestModeStoreloadTestModeTransactionloadTestModeUniswapV3loadTestModeUserOp"
 
-var _loadTestMode_index = [...]uint16{0, 17, 35, 51, 67, 91, 109, 129, 152, 173, 210, 249, 267, 285, 300, 317, 340, 361}
+var _loadTestMode_index = [...]uint16{0, 17, 35, 57, 73, 91, 107, 131, 149, 173, 193, 216, 237, 256, 293, 332, 350, 368, 383, 402, 425, 442, 465, 486, 504}
 
 func (i loadTestMode) String() string {
 	if i < 0 || i >= loadTestMode(len(_loadTestMode_index)-1) {
@@ -0,0 +1,408 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// mempoolCaseGap sends a transaction after a nonce gap, which should be queued, then fills
+	// the gap.
+	mempoolCaseGap = "gap"
+	// mempoolCaseReplace sends a transaction and replaces it with a higher fee.
+	mempoolCaseReplace = "replace"
+	// mempoolCaseUnderpriced sends a transaction below the suggested fee.
+	mempoolCaseUnderpriced = "underpriced"
+	// mempoolCaseSlots sends as many future transactions from an account as the txpool
+	// queues, in reverse nonce order.
+	mempoolCaseSlots = "slots"
+
+	mempoolOutcomeAccepted = "accepted"
+	mempoolOutcomeRejected = "rejected"
+	mempoolOutcomeEvicted  = "evicted"
+	mempoolOutcomeUnknown  = "unknown"
+
+	// mempoolRecheckDelay is how long the probes missing from the txpool are given to show up
+	// in the txpool or in a block before they're considered evicted.
+	mempoolRecheckDelay = 2 * time.Second
+	// mempoolFillAttempts is how many times a reserved nonce is sent before giving up on it.
+	mempoolFillAttempts = 3
+)
+
+var mempoolCases = []string{mempoolCaseGap, mempoolCaseReplace, mempoolCaseUnderpriced, mempoolCaseSlots}
+
+type (
+	// mempoolCaseResult counts how the txpool responded to the probe transactions of a case.
+	mempoolCaseResult struct {
+		Accepted      int
+		Rejected      int
+		Evicted       int
+		Unknown       int
+		RejectReasons map[string]int
+	}
+
+	// txPoolContent is the subset of the txpool_content and txpool_contentFrom responses
+	// needed to find the transactions of an account.
+	txPoolContent struct {
+		Pending map[string]map[string]txPoolTx `json:"pending"`
+		Queued  map[string]map[string]txPoolTx `json:"queued"`
+	}
+	txPoolTx struct {
+		Hash ethcommon.Hash `json:"hash"`
+	}
+)
+
+var (
+	mempoolResults      = make(map[string]*mempoolCaseResult)
+	mempoolResultsMutex sync.Mutex
+)
+
+// loadTestMempool runs one of the txpool edge cases with the account. The probe transactions
+// of the case are expected to be rejected or evicted at times, so an error is only returned
+// when the nonce of the request couldn't be filled.
+func loadTestMempool(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client, account *loadTestAccount, nonce uint64) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
+	ltp := inputLoadTestParams
+	if *ltp.CallOnly {
+		err = errors.New("call only mode isn't supported for mempool transactions")
+		return
+	}
+
+	chainID := new(big.Int).SetUint64(*ltp.ChainID)
+	tops, err := bind.NewKeyedTransactorWithChainID(account.PrivateKey, chainID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable create transaction signer")
+		return
+	}
+	tops = configureTransactOpts(ctx, c, tops)
+
+	cases := *ltp.MempoolCases
+	mempoolCase := cases[randSrc.Intn(len(cases))]
+
+	t1 = time.Now()
+	defer func() { t2 = time.Now() }()
+	switch mempoolCase {
+	case mempoolCaseGap:
+		txHash, err = mempoolGap(ctx, c, rpc, tops, account, nonce)
+	case mempoolCaseReplace:
+		txHash, err = mempoolReplace(ctx, c, rpc, tops, account, nonce)
+	case mempoolCaseUnderpriced:
+		txHash, err = mempoolUnderpriced(ctx, c, rpc, tops, account, nonce)
+	case mempoolCaseSlots:
+		txHash, err = mempoolSlots(ctx, c, rpc, tops, account, nonce)
+	}
+	return
+}
+
+// mempoolGap sends the transaction of the request, then reserves a block of nonces and sends a
+// transaction with the last one, which should be queued since the other nonces of the block
+// aren't used yet. The gap is filled afterwards.
+func mempoolGap(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client, tops *bind.TransactOpts, account *loadTestAccount, nonce uint64) (ethcommon.Hash, error) {
+	// The nonces are only reserved once the transaction of the request is sent, so that a
+	// retried request doesn't leave them behind.
+	txHash, err := sendMempoolTx(ctx, c, tops, account, nonce, 100)
+	if err != nil {
+		return ethcommon.Hash{}, err
+	}
+
+	gap := *inputLoadTestParams.MempoolNonceGap
+	first := account.reserveNonces(gap + 1)
+	txs := make([]*ethtypes.Transaction, 0, gap+1)
+	for n := first; n <= first+gap; n++ {
+		tx, err := signMempoolTx(tops, account, n, 100)
+		if err != nil {
+			return ethcommon.Hash{}, err
+		}
+		txs = append(txs, tx)
+	}
+
+	future := txs[len(txs)-1]
+	sendErr := c.SendTransaction(ctx, future)
+	recordMempoolOutcomes(ctx, c, rpc, account, mempoolCaseGap, []*ethtypes.Transaction{future}, []error{sendErr})
+	fillMempoolNonces(ctx, c, txs)
+	return txHash, nil
+}
+
+// mempoolReplace sends the transaction of the request and replaces it with a fee bumped by
+// the configured percentage.
+func mempoolReplace(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client, tops *bind.TransactOpts, account *loadTestAccount, nonce uint64) (ethcommon.Hash, error) {
+	original, err := sendMempoolTx(ctx, c, tops, account, nonce, 100)
+	if err != nil {
+		return ethcommon.Hash{}, err
+	}
+	replacement, err := signMempoolTx(tops, account, nonce, 100+*inputLoadTestParams.MempoolReplacementBump)
+	if err != nil {
+		return ethcommon.Hash{}, err
+	}
+	sendErr := c.SendTransaction(ctx, replacement)
+	recordMempoolOutcomes(ctx, c, rpc, account, mempoolCaseReplace, []*ethtypes.Transaction{replacement}, []error{sendErr})
+	if sendErr != nil {
+		return original, nil
+	}
+	return replacement.Hash(), nil
+}
+
+// mempoolUnderpriced sends the transaction of the request below the suggested fee. If the
+// transaction is rejected or dropped, the nonce is filled with a correctly priced one.
+func mempoolUnderpriced(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client, tops *bind.TransactOpts, account *loadTestAccount, nonce uint64) (ethcommon.Hash, error) {
+	underpriced, err := signMempoolTx(tops, account, nonce, *inputLoadTestParams.MempoolUnderpricedPercent)
+	if err != nil {
+		return ethcommon.Hash{}, err
+	}
+	sendErr := c.SendTransaction(ctx, underpriced)
+	recordMempoolOutcomes(ctx, c, rpc, account, mempoolCaseUnderpriced, []*ethtypes.Transaction{underpriced}, []error{sendErr})
+	// An accepted underpriced transaction might never be included, so it's replaced as well.
+	return sendMempoolTx(ctx, c, tops, account, nonce, 100+*inputLoadTestParams.MempoolReplacementBump)
+}
+
+// mempoolSlots sends the transaction of the request, then reserves a block of nonces and sends
+// the transactions in reverse order, so that they're all queued until the first one is sent.
+// The transactions that were rejected or evicted are sent again in order afterwards.
+func mempoolSlots(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client, tops *bind.TransactOpts, account *loadTestAccount, nonce uint64) (ethcommon.Hash, error) {
+	txHash, err := sendMempoolTx(ctx, c, tops, account, nonce, 100)
+	if err != nil {
+		return ethcommon.Hash{}, err
+	}
+
+	slots := *inputLoadTestParams.MempoolSlots
+	first := account.reserveNonces(slots)
+	txs := make([]*ethtypes.Transaction, 0, slots)
+	for n := first; n < first+slots; n++ {
+		tx, err := signMempoolTx(tops, account, n, 100)
+		if err != nil {
+			return ethcommon.Hash{}, err
+		}
+		txs = append(txs, tx)
+	}
+
+	probes := make([]*ethtypes.Transaction, 0, slots)
+	sendErrs := make([]error, 0, slots)
+	for k := len(txs) - 1; k >= 0; k-- {
+		probes = append(probes, txs[k])
+		sendErrs = append(sendErrs, c.SendTransaction(ctx, txs[k]))
+	}
+	recordMempoolOutcomes(ctx, c, rpc, account, mempoolCaseSlots, probes, sendErrs)
+	fillMempoolNonces(ctx, c, txs)
+	return txHash, nil
+}
+
+// fillMempoolNonces sends the transactions of the nonces reserved by a case in order, so that
+// the account doesn't get stuck behind a probe that was rejected or evicted. The transactions
+// already in the txpool or in a block are rejected as known, and every nonce is attempted even
+// if some of them fail.
+func fillMempoolNonces(ctx context.Context, c *ethclient.Client, txs []*ethtypes.Transaction) {
+	for _, tx := range txs {
+		var err error
+		for attempt := 0; attempt < mempoolFillAttempts; attempt++ {
+			if err = c.SendTransaction(ctx, tx); err == nil || isResentTxKnown(err) {
+				err = nil
+				break
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			time.Sleep(transportBackoff)
+		}
+		if err != nil {
+			log.Error().Err(err).Uint64("nonce", tx.Nonce()).Msg("Unable to fill a nonce reserved by a mempool case")
+		}
+	}
+}
+
+// signMempoolTx signs a transfer with the fee set to the given percentage of the suggested fee.
+func signMempoolTx(tops *bind.TransactOpts, account *loadTestAccount, nonce uint64, feePercent float64) (*ethtypes.Transaction, error) {
+	ltp := inputLoadTestParams
+	scale := func(v *big.Int) *big.Int {
+		scaled, _ := new(big.Float).Mul(new(big.Float).SetInt(v), big.NewFloat(feePercent/100)).Int(nil)
+		return scaled
+	}
+
+	var tx *ethtypes.Transaction
+	if *ltp.LegacyTransactionMode {
+		tx = ethtypes.NewTx(&ethtypes.LegacyTx{
+			Nonce:    nonce,
+			To:       ltp.ToETHAddress,
+			Value:    ltp.SendAmount,
+			Gas:      21000,
+			GasPrice: scale(tops.GasPrice),
+		})
+	} else {
+		tx = ethtypes.NewTx(&ethtypes.DynamicFeeTx{
+			ChainID:   new(big.Int).SetUint64(*ltp.ChainID),
+			Nonce:     nonce,
+			To:        ltp.ToETHAddress,
+			Gas:       21000,
+			GasFeeCap: scale(tops.GasFeeCap),
+			GasTipCap: scale(tops.GasTipCap),
+			Value:     ltp.SendAmount,
+		})
+	}
+	stx, err := tops.Signer(account.Address, tx)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to sign transaction")
+		return nil, err
+	}
+	return stx, nil
+}
+
+func sendMempoolTx(ctx context.Context, c *ethclient.Client, tops *bind.TransactOpts, account *loadTestAccount, nonce uint64, feePercent float64) (ethcommon.Hash, error) {
+	tx, err := signMempoolTx(tops, account, nonce, feePercent)
+	if err != nil {
+		return ethcommon.Hash{}, err
+	}
+	if err = c.SendTransaction(ctx, tx); err != nil {
+		return ethcommon.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
+
+// recordMempoolOutcomes checks how the txpool responded to the probe transactions. A probe is accepted if it's in the txpool or has been included,
+// and rejected if sending it failed. The probes that are missing are looked up again after a
+// delay, since the txpool might not have processed them yet, and are evicted if they're still
+// missing. Without the txpool content, the outcome of the probes that were sent is unknown.
+func recordMempoolOutcomes(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client, account *loadTestAccount, mempoolCase string, txs []*ethtypes.Transaction, sendErrs []error) {
+	outcomes := make([]string, len(txs))
+	missing := false
+	for k := range txs {
+		if sendErrs[k] != nil {
+			outcomes[k] = mempoolOutcomeRejected
+		} else {
+			missing = true
+			outcomes[k] = mempoolOutcomeUnknown
+		}
+	}
+	for check := 0; missing && check < 2; check++ {
+		if check > 0 {
+			select {
+			case <-time.After(mempoolRecheckDelay):
+			case <-ctx.Done():
+			}
+		}
+		known, err := getTxPoolHashes(ctx, rpc, account.Address)
+		if err != nil {
+			log.Warn().Err(err).Msg("Unable to get the txpool content")
+			for k := range txs {
+				if outcomes[k] == mempoolOutcomeEvicted {
+					outcomes[k] = mempoolOutcomeUnknown
+				}
+			}
+			break
+		}
+		missing = false
+		for k, tx := range txs {
+			if outcomes[k] == mempoolOutcomeRejected || outcomes[k] == mempoolOutcomeAccepted {
+				continue
+			}
+			if known[tx.Hash()] || isTxIncluded(ctx, c, tx.Hash()) {
+				outcomes[k] = mempoolOutcomeAccepted
+			} else {
+				missing = true
+				outcomes[k] = mempoolOutcomeEvicted
+			}
+		}
+	}
+
+	mempoolResultsMutex.Lock()
+	defer mempoolResultsMutex.Unlock()
+	result, ok := mempoolResults[mempoolCase]
+	if !ok {
+		result = &mempoolCaseResult{RejectReasons: make(map[string]int)}
+		mempoolResults[mempoolCase] = result
+	}
+	for k, outcome := range outcomes {
+		switch outcome {
+		case mempoolOutcomeRejected:
+			result.Rejected++
+			result.RejectReasons[string(getErrorCategory(sendErrs[k]))]++
+		case mempoolOutcomeAccepted:
+			result.Accepted++
+		case mempoolOutcomeEvicted:
+			result.Evicted++
+		default:
+			result.Unknown++
+		}
+	}
+}
+
+func isTxIncluded(ctx context.Context, c *ethclient.Client, txHash ethcommon.Hash) bool {
+	_, err := c.TransactionReceipt(ctx, txHash)
+	return err == nil
+}
+
+// isResentTxKnown returns true if a transaction that was sent again is already in the txpool
+// or in a block.
+func isResentTxKnown(err error) bool {
+	return getErrorCategory(err) == errorCategoryNonce
+}
+
+// getTxPoolHashes returns the hashes of the pending and queued transactions of the address.
+// txpool_contentFrom is used when it's supported since the whole content of a busy txpool
+// can be large.
+func getTxPoolHashes(ctx context.Context, rpc *ethrpc.Client, address ethcommon.Address) (map[ethcommon.Hash]bool, error) {
+	var content txPoolContent
+	hashes := make(map[ethcommon.Hash]bool)
+	var fromContent struct {
+		Pending map[string]txPoolTx `json:"pending"`
+		Queued  map[string]txPoolTx `json:"queued"`
+	}
+	if err := rpc.CallContext(ctx, &fromContent, "txpool_contentFrom", address); err == nil {
+		for _, tx := range fromContent.Pending {
+			hashes[tx.Hash] = true
+		}
+		for _, tx := range fromContent.Queued {
+			hashes[tx.Hash] = true
+		}
+		return hashes, nil
+	}
+
+	if err := rpc.CallContext(ctx, &content, "txpool_content"); err != nil {
+		return nil, err
+	}
+	for _, txs := range []map[string]map[string]txPoolTx{content.Pending, content.Queued} {
+		for from, nonces := range txs {
+			if !strings.EqualFold(from, address.Hex()) {
+				continue
+			}
+			for _, tx := range nonces {
+				hashes[tx.Hash] = true
+			}
+		}
+	}
+	return hashes, nil
+}
+
+// mempoolSummary logs how the txpool responded to every case.
+func mempoolSummary() {
+	mempoolResultsMutex.Lock()
+	defer mempoolResultsMutex.Unlock()
+	if len(mempoolResults) == 0 {
+		return
+	}
+	cases := make([]string, 0, len(mempoolResults))
+	for mempoolCase := range mempoolResults {
+		cases = append(cases, mempoolCase)
+	}
+	sort.Strings(cases)
+	for _, mempoolCase := range cases {
+		result := mempoolResults[mempoolCase]
+		log.Info().
+			Str("case", mempoolCase).
+			Int(mempoolOutcomeAccepted, result.Accepted).
+			Int(mempoolOutcomeRejected, result.Rejected).
+			Int(mempoolOutcomeEvicted, result.Evicted).
+			Int(mempoolOutcomeUnknown, result.Unknown).
+			Interface("rejectReasons", result.RejectReasons).
+			Msg("Mempool case results")
+	}
+}
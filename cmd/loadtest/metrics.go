@@ -0,0 +1,130 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/0xPolygon/polygon-cli/util"
+	"github.com/ethereum/go-ethereum/ethclient"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+// metricsPollInterval is how often the txpool size and the nonce lag are refreshed.
+const metricsPollInterval = 5 * time.Second
+
+type loadTestMetrics struct {
+	sent           *prometheus.CounterVec
+	errored        *prometheus.CounterVec
+	requestLatency *prometheus.HistogramVec
+	txPoolPending  prometheus.Gauge
+	txPoolQueued   prometheus.Gauge
+	nonceLag       prometheus.Gauge
+}
+
+var ltMetrics *loadTestMetrics
+
+// startMetricsServer registers the load test metrics and starts a server to expose them at
+// the /metrics endpoint, so that Prometheus can scrape them while the load test is running.
+func startMetricsServer(port uint) {
+	ltMetrics = &loadTestMetrics{
+		sent: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loadtest",
+			Name:      "transactions_sent_total",
+			Help:      "The number of requests that were sent successfully",
+		}, []string{"mode"}),
+		errored: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loadtest",
+			Name:      "transactions_errored_total",
+			Help:      "The number of requests that returned an error",
+		}, []string{"mode", "error_class"}),
+		requestLatency: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "loadtest",
+			Name:      "request_latency_seconds",
+			Help:      "The time taken by the RPC endpoint to handle the requests",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16),
+		}, []string{"mode"}),
+		txPoolPending: promauto.NewGauge(prometheus.GaugeOpts{
+			Namespace: "loadtest",
+			Name:      "txpool_pending",
+			Help:      "The number of pending transactions reported by txpool_status",
+		}),
+		txPoolQueued: promauto.NewGauge(prometheus.GaugeOpts{
+			Namespace: "loadtest",
+			Name:      "txpool_queued",
+			Help:      "The number of queued transactions reported by txpool_status",
+		}),
+		nonceLag: promauto.NewGauge(prometheus.GaugeOpts{
+			Namespace: "loadtest",
+			Name:      "nonce_lag",
+			Help:      "The number of transactions sent by the sending accounts that aren't included yet",
+		}),
+	}
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "loadtest",
+		Name:      "rate_limit",
+		Help:      "The current rate limit in requests per second. Zero or +Inf means there is no rate limit",
+	}, func() float64 {
+		if rl == nil {
+			return 0
+		}
+		return float64(rl.Limit())
+	})
+
+	go func() {
+		http.Handle("/metrics", promhttp.Handler())
+		addr := fmt.Sprintf(":%d", port)
+		if err := http.ListenAndServe(addr, nil); err != nil {
+			log.Error().Err(err).Msg("Failed to start Prometheus handler")
+		}
+	}()
+}
+
+// recordSampleMetrics updates the request metrics with the outcome of a request.
+func recordSampleMetrics(s loadTestSample, err error) {
+	if ltMetrics == nil {
+		return
+	}
+	mode := s.Mode.String()
+	ltMetrics.requestLatency.WithLabelValues(mode).Observe(s.WaitTime.Seconds())
+	if err != nil {
+		ltMetrics.errored.WithLabelValues(mode, string(s.ErrorCategory)).Inc()
+		return
+	}
+	ltMetrics.sent.WithLabelValues(mode).Inc()
+}
+
+// pollMetrics periodically refreshes the txpool size and the nonce lag of the sending accounts.
+func pollMetrics(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) {
+	tryTxPool := true
+	ticker := time.NewTicker(metricsPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if tryTxPool {
+				pendingTx, queuedTx, err := util.GetTxPoolStatus(rpc)
+				if err != nil {
+					tryTxPool = false
+					log.Warn().Err(err).Msg("Error getting txpool size. Disabling the txpool metrics")
+				} else {
+					ltMetrics.txPoolPending.Set(float64(pendingTx))
+					ltMetrics.txPoolQueued.Set(float64(queuedTx))
+				}
+			}
+			nonceLag, err := getSendingAccountsNonceLag(ctx, c, nil)
+			if err != nil {
+				log.Trace().Err(err).Msg("Unable to get the nonce lag")
+				continue
+			}
+			ltMetrics.nonceLag.Set(float64(nonceLag))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -30,8 +30,8 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-func printBlockSummary(c *ethclient.Client, bs map[uint64]blockSummary, startNonce, endNonce uint64) {
-	filterBlockSummary(bs, startNonce, endNonce)
+func printBlockSummary(c *ethclient.Client, bs map[uint64]blockSummary, sentTxs map[ethcommon.Hash]loadTestSample) {
+	filterBlockSummary(bs, sentTxs)
 	mapKeys := getSortedMapKeys(bs)
 	if len(mapKeys) == 0 {
 		return
@@ -96,6 +96,30 @@ func printBlockSummary(c *ethclient.Client, bs map[uint64]blockSummary, startNon
 	minLatency, medianLatency, maxLatency := getMinMedianMax(allLatencies)
 	successfulTx, totalTx := getSuccessfulTransactionCount(bs)
 	meanBlocktime, medianBlocktime, minBlocktime, maxBlocktime, stddevBlocktime, varianceBlocktime := getTimestampBlockSummary(bs)
+	var phaseSummaries []PhaseSummary
+	if loadTestScenarioPlan != nil {
+		phaseSummaries = getPhaseSummaries(bs, sentTxs)
+	}
+	inclusionLatencies := getInclusionLatencies(getMapValues(sentTxs))
+
+	summaryOutput := SummaryOutput{}
+	summaryOutput.Summaries = jsonSummaryList
+	summaryOutput.SuccessfulTx = successfulTx
+	summaryOutput.TotalTx = totalTx
+	summaryOutput.TotalMiningTime = totalMiningTime
+	summaryOutput.TotalGasUsed = totalGasUsed
+	summaryOutput.TransactionsPerSec = tps
+	summaryOutput.GasPerSecond = gaspersec
+
+	latencies := Latency{}
+	latencies.Min = minLatency.Seconds()
+	latencies.Median = medianLatency.Seconds()
+	latencies.Max = maxLatency.Seconds()
+	summaryOutput.Latencies = latencies
+	summaryOutput.InclusionLatencies = inclusionLatencies
+	summaryOutput.Phases = phaseSummaries
+
+	blockSummaryOutput = &summaryOutput
 
 	if summaryOutputMode == "text" {
 		// In the case where no transaction receipts could be retrieved, return.
@@ -121,35 +145,26 @@ func printBlockSummary(c *ethclient.Client, bs map[uint64]blockSummary, startNon
 		} else {
 			log.Debug().Int("Length of blockSummary", len(bs)).Msg("blockSummary is empty")
 		}
+		for _, il := range inclusionLatencies {
+			p.Printf("Inclusion Latencies (%s) - Included: %v\tMissing: %v\tP50: %v\tP90: %v\tP99: %v\tP99.9: %v\n", il.Mode, number.Decimal(il.Included), number.Decimal(il.Missing), number.Decimal(il.P50), number.Decimal(il.P90), number.Decimal(il.P99), number.Decimal(il.P999))
+		}
+		for _, ps := range phaseSummaries {
+			p.Printf("Phase: %s\tSuccessful Tx: %v\tTotal Tx: %v\tLatencies - Min: %v\tMedian: %v\tMax: %v\n", ps.Name, number.Decimal(ps.SuccessfulTx), number.Decimal(ps.TotalTx), number.Decimal(ps.Latencies.Min), number.Decimal(ps.Latencies.Median), number.Decimal(ps.Latencies.Max))
+		}
 	} else if summaryOutputMode == "json" {
-		summaryOutput := SummaryOutput{}
-		summaryOutput.Summaries = jsonSummaryList
-		summaryOutput.SuccessfulTx = successfulTx
-		summaryOutput.TotalTx = totalTx
-		summaryOutput.TotalMiningTime = totalMiningTime
-		summaryOutput.TotalGasUsed = totalGasUsed
-		summaryOutput.TransactionsPerSec = tps
-		summaryOutput.GasPerSecond = gaspersec
-
-		latencies := Latency{}
-		latencies.Min = minLatency.Seconds()
-		latencies.Median = medianLatency.Seconds()
-		latencies.Max = maxLatency.Seconds()
-		summaryOutput.Latencies = latencies
-
 		val, _ := json.MarshalIndent(summaryOutput, "", "    ")
 		p.Println(string(val))
 	} else {
 		log.Error().Str("mode", summaryOutputMode).Msg("Invalid mode for summary output")
 	}
 }
-func filterBlockSummary(blockSummaries map[uint64]blockSummary, startNonce, endNonce uint64) {
+func filterBlockSummary(blockSummaries map[uint64]blockSummary, sentTxs map[ethcommon.Hash]loadTestSample) {
 	validTx := make(map[ethcommon.Hash]struct{}, 0)
 	var minBlock uint64 = math.MaxUint64
 	var maxBlock uint64 = 0
 	for _, bs := range blockSummaries {
 		for _, tx := range bs.Block.Transactions {
-			if tx.Nonce.ToUint64() >= startNonce && tx.Nonce.ToUint64() <= endNonce {
+			if _, sent := sentTxs[tx.Hash.ToHash()]; sent {
 				validTx[tx.Hash.ToHash()] = struct{}{}
 				if tx.BlockNumber.ToUint64() < minBlock {
 					minBlock = tx.BlockNumber.ToUint64()
@@ -188,7 +203,7 @@ func filterBlockSummary(blockSummaries map[uint64]blockSummary, startNonce, endN
 
 	}
 }
-func getMapValues[K constraints.Ordered, V any](m map[K]V) []V {
+func getMapValues[K comparable, V any](m map[K]V) []V {
 	newSlice := make([]V, 0)
 	for _, val := range m {
 		newSlice = append(newSlice, val)
@@ -299,6 +314,13 @@ type Summary struct {
 	Latencies   Latency
 }
 
+type PhaseSummary struct {
+	Name         string
+	SuccessfulTx int64
+	TotalTx      int64
+	Latencies    Latency
+}
+
 type SummaryOutput struct {
 	Summaries          []Summary
 	SuccessfulTx       int64
@@ -308,9 +330,47 @@ type SummaryOutput struct {
 	TransactionsPerSec float64
 	GasPerSecond       float64
 	Latencies          Latency
+	InclusionLatencies []InclusionLatency `json:",omitempty"`
+	Phases             []PhaseSummary     `json:",omitempty"`
 }
 
-func summarizeTransactions(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client, startBlockNumber, startNonce, lastBlockNumber, endNonce uint64) error {
+// getPhaseSummaries groups the mined transactions by the scenario phase that sent them.
+func getPhaseSummaries(bs map[uint64]blockSummary, sentTxs map[ethcommon.Hash]loadTestSample) []PhaseSummary {
+	successful := make(map[string]int64)
+	total := make(map[string]int64)
+	latencies := make(map[string][]time.Duration)
+	for _, block := range bs {
+		for hash, receipt := range block.Receipts {
+			sample, sent := sentTxs[hash]
+			if !sent {
+				continue
+			}
+			total[sample.Phase] += 1
+			successful[sample.Phase] += receipt.Status.ToInt64()
+			if latency, hasLatency := block.Latencies[hash]; hasLatency {
+				latencies[sample.Phase] = append(latencies[sample.Phase], latency)
+			}
+		}
+	}
+
+	phaseSummaries := make([]PhaseSummary, 0, len(loadTestScenarioPlan.Phases))
+	for _, phase := range loadTestScenarioPlan.Phases {
+		minLatency, medianLatency, maxLatency := getMinMedianMax(latencies[phase.Name])
+		phaseSummaries = append(phaseSummaries, PhaseSummary{
+			Name:         phase.Name,
+			SuccessfulTx: successful[phase.Name],
+			TotalTx:      total[phase.Name],
+			Latencies: Latency{
+				Min:    minLatency.Seconds(),
+				Median: medianLatency.Seconds(),
+				Max:    maxLatency.Seconds(),
+			},
+		})
+	}
+	return phaseSummaries
+}
+
+func summarizeTransactions(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client, startBlockNumber, lastBlockNumber uint64) error {
 	ltp := inputLoadTestParams
 	var err error
 
@@ -393,7 +453,7 @@ func summarizeTransactions(ctx context.Context, c *ethclient.Client, rpc *ethrpc
 		bs := blockSummary{}
 		bs.Block = &blocks[k]
 		bs.Receipts = make(map[ethcommon.Hash]rpctypes.RawTxReceipt, 0)
-		bs.Latencies = make(map[uint64]time.Duration, 0)
+		bs.Latencies = make(map[ethcommon.Hash]time.Duration, 0)
 		blockData[b.Number.ToUint64()] = bs
 	}
 
@@ -407,9 +467,13 @@ func summarizeTransactions(ctx context.Context, c *ethclient.Client, rpc *ethrpc
 		blockData[bn] = bs
 	}
 
-	nonceTimes := make(map[uint64]time.Time, 0)
+	// The transactions are matched by hash since the nonces of different sending accounts overlap.
+	sentTxs := make(map[ethcommon.Hash]loadTestSample, 0)
 	for _, ltr := range loadTestResults {
-		nonceTimes[ltr.Nonce] = ltr.RequestTime
+		if ltr.TxHash == (ethcommon.Hash{}) {
+			continue
+		}
+		sentTxs[ltr.TxHash] = ltr
 	}
 
 	minLatency := time.Millisecond * 100
@@ -417,13 +481,17 @@ func summarizeTransactions(ctx context.Context, c *ethclient.Client, rpc *ethrpc
 		for _, tx := range bs.Block.Transactions {
 			// TODO: What happens when the system clock of the load tester isn't in sync with the system clock of the miner?
 			// TODO: the timestamp in the chain only has granularity down to the second. How to deal with this
+			sample, sent := sentTxs[tx.Hash.ToHash()]
+			if !sent {
+				continue
+			}
+			requestTime := sample.getStartTime()
 			mineTime := time.Unix(bs.Block.Timestamp.ToInt64(), 0)
-			requestTime := nonceTimes[tx.Nonce.ToUint64()]
 			txLatency := mineTime.Sub(requestTime)
 			if txLatency.Hours() > 2 {
 				log.Debug().Float64("txHours", txLatency.Hours()).Uint64("nonce", tx.Nonce.ToUint64()).Uint64("blockNumber", bs.Block.Number.ToUint64()).Time("mineTime", mineTime).Time("requestTime", requestTime).Msg("Encountered transaction with more than 2 hours latency")
 			}
-			bs.Latencies[tx.Nonce.ToUint64()] = txLatency
+			bs.Latencies[tx.Hash.ToHash()] = txLatency
 
 			if txLatency < minLatency {
 				minLatency = txLatency
@@ -435,13 +503,13 @@ func summarizeTransactions(ctx context.Context, c *ethclient.Client, rpc *ethrpc
 		log.Trace().Str("minLatency", minLatency.String()).Msg("Minimum latency is below expected threshold")
 		shiftSize := ((time.Millisecond * 100) - minLatency) + time.Millisecond + 100
 		for _, bs := range blockData {
-			for _, tx := range bs.Block.Transactions {
-				bs.Latencies[tx.Nonce.ToUint64()] += shiftSize
+			for hash := range bs.Latencies {
+				bs.Latencies[hash] += shiftSize
 			}
 		}
 	}
 
-	printBlockSummary(c, blockData, startNonce, endNonce)
+	printBlockSummary(c, blockData, sentTxs)
 
 	log.Trace().Str("summaryTime", (endReceipt.Sub(startReceipt)).String()).Msg("Total Summary Time")
 
@@ -508,6 +576,90 @@ func lightSummary(lts []loadTestSample, startTime, endTime time.Time, rl *rate.L
 		Float64("finalRateLimit", rlLimit).
 		Msg("Rough test summary")
 	log.Info().Uint64("numErrors", numErrors).Msg("Num errors")
+	errorCategoryLightSummary(lts)
+
+	if isOpenLoop() {
+		openLoopSummary(lts)
+	}
+
+	for _, il := range getInclusionLatencies(lts) {
+		log.Info().
+			Str("mode", il.Mode).
+			Int("included", il.Included).
+			Int("missing", il.Missing).
+			Float64("p50", il.P50).
+			Float64("p90", il.P90).
+			Float64("p99", il.P99).
+			Float64("p99.9", il.P999).
+			Msg("Inclusion Latency of Transactions Stats")
+	}
+
+	if loadTestScenarioPlan != nil {
+		phaseLightSummary(lts)
+	}
+
+	rpcEndpointsLightSummary(lts)
+	mempoolSummary()
+	userOpLightSummary()
+	stateGrowthLightSummary()
+	blobLightSummary()
+	bridgeLightSummary()
+	deployStressLightSummary()
+	rateControllerSummary()
+}
+
+// phaseLightSummary logs the request rates and latencies of every scenario phase.
+func phaseLightSummary(lts []loadTestSample) {
+	log.Info().Msg("* Phase results")
+	for _, phase := range loadTestScenarioPlan.Phases {
+		phaseSamples := make([]loadTestSample, 0)
+		for _, s := range lts {
+			if s.Phase == phase.Name {
+				phaseSamples = append(phaseSamples, s)
+			}
+		}
+		if len(phaseSamples) == 0 {
+			log.Warn().Str("phase", phase.Name).Msg("No results recorded for phase")
+			continue
+		}
+
+		var numErrors uint64 = 0
+		latencies := make([]float64, 0)
+		startTime := phaseSamples[0].RequestTime
+		var endTime time.Time
+		for _, s := range phaseSamples {
+			if s.IsError {
+				numErrors++
+			}
+			latencies = append(latencies, s.WaitTime.Seconds())
+			if s.RequestTime.Before(startTime) {
+				startTime = s.RequestTime
+			}
+			if sentTime := s.RequestTime.Add(s.WaitTime); sentTime.After(endTime) {
+				endTime = sentTime
+			}
+		}
+
+		phaseDuration := endTime.Sub(startTime)
+		rps := float64(len(phaseSamples)) / phaseDuration.Seconds()
+		tps := float64(len(phaseSamples)-int(numErrors)) / phaseDuration.Seconds()
+		meanLat, _ := stats.Mean(latencies)
+		medianLat, _ := stats.Median(latencies)
+		maxLat, _ := stats.Max(latencies)
+
+		log.Info().
+			Str("phase", phase.Name).
+			Int("samples", len(phaseSamples)).
+			Uint64("numErrors", numErrors).
+			Float64("phaseDuration", phaseDuration.Seconds()).
+			Float64("targetRateLimit", float64(phase.getRateLimit())).
+			Float64("rps", rps).
+			Float64("tps", tps).
+			Float64("meanLatency", meanLat).
+			Float64("medianLatency", medianLat).
+			Float64("maxLatency", maxLat).
+			Msg("Phase summary")
+	}
 }
 
 func lastSample(lts []loadTestSample) loadTestSample {
@@ -0,0 +1,286 @@
+package loadtest
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/montanaflynn/stats"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// adaptiveTargetTxPool keeps the txpool at a steady size with AIMD.
+	adaptiveTargetTxPool = "txpool"
+	// adaptiveTargetRPCLatency keeps the p95 latency of the requests at a target.
+	adaptiveTargetRPCLatency = "rpc-latency"
+	// adaptiveTargetInclusionLatency keeps the p95 time between sending a transaction and
+	// seeing it in a block at a target.
+	adaptiveTargetInclusionLatency = "inclusion-latency"
+	// adaptiveTargetGasUtilization keeps the gas used by the blocks at a fraction of their gas
+	// limit.
+	adaptiveTargetGasUtilization = "gas-utilization"
+
+	defaultRPCLatencyTarget       = 0.5
+	defaultInclusionLatencyTarget = 10
+	defaultGasUtilizationTarget   = 0.8
+
+	// The rate limit changes by at most these fractions in a cycle, and the integral of the
+	// error is bounded so that it doesn't wind up while the rate is limited.
+	rateControllerMaxDecrease = 0.5
+	rateControllerMaxIncrease = 1
+	rateControllerMaxIntegral = 5
+	rateControllerMinRate     = 1
+
+	// rateControllerMaxPendingAge is the age, in multiples of the setpoint, at which a
+	// transaction that isn't included is given up on, e.g. because it was dropped.
+	rateControllerMaxPendingAge = 4
+)
+
+var adaptiveTargets = []string{adaptiveTargetTxPool, adaptiveTargetRPCLatency, adaptiveTargetInclusionLatency, adaptiveTargetGasUtilization}
+
+type (
+	// rateControllerPoint is the rate limit of a cycle of the rate controller, along with the
+	// throughput and the value of the target measured during the cycle.
+	rateControllerPoint struct {
+		RateLimit  float64
+		Throughput float64
+		Measured   float64
+	}
+
+	// rateController adjusts the rate limit with a PID controller to keep a latency or the
+	// block gas utilization at a setpoint.
+	rateController struct {
+		target     string
+		setpoint   float64
+		kp, ki, kd float64
+
+		integral     float64
+		prevError    float64
+		hasPrevError bool
+
+		sampleCursor int
+		pending      map[ethcommon.Hash]time.Time
+		lastBlock    uint64
+
+		points []rateControllerPoint
+		mutex  sync.Mutex
+	}
+)
+
+var rateControl *rateController
+
+// newRateController creates the controller for the target of the adaptive rate limit.
+func newRateController() *rateController {
+	ltp := inputLoadTestParams
+	setpoint := *ltp.AdaptiveTargetValue
+	if setpoint == 0 {
+		switch *ltp.AdaptiveTarget {
+		case adaptiveTargetRPCLatency:
+			setpoint = defaultRPCLatencyTarget
+		case adaptiveTargetInclusionLatency:
+			setpoint = defaultInclusionLatencyTarget
+		case adaptiveTargetGasUtilization:
+			setpoint = defaultGasUtilizationTarget
+		}
+	}
+	return &rateController{
+		target:   *ltp.AdaptiveTarget,
+		setpoint: setpoint,
+		kp:       *ltp.AdaptiveKp,
+		ki:       *ltp.AdaptiveKi,
+		kd:       *ltp.AdaptiveKd,
+		pending:  make(map[ethcommon.Hash]time.Time),
+	}
+}
+
+// run measures the target and updates the rate limit at the end of every cycle.
+func (r *rateController) run(ctx context.Context, c *ethclient.Client, rl *rate.Limiter, cycleDuration time.Duration) {
+	ticker := time.NewTicker(cycleDuration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if rl.Limit() == rate.Inf {
+				continue
+			}
+			measured, throughput, ok := r.measure(ctx, c, cycleDuration)
+			if !ok {
+				log.Debug().Str("target", r.target).Msg("Nothing to measure during the rate controller cycle")
+				continue
+			}
+			r.update(rl, measured, throughput)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// measure returns the value of the target during the last cycle, along with the number of
+// requests sent per second. It returns false when there was nothing to measure.
+func (r *rateController) measure(ctx context.Context, c *ethclient.Client, cycleDuration time.Duration) (float64, float64, bool) {
+	loadTestResutsMutex.RLock()
+	samples := append([]loadTestSample{}, loadTestResults[min(r.sampleCursor, len(loadTestResults)):]...)
+	r.sampleCursor = len(loadTestResults)
+	loadTestResutsMutex.RUnlock()
+	throughput := float64(len(samples)) / cycleDuration.Seconds()
+
+	switch r.target {
+	case adaptiveTargetRPCLatency:
+		latencies := make([]float64, 0, len(samples))
+		for _, s := range samples {
+			if !s.IsError {
+				latencies = append(latencies, s.WaitTime.Seconds())
+			}
+		}
+		if len(latencies) == 0 {
+			return 0, throughput, false
+		}
+		p95, _ := stats.Percentile(latencies, 95)
+		return p95, throughput, true
+	case adaptiveTargetInclusionLatency:
+		return r.measureInclusionLatency(samples, throughput)
+	case adaptiveTargetGasUtilization:
+		return r.measureGasUtilization(ctx, c, throughput)
+	}
+	return 0, throughput, false
+}
+
+// measureInclusionLatency returns the p95 inclusion latency of the transactions included
+// during the last cycle. The transactions that are still pending past the setpoint count with
+// their current age, so that the rate backs off when nothing gets included. Their age is capped
+// and they stop being tracked once it's reached, so that the transactions that are never
+// included don't pin the latency.
+func (r *rateController) measureInclusionLatency(samples []loadTestSample, throughput float64) (float64, float64, bool) {
+	if inclusions == nil {
+		return 0, throughput, false
+	}
+	for _, s := range samples {
+		if !s.IsError && s.TxHash != (ethcommon.Hash{}) && !isBundledUserOp(s.Mode) {
+			r.pending[s.TxHash] = s.RequestTime
+		}
+	}
+
+	now := time.Now()
+	maxAge := rateControllerMaxPendingAge * r.setpoint
+	latencies := make([]float64, 0, len(r.pending))
+	for txHash, sentTime := range r.pending {
+		if seenTime, seen := inclusions.getSeenTime(txHash); seen {
+			latencies = append(latencies, seenTime.Sub(sentTime).Seconds())
+			delete(r.pending, txHash)
+			continue
+		}
+		age := now.Sub(sentTime).Seconds()
+		if age >= maxAge {
+			age = maxAge
+			delete(r.pending, txHash)
+		}
+		if age > r.setpoint {
+			latencies = append(latencies, age)
+		}
+	}
+	if len(latencies) == 0 {
+		return 0, throughput, false
+	}
+	p95, _ := stats.Percentile(latencies, 95)
+	return p95, throughput, true
+}
+
+// measureGasUtilization returns the average fraction of the gas limit used by the blocks
+// mined during the last cycle.
+func (r *rateController) measureGasUtilization(ctx context.Context, c *ethclient.Client, throughput float64) (float64, float64, bool) {
+	head, err := c.BlockNumber(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get the block number")
+		return 0, throughput, false
+	}
+	if r.lastBlock == 0 || r.lastBlock >= head {
+		r.lastBlock = head
+		return 0, throughput, false
+	}
+
+	utilizations := make([]float64, 0, head-r.lastBlock)
+	for n := r.lastBlock + 1; n <= head; n++ {
+		header, err := c.HeaderByNumber(ctx, new(big.Int).SetUint64(n))
+		if err != nil {
+			log.Error().Err(err).Uint64("blockNumber", n).Msg("Unable to get the block header")
+			return 0, throughput, false
+		}
+		if header.GasLimit > 0 {
+			utilizations = append(utilizations, float64(header.GasUsed)/float64(header.GasLimit))
+		}
+	}
+	r.lastBlock = head
+	if len(utilizations) == 0 {
+		return 0, throughput, false
+	}
+	mean, _ := stats.Mean(utilizations)
+	return mean, throughput, true
+}
+
+// update moves the rate limit according to the relative distance between the measured value
+// and the setpoint. A value below the setpoint increases the rate, and a value above it
+// decreases the rate.
+func (r *rateController) update(rl *rate.Limiter, measured, throughput float64) {
+	currentError := (r.setpoint - measured) / r.setpoint
+	r.integral = max(-rateControllerMaxIntegral, min(rateControllerMaxIntegral, r.integral+currentError))
+	var derivative float64
+	if r.hasPrevError {
+		derivative = currentError - r.prevError
+	}
+	r.prevError = currentError
+	r.hasPrevError = true
+
+	adjustment := r.kp*currentError + r.ki*r.integral + r.kd*derivative
+	adjustment = max(-rateControllerMaxDecrease, min(rateControllerMaxIncrease, adjustment))
+	currentRate := float64(rl.Limit())
+	newRate := max(rateControllerMinRate, currentRate*(1+adjustment))
+	rl.SetLimit(rate.Limit(newRate))
+
+	r.mutex.Lock()
+	r.points = append(r.points, rateControllerPoint{RateLimit: currentRate, Throughput: throughput, Measured: measured})
+	r.mutex.Unlock()
+	log.Info().
+		Str("target", r.target).
+		Float64("setpoint", r.setpoint).
+		Float64("measured", measured).
+		Float64("throughput", throughput).
+		Float64("New Rate Limit (RPS)", newRate).
+		Msg("Adjusted rate limit")
+}
+
+// rateControllerSummary logs the value of the target measured at every rate limit, sorted by
+// rate limit, which shows the knee point of the chain or of the RPC endpoint.
+func rateControllerSummary() {
+	if rateControl == nil {
+		return
+	}
+	rateControl.mutex.Lock()
+	points := append([]rateControllerPoint{}, rateControl.points...)
+	rateControl.mutex.Unlock()
+	if len(points) == 0 {
+		return
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].RateLimit < points[j].RateLimit
+	})
+
+	log.Info().Str("target", rateControl.target).Float64("setpoint", rateControl.setpoint).Msg("* Rate controller curve")
+	var maxRateWithinTarget float64
+	for _, p := range points {
+		log.Info().
+			Float64("rateLimit", p.RateLimit).
+			Float64("throughput", p.Throughput).
+			Float64("measured", p.Measured).
+			Msg("Rate controller cycle")
+		if p.Measured <= rateControl.setpoint {
+			maxRateWithinTarget = max(maxRateWithinTarget, p.RateLimit)
+		}
+	}
+	log.Info().Float64("maxRateWithinTarget", maxRateWithinTarget).Msg("Highest rate limit that met the target")
+}
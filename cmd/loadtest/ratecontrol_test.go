@@ -0,0 +1,143 @@
+package loadtest
+
+import (
+	"testing"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+// TestRateControllerUpdate tests the PID update of the rate limit, including the bounds of
+// the adjustment, of the integral and of the rate.
+func TestRateControllerUpdate(t *testing.T) {
+	type Test struct {
+		Name         string
+		Controller   *rateController
+		Rate         float64
+		Measured     float64
+		ExpectedRate float64
+		Integral     float64
+	}
+
+	tests := []Test{
+		{
+			Name:         "proportional increase",
+			Controller:   &rateController{setpoint: 1, kp: 0.5},
+			Rate:         100,
+			Measured:     0.5,
+			ExpectedRate: 125,
+			Integral:     0.5,
+		},
+		{
+			Name:         "proportional decrease",
+			Controller:   &rateController{setpoint: 1, kp: 0.5},
+			Rate:         100,
+			Measured:     2,
+			ExpectedRate: 50,
+			Integral:     -1,
+		},
+		{
+			Name:         "bounded decrease",
+			Controller:   &rateController{setpoint: 1, kp: 1},
+			Rate:         100,
+			Measured:     10,
+			ExpectedRate: 100 * (1 - rateControllerMaxDecrease),
+			Integral:     -rateControllerMaxIntegral,
+		},
+		{
+			Name:         "bounded increase",
+			Controller:   &rateController{setpoint: 1, kp: 2},
+			Rate:         100,
+			Measured:     0,
+			ExpectedRate: 100 * (1 + rateControllerMaxIncrease),
+			Integral:     1,
+		},
+		{
+			Name:         "minimum rate",
+			Controller:   &rateController{setpoint: 1, kp: 1},
+			Rate:         1.5,
+			Measured:     10,
+			ExpectedRate: rateControllerMinRate,
+			Integral:     -rateControllerMaxIntegral,
+		},
+		{
+			Name:         "bounded integral",
+			Controller:   &rateController{setpoint: 1, ki: 0.1, integral: 4.8},
+			Rate:         100,
+			Measured:     0.5,
+			ExpectedRate: 150,
+			Integral:     rateControllerMaxIntegral,
+		},
+		{
+			Name:         "derivative",
+			Controller:   &rateController{setpoint: 1, kd: 1, prevError: 0.5, hasPrevError: true},
+			Rate:         100,
+			Measured:     1,
+			ExpectedRate: 50,
+			Integral:     0,
+		},
+		{
+			Name:         "no derivative on the first cycle",
+			Controller:   &rateController{setpoint: 1, kd: 1},
+			Rate:         100,
+			Measured:     0.5,
+			ExpectedRate: 100,
+			Integral:     0.5,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			rl := rate.NewLimiter(rate.Limit(tc.Rate), 1)
+			tc.Controller.update(rl, tc.Measured, tc.Rate)
+			assert.InDelta(t, tc.ExpectedRate, float64(rl.Limit()), 1e-9)
+			assert.InDelta(t, tc.Integral, tc.Controller.integral, 1e-9)
+			assert.True(t, tc.Controller.hasPrevError)
+		})
+	}
+}
+
+// TestMeasureInclusionLatency tests the latency measured for the included and the pending
+// transactions, and that the transactions pending for too long stop being tracked.
+func TestMeasureInclusionLatency(t *testing.T) {
+	type Test struct {
+		Name     string
+		SentAgo  time.Duration
+		SeenAgo  time.Duration
+		Expected float64
+		Measured bool
+		Pending  bool
+	}
+
+	const setpoint = 10
+	tests := []Test{
+		{Name: "included", SentAgo: 30 * time.Second, SeenAgo: 28 * time.Second, Expected: 2, Measured: true},
+		{Name: "pending within the setpoint", SentAgo: 5 * time.Second, Pending: true},
+		{Name: "pending past the setpoint", SentAgo: 20 * time.Second, Expected: 20, Measured: true, Pending: true},
+		{Name: "pending past the maximum age", SentAgo: 100 * time.Second, Expected: rateControllerMaxPendingAge * setpoint, Measured: true},
+	}
+
+	defer func(tracker *inclusionTracker) { inclusions = tracker }(inclusions)
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			now := time.Now()
+			txHash := ethcommon.HexToHash("0x01")
+			inclusions = &inclusionTracker{firstSeen: make(map[ethcommon.Hash]inclusion)}
+			if tc.SeenAgo > 0 {
+				inclusions.firstSeen[txHash] = inclusion{SeenTime: now.Add(-tc.SeenAgo)}
+			}
+			r := &rateController{setpoint: setpoint, pending: make(map[ethcommon.Hash]time.Time)}
+			samples := []loadTestSample{{TxHash: txHash, RequestTime: now.Add(-tc.SentAgo), Mode: loadTestModeTransaction}}
+
+			measured, _, ok := r.measureInclusionLatency(samples, 0)
+			assert.Equal(t, tc.Measured, ok)
+			if tc.Measured {
+				assert.InDelta(t, tc.Expected, measured, 0.5)
+			}
+			_, pending := r.pending[txHash]
+			assert.Equal(t, tc.Pending, pending)
+		})
+	}
+}
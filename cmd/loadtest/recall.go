@@ -1,15 +1,35 @@
 package loadtest
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/0xPolygon/polygon-cli/proto/gen/pb"
 	"github.com/0xPolygon/polygon-cli/rpctypes"
 	"github.com/0xPolygon/polygon-cli/util"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	ethrpc "github.com/ethereum/go-ethereum/rpc"
-	"math/big"
-	"strings"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	recallFileFormatJSON  = "json"
+	recallFileFormatProto = "proto"
 )
 
 // TODO allow this to be pre-specified with an input file
@@ -26,24 +46,166 @@ func getRecentBlocks(ctx context.Context, ec *ethclient.Client, c *ethrpc.Client
 	return rawBlocks, err
 }
 
+// getRecallTransactions returns the transactions replayed in recall mode. They come from the
+// files written by `polycli dumpblocks` if any are given, otherwise from the latest blocks of the
+// chain. Only the transactions matching the recall filters are kept.
 func getRecallTransactions(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) ([]rpctypes.PolyTransaction, error) {
-	rb, err := getRecentBlocks(ctx, c, rpc)
+	ltp := inputLoadTestParams
+	var blocks []*rpctypes.RawBlockResponse
+	var err error
+	if len(*ltp.RecallFiles) > 0 {
+		blocks, err = readDumpedBlocks(*ltp.RecallFiles, *ltp.RecallFileFormat)
+	} else {
+		var rb []*json.RawMessage
+		rb, err = getRecentBlocks(ctx, c, rpc)
+		for _, v := range rb {
+			if err != nil {
+				break
+			}
+			b := new(rpctypes.RawBlockResponse)
+			err = json.Unmarshal(*v, b)
+			blocks = append(blocks, b)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := newRecallFilter(*ltp.RecallFrom, *ltp.RecallTo, *ltp.RecallSelectors)
 	if err != nil {
 		return nil, err
 	}
 	txs := make([]rpctypes.PolyTransaction, 0)
-	for _, v := range rb {
-		pb := new(rpctypes.RawBlockResponse)
-		err := json.Unmarshal(*v, pb)
+	for _, b := range blocks {
+		for k := range b.Transactions {
+			pt := rpctypes.NewPolyTransaction(&b.Transactions[k])
+			if filter.matches(pt) {
+				txs = append(txs, pt)
+			}
+		}
+	}
+	if len(txs) == 0 {
+		return nil, errors.New("there are no transactions to recall")
+	}
+	log.Debug().Int("blocks", len(blocks)).Int("transactions", len(txs)).Msg("Retrieved transactions to recall")
+	return txs, nil
+}
+
+// readDumpedBlocks reads the blocks from files written by `polycli dumpblocks`. The receipts that
+// are dumped along with the blocks are skipped.
+func readDumpedBlocks(fileNames []string, format string) ([]*rpctypes.RawBlockResponse, error) {
+	blocks := make([]*rpctypes.RawBlockResponse, 0)
+	for _, fileName := range fileNames {
+		f, err := os.Open(fileName)
 		if err != nil {
 			return nil, err
 		}
-		for k := range pb.Transactions {
-			pt := rpctypes.NewPolyTransaction(&pb.Transactions[k])
-			txs = append(txs, pt)
+		var fileBlocks []*rpctypes.RawBlockResponse
+		if format == recallFileFormatProto {
+			fileBlocks, err = readProtoBlocks(bufio.NewReader(f))
+		} else {
+			fileBlocks, err = readJSONBlocks(f)
 		}
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the blocks of %s: %w", fileName, err)
+		}
+		blocks = append(blocks, fileBlocks...)
 	}
-	return txs, nil
+	return blocks, nil
+}
+
+// readJSONBlocks reads the JSON blocks and receipts written one after the other by dumpblocks.
+// Unlike receipts, blocks have a hash.
+func readJSONBlocks(r io.Reader) ([]*rpctypes.RawBlockResponse, error) {
+	blocks := make([]*rpctypes.RawBlockResponse, 0)
+	decoder := json.NewDecoder(r)
+	for {
+		b := new(rpctypes.RawBlockResponse)
+		err := decoder.Decode(b)
+		if err == io.EOF {
+			return blocks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b.Hash != "" {
+			blocks = append(blocks, b)
+		}
+	}
+}
+
+// readProtoBlocks reads the length prefixed protobuf blocks and receipts written by dumpblocks.
+// Protobuf messages don't say what they are, so a message is taken to be a block if its hash field
+// holds a 32 byte hash. The same field holds the sender address of a receipt.
+func readProtoBlocks(r io.Reader) ([]*rpctypes.RawBlockResponse, error) {
+	blocks := make([]*rpctypes.RawBlockResponse, 0)
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return blocks, nil
+			}
+			return nil, err
+		}
+		msg := make([]byte, binary.LittleEndian.Uint32(header))
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return nil, err
+		}
+
+		block := &pb.Block{}
+		if err := proto.Unmarshal(msg, block); err != nil || len(block.Hash) != 2+2*ethcommon.HashLength {
+			continue
+		}
+		data, err := protojson.Marshal(block)
+		if err != nil {
+			return nil, err
+		}
+		b := new(rpctypes.RawBlockResponse)
+		if err = json.Unmarshal(data, b); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+}
+
+// recallFilter selects the recalled transactions by sender, recipient and function selector. An
+// empty list matches every transaction.
+type recallFilter struct {
+	from      []ethcommon.Address
+	to        []ethcommon.Address
+	selectors [][]byte
+}
+
+func newRecallFilter(from, to, selectors []string) (*recallFilter, error) {
+	f := new(recallFilter)
+	for _, a := range from {
+		f.from = append(f.from, ethcommon.HexToAddress(a))
+	}
+	for _, a := range to {
+		f.to = append(f.to, ethcommon.HexToAddress(a))
+	}
+	for _, s := range selectors {
+		selector, err := hexutil.Decode(s)
+		if err != nil || len(selector) != 4 {
+			return nil, fmt.Errorf("invalid function selector: %s", s)
+		}
+		f.selectors = append(f.selectors, selector)
+	}
+	return f, nil
+}
+
+func (f *recallFilter) matches(pt rpctypes.PolyTransaction) bool {
+	if len(f.from) > 0 && !slices.Contains(f.from, pt.From()) {
+		return false
+	}
+	if len(f.to) > 0 && !slices.Contains(f.to, pt.To()) {
+		return false
+	}
+	if len(f.selectors) > 0 && !slices.ContainsFunc(f.selectors, func(s []byte) bool { return bytes.HasPrefix(pt.Data(), s) }) {
+		return false
+	}
+	return true
 }
 
 // IndexedActivity is used to hold a bunch of values for testing an RPC
@@ -138,7 +300,8 @@ func rawTransactionToNewTx(pt rpctypes.PolyTransaction, nonce uint64, price, tip
 
 func rawTransactionToDynamicFeeTx(pt rpctypes.PolyTransaction, nonce uint64, price, tipCap *big.Int) *ethtypes.Transaction {
 	toAddr := pt.To()
-	chainId := new(big.Int).SetUint64(pt.ChainID())
+	// The transaction may come from another chain, so it's replayed with the chain id of the load test.
+	chainId := new(big.Int).SetUint64(*inputLoadTestParams.ChainID)
 	dynamicFeeTx := &ethtypes.DynamicFeeTx{
 		ChainID:   chainId,
 		To:        &toAddr,
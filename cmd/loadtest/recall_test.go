@@ -0,0 +1,105 @@
+package loadtest
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/0xPolygon/polygon-cli/proto/gen/pb"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// TestReadDumpedBlocks tests that the blocks written by dumpblocks are read back in both
+// formats, and that the receipts dumped along with them are skipped.
+func TestReadDumpedBlocks(t *testing.T) {
+	type Test struct {
+		Name    string
+		Format  string
+		Data    []byte
+		Numbers []string
+		ErrMsg  string
+	}
+
+	blockHash := func(b byte) string {
+		return ethcommon.BytesToHash([]byte{b}).Hex()
+	}
+	sender := "0x00000000000000000000000000000000000000aa"
+	jsonBlock := func(number string, b byte) string {
+		return `{"number":"` + number + `","hash":"` + blockHash(b) + `","transactions":[{"hash":"` + blockHash(0xf0+b) + `","from":"` + sender + `","input":"0x"}]}` + "\n"
+	}
+	jsonReceipt := `{"transactionHash":"` + blockHash(0xf1) + `","from":"` + sender + `","status":"0x1"}` + "\n"
+
+	protoMessage := func(t *testing.T, m proto.Message) []byte {
+		out, err := proto.Marshal(m)
+		require.NoError(t, err)
+		header := make([]byte, 4)
+		binary.LittleEndian.PutUint32(header, uint32(len(out)))
+		return append(header, out...)
+	}
+	protoBlock := func(number string, b byte) proto.Message {
+		return &pb.Block{Number: number, Hash: blockHash(b), Transactions: []*pb.Transaction{{Hash: blockHash(0xf0 + b), From: sender}}}
+	}
+	protoReceipt := &pb.Transaction{Hash: blockHash(0xf1), From: sender}
+
+	tests := []Test{
+		{
+			Name:    "json blocks and receipts",
+			Format:  recallFileFormatJSON,
+			Data:    []byte(jsonBlock("0x1", 1) + jsonReceipt + jsonBlock("0x2", 2)),
+			Numbers: []string{"0x1", "0x2"},
+		},
+		{
+			Name:    "empty json file",
+			Format:  recallFileFormatJSON,
+			Numbers: []string{},
+		},
+		{
+			Name:   "invalid json",
+			Format: recallFileFormatJSON,
+			Data:   []byte(jsonBlock("0x1", 1) + "{"),
+			ErrMsg: "unable to read the blocks",
+		},
+		{
+			Name:    "proto blocks and receipts",
+			Format:  recallFileFormatProto,
+			Data:    append(append(protoMessage(t, protoBlock("0x1", 1)), protoMessage(t, protoReceipt)...), protoMessage(t, protoBlock("0x2", 2))...),
+			Numbers: []string{"0x1", "0x2"},
+		},
+		{
+			Name:    "empty proto file",
+			Format:  recallFileFormatProto,
+			Numbers: []string{},
+		},
+		{
+			Name:   "truncated proto message",
+			Format: recallFileFormatProto,
+			Data:   protoMessage(t, protoBlock("0x1", 1))[:10],
+			ErrMsg: "unable to read the blocks",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "blocks")
+			require.NoError(t, os.WriteFile(fileName, tc.Data, 0o644))
+
+			blocks, err := readDumpedBlocks([]string{fileName}, tc.Format)
+			if tc.ErrMsg != "" {
+				assert.ErrorContains(t, err, tc.ErrMsg)
+				return
+			}
+			require.NoError(t, err)
+			numbers := make([]string, 0, len(blocks))
+			for _, b := range blocks {
+				numbers = append(numbers, string(b.Number))
+				require.Len(t, b.Transactions, 1)
+				assert.Equal(t, sender, string(b.Transactions[0].From))
+			}
+			assert.Equal(t, tc.Numbers, numbers)
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package loadtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// recordedTransaction is a line of a record file. Setup transactions are the ones sent
+	// before the load starts, such as contract deployments and funding, and don't have an
+	// offset.
+	recordedTransaction struct {
+		Setup  bool              `json:"setup"`
+		Offset time.Duration     `json:"offset"`
+		Nonce  uint64            `json:"nonce"`
+		Mode   string            `json:"mode"`
+		Phase  string            `json:"phase,omitempty"`
+		Hash   ethcommon.Hash    `json:"hash"`
+		RawTx  hexutil.Bytes     `json:"rawTx"`
+		From   ethcommon.Address `json:"from"`
+	}
+
+	// capturedTransaction is a raw signed transaction that hasn't been written yet.
+	capturedTransaction struct {
+		rawTx hexutil.Bytes
+		time  time.Time
+	}
+
+	// txRecorder captures the raw signed transactions sent to the RPC endpoint and writes them,
+	// with the nonce, mode and intended send offset of the matching request, to a file.
+	txRecorder struct {
+		file      *os.File
+		writer    *bufio.Writer
+		pending   map[ethcommon.Hash]capturedTransaction
+		order     []ethcommon.Hash
+		loadStart time.Time
+		mutex     sync.Mutex
+	}
+
+	// recordingTransport is an http.RoundTripper that hands the raw transactions sent with
+	// eth_sendRawTransaction to the recorder.
+	recordingTransport struct {
+		base     http.RoundTripper
+		recorder *txRecorder
+	}
+
+	jsonRPCRequest struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+)
+
+var recorder *txRecorder
+
+func newTxRecorder(fileName string) (*txRecorder, error) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return &txRecorder{
+		file:    file,
+		writer:  bufio.NewWriter(file),
+		pending: make(map[ethcommon.Hash]capturedTransaction),
+		order:   make([]ethcommon.Hash, 0),
+	}, nil
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		t.recorder.capture(body)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// capture extracts the raw transactions from a single or batched JSON-RPC request.
+func (r *txRecorder) capture(body []byte) {
+	var requests []jsonRPCRequest
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		if err := json.Unmarshal(body, &requests); err != nil {
+			return
+		}
+	} else {
+		var request jsonRPCRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			return
+		}
+		requests = append(requests, request)
+	}
+
+	for _, request := range requests {
+		if request.Method != "eth_sendRawTransaction" || len(request.Params) == 0 {
+			continue
+		}
+		var rawTx hexutil.Bytes
+		if err := json.Unmarshal(request.Params[0], &rawTx); err != nil {
+			log.Error().Err(err).Msg("Unable to decode the raw transaction to record")
+			continue
+		}
+		tx := new(ethtypes.Transaction)
+		if err := tx.UnmarshalBinary(rawTx); err != nil {
+			log.Error().Err(err).Msg("Unable to decode the raw transaction to record")
+			continue
+		}
+		r.mutex.Lock()
+		if _, known := r.pending[tx.Hash()]; !known {
+			r.pending[tx.Hash()] = capturedTransaction{rawTx: rawTx, time: time.Now()}
+			// the order only matters for the setup transactions
+			if r.loadStart.IsZero() {
+				r.order = append(r.order, tx.Hash())
+			}
+		}
+		r.mutex.Unlock()
+	}
+}
+
+// startLoad writes the transactions sent so far as setup transactions. The offsets of the
+// following transactions are relative to the given time.
+func (r *txRecorder) startLoad(loadStart time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, hash := range r.order {
+		captured, isPending := r.pending[hash]
+		if !isPending {
+			continue
+		}
+		r.write(recordedTransaction{Setup: true, Mode: "setup", Hash: hash, RawTx: captured.rawTx})
+		delete(r.pending, hash)
+	}
+	r.order = nil
+	r.loadStart = loadStart
+}
+
+// recordSample writes the transaction of a sample once it has been sent successfully.
+func (r *txRecorder) recordSample(s loadTestSample) {
+	if s.TxHash == (ethcommon.Hash{}) {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	captured, isPending := r.pending[s.TxHash]
+	if !isPending {
+		return
+	}
+	delete(r.pending, s.TxHash)
+	if s.IsError {
+		return
+	}
+	r.write(recordedTransaction{
+		Offset: s.getStartTime().Sub(r.loadStart),
+		Mode:   s.Mode.String(),
+		Phase:  s.Phase,
+		Hash:   s.TxHash,
+		RawTx:  captured.rawTx,
+	})
+}
+
+// write adds a transaction to the record file. The nonce and the sender are taken from the
+// raw transaction.
+func (r *txRecorder) write(rt recordedTransaction) {
+	tx := new(ethtypes.Transaction)
+	if err := tx.UnmarshalBinary(rt.RawTx); err == nil {
+		rt.Nonce = tx.Nonce()
+		if from, err := ethtypes.Sender(ethtypes.LatestSignerForChainID(tx.ChainId()), tx); err == nil {
+			rt.From = from
+		}
+	}
+	line, err := json.Marshal(rt)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to encode the recorded transaction")
+		return
+	}
+	line = append(line, '\n')
+	if _, err = r.writer.Write(line); err != nil {
+		log.Error().Err(err).Msg("Unable to write the recorded transaction")
+	}
+}
+
+// close writes the transactions that weren't matched with a request, such as the extra
+// transactions of the mempool mode, at the offset they were sent and without a mode, and
+// closes the file.
+func (r *txRecorder) close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	unmatched := make([]ethcommon.Hash, 0, len(r.pending))
+	for hash := range r.pending {
+		unmatched = append(unmatched, hash)
+	}
+	sort.Slice(unmatched, func(i, j int) bool {
+		return r.pending[unmatched[i]].time.Before(r.pending[unmatched[j]].time)
+	})
+	for _, hash := range unmatched {
+		captured := r.pending[hash]
+		if r.loadStart.IsZero() {
+			r.write(recordedTransaction{Setup: true, Mode: "setup", Hash: hash, RawTx: captured.rawTx})
+		} else {
+			r.write(recordedTransaction{Offset: captured.time.Sub(r.loadStart), Hash: hash, RawTx: captured.rawTx})
+		}
+	}
+	if len(unmatched) > 0 {
+		log.Debug().Int("count", len(unmatched)).Msg("Recorded the sent transactions that couldn't be matched with a request")
+	}
+	if err := r.writer.Flush(); err != nil {
+		return err
+	}
+	return r.file.Close()
+}
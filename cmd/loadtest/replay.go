@@ -0,0 +1,284 @@
+package loadtest
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	//go:embed replayUsage.md
+	replayUsage       string
+	inputReplayParams replayParams
+)
+
+type replayParams struct {
+	File  *string
+	Speed *float64
+}
+
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Send the transactions recorded by a previous load test again.",
+	Long:  replayUsage,
+	Args:  cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return checkReplayFlags()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runReplay(cmd.Context())
+	},
+}
+
+func checkReplayFlags() error {
+	if *inputReplayParams.File == "" {
+		return errors.New("the record file is required")
+	}
+	if *inputReplayParams.Speed < 0 {
+		return fmt.Errorf("the speed can't be negative. Given: %f", *inputReplayParams.Speed)
+	}
+	return nil
+}
+
+func init() {
+	params := new(replayParams)
+	params.File = replayCmd.Flags().String("file", "", "The record file written by a load test with --record-file")
+	params.Speed = replayCmd.Flags().Float64("speed", 1, "A multiplier applied to the original pacing. For example 2 sends the transactions twice as fast. Use zero to send the transactions as fast as possible")
+	inputReplayParams = *params
+}
+
+// readRecordFile returns the setup transactions in their original order, and the load
+// transactions sorted by offset.
+func readRecordFile(fileName string) (setupTxs, loadTxs []recordedTransaction, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	// Blob transactions with their sidecars can be large.
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var rt recordedTransaction
+		if err = json.Unmarshal([]byte(line), &rt); err != nil {
+			return nil, nil, fmt.Errorf("unable to decode the recorded transaction: %w", err)
+		}
+		if rt.Setup {
+			setupTxs = append(setupTxs, rt)
+		} else {
+			loadTxs = append(loadTxs, rt)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	sort.SliceStable(loadTxs, func(i, j int) bool {
+		return loadTxs[i].Offset < loadTxs[j].Offset
+	})
+	return setupTxs, loadTxs, nil
+}
+
+// getLoadTestModeByName returns the load test mode matching the name used in the records.
+func getLoadTestModeByName(name string) (loadTestMode, bool) {
+	for m := loadTestMode(0); !strings.HasPrefix(m.String(), "loadTestMode("); m = m + 1 {
+		if m.String() == name {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+func runReplay(ctx context.Context) error {
+	ltp := inputLoadTestParams
+	setupTxs, loadTxs, err := readRecordFile(*inputReplayParams.File)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read the record file")
+		return err
+	}
+	log.Info().Int("setupTxs", len(setupTxs)).Int("loadTxs", len(loadTxs)).Msg("Read the record file")
+	if len(loadTxs) == 0 {
+		return errors.New("the record file doesn't have any load transaction")
+	}
+
+	rpc, err := ethrpc.DialContext(ctx, *ltp.RPCUrl)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to dial rpc")
+		return err
+	}
+	defer rpc.Close()
+	ec := ethclient.NewClient(rpc)
+
+	if err = checkReplayChainID(ctx, ec, loadTxs[0]); err != nil {
+		return err
+	}
+	if *ltp.PrometheusPort > 0 {
+		startMetricsServer(*ltp.PrometheusPort)
+	}
+
+	// The setup transactions are sent one at a time since the later ones usually depend on
+	// the earlier ones, e.g. funding the accounts before using them.
+	for _, rt := range setupTxs {
+		tx := new(ethtypes.Transaction)
+		if err = tx.UnmarshalBinary(rt.RawTx); err != nil {
+			return err
+		}
+		if err = ec.SendTransaction(ctx, tx); err != nil {
+			log.Error().Err(err).Stringer("hash", rt.Hash).Msg("Unable to send the setup transaction")
+			return err
+		}
+		if _, err = bind.WaitMined(ctx, ec, tx); err != nil {
+			return err
+		}
+	}
+	log.Debug().Int("count", len(setupTxs)).Msg("Sent the setup transactions")
+
+	startBlockNumber, err = ec.BlockNumber(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get the block number")
+		return err
+	}
+	inclusions = startInclusionTracker(ctx, ec, rpc, startBlockNumber)
+	loadTestResults = make([]loadTestSample, 0, len(loadTxs))
+
+	type scheduledTx struct {
+		index    int64
+		intended time.Time
+		rt       recordedTransaction
+	}
+	scheduled := make(chan scheduledTx, *ltp.Concurrency)
+	speed := *inputReplayParams.Speed
+	startTime := time.Now()
+	go func() {
+		defer close(scheduled)
+		for k, rt := range loadTxs {
+			intended := startTime
+			if speed > 0 {
+				intended = startTime.Add(time.Duration(float64(rt.Offset) / speed))
+			}
+			if d := time.Until(intended); d > 0 {
+				timer := time.NewTimer(d)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+			scheduled <- scheduledTx{index: int64(k), intended: intended, rt: rt}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := int64(0); i < *ltp.Concurrency; i = i + 1 {
+		wg.Add(1)
+		go func(i int64) {
+			defer wg.Done()
+			for st := range scheduled {
+				mode, isSample := getLoadTestModeByName(st.rt.Mode)
+				startReq := time.Now()
+				err := rpc.CallContext(ctx, nil, "eth_sendRawTransaction", st.rt.RawTx)
+				endReq := time.Now()
+				if err != nil {
+					log.Error().Err(err).Stringer("hash", st.rt.Hash).Uint64("nonce", st.rt.Nonce).Msg("Recorded an error while sending transactions")
+				}
+				// The transactions that weren't sent by a request of their own, such as the
+				// extra transactions of the mempool mode, weren't samples of the recorded run
+				// either.
+				if isSample {
+					recordSample(i, st.index, err, st.intended, startReq, endReq, st.rt.Nonce, st.rt.Hash, st.rt.Phase, mode)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	log.Debug().Msg("Waiting for the replayed transactions to be included")
+	waitForReplayInclusion(ctx)
+	inclusions.stop(ctx, ec, rpc)
+
+	endTime := time.Now()
+	lightSummary(loadTestResults, startTime, endTime, nil)
+	// The samples are reported like an open loop load test since the transactions are sent at
+	// their scheduled time.
+	if !isOpenLoop() {
+		openLoopSummary(loadTestResults)
+	}
+	saveRunResult(ctx, ec, rpc, startTime, endTime)
+	return nil
+}
+
+// checkReplayChainID makes sure the recorded transactions can be included on the chain.
+func checkReplayChainID(ctx context.Context, c *ethclient.Client, rt recordedTransaction) error {
+	tx := new(ethtypes.Transaction)
+	if err := tx.UnmarshalBinary(rt.RawTx); err != nil {
+		return err
+	}
+	if !tx.Protected() {
+		return nil
+	}
+	chainID, err := c.ChainID(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to fetch chain ID")
+		return err
+	}
+	if chainID.Cmp(tx.ChainId()) != 0 {
+		return fmt.Errorf("the transactions were recorded for chain id %s but the RPC endpoint is on chain id %s", tx.ChainId(), chainID)
+	}
+	return nil
+}
+
+// waitForReplayInclusion waits until every replayed transaction has been seen in a block, or
+// until the number of missing transactions stops going down.
+func waitForReplayInclusion(ctx context.Context) {
+	sentHashes := make([]ethcommon.Hash, 0, len(loadTestResults))
+	for _, s := range loadTestResults {
+		if !s.IsError {
+			sentHashes = append(sentHashes, s.TxHash)
+		}
+	}
+
+	var prevMissing int
+	maxWaitCount := 20
+	for maxWaitCount > 0 {
+		missing := 0
+		for _, hash := range sentHashes {
+			if !inclusions.isIncluded(hash) {
+				missing = missing + 1
+			}
+		}
+		if missing == 0 {
+			return
+		}
+		if missing == prevMissing {
+			maxWaitCount = maxWaitCount - 1
+		}
+		prevMissing = missing
+		log.Trace().Int("missing", missing).Int("Remaining Attempts", maxWaitCount).Msg("Not all transactions have been included. Waiting")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+	log.Warn().Int("missing", prevMissing).Msg("Some replayed transactions weren't included")
+}
@@ -0,0 +1,210 @@
+package loadtest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/0xPolygon/polygon-cli/cmd/version"
+	"github.com/ethereum/go-ethereum/ethclient"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/montanaflynn/stats"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/pflag"
+)
+
+// redactedFlags are the flags whose values are never written to the results files, since
+// they hold secrets or URLs that can embed API keys.
+var redactedFlags = map[string]struct{}{
+	"private-key":       {},
+	"rpc-url":           {},
+	"rpc-urls":          {},
+	"bundler-url":       {},
+	"coordinator":       {},
+	"coordinator-token": {},
+	"worker":            {},
+}
+
+type (
+	// RunResult is written to the results directory at the end of a load test so that runs
+	// can be compared with each other.
+	RunResult struct {
+		ID        string
+		StartTime time.Time
+		EndTime   time.Time
+		Metadata  RunMetadata
+		Summary   RunSummary
+	}
+
+	// RunMetadata describes the environment of a load test run.
+	RunMetadata struct {
+		ClientVersion string
+		ChainID       uint64
+		Modes         []string
+		Flags         map[string]string
+		Version       string
+		Commit        string
+	}
+
+	// RunSummary holds the headline numbers of a load test run. The mined transaction rate
+	// and the gas per second are only known when the run was summarized.
+	RunSummary struct {
+		Samples                 int
+		Errors                  int
+		ErrorRate               float64
+		ErrorCategories         map[string]int `json:",omitempty"`
+		RequestsPerSec          float64
+		TransactionsPerSec      float64
+		MinedTransactionsPerSec float64 `json:",omitempty"`
+		GasPerSecond            float64 `json:",omitempty"`
+		RequestLatency          LatencyPercentiles
+		InclusionLatencies      []InclusionLatency `json:",omitempty"`
+	}
+
+	// LatencyPercentiles holds latency statistics in seconds.
+	LatencyPercentiles struct {
+		Mean float64
+		P50  float64
+		P90  float64
+		P99  float64
+		Max  float64
+	}
+)
+
+var (
+	// loadTestFlags are the parsed flags of the running command, saved in the results files.
+	loadTestFlags *pflag.FlagSet
+	// blockSummaryOutput is the block summary of the run, if it was summarized.
+	blockSummaryOutput *SummaryOutput
+)
+
+// writeRunResult saves the summary and the metadata of the run to the results directory.
+func writeRunResult(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client, lts []loadTestSample, startTime, endTime time.Time) error {
+	resultsDir := *inputLoadTestParams.ResultsDir
+	if err := os.MkdirAll(resultsDir, 0o755); err != nil {
+		return err
+	}
+
+	result := RunResult{
+		ID:        getRunID(startTime),
+		StartTime: startTime,
+		EndTime:   endTime,
+		Metadata:  getRunMetadata(ctx, c, rpc),
+		Summary:   getRunSummary(lts, startTime, endTime),
+	}
+	data, err := json.MarshalIndent(result, "", "    ")
+	if err != nil {
+		return err
+	}
+	fileName := filepath.Join(resultsDir, result.ID+".json")
+	if err = os.WriteFile(fileName, data, 0o644); err != nil {
+		return err
+	}
+	log.Info().Str("file", fileName).Str("id", result.ID).Msg("Saved the run results")
+	return nil
+}
+
+// getRunID returns the id of a run started at the given time. A random suffix keeps the ids of
+// the runs started in the same second apart.
+func getRunID(startTime time.Time) string {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		log.Debug().Err(err).Msg("Unable to generate the run id suffix")
+	}
+	return startTime.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+}
+
+func getRunMetadata(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) RunMetadata {
+	metadata := RunMetadata{
+		Modes:   make([]string, 0, len(inputLoadTestParams.ParsedModes)),
+		Flags:   make(map[string]string),
+		Version: version.Version,
+		Commit:  version.Commit,
+	}
+	if err := rpc.CallContext(ctx, &metadata.ClientVersion, "web3_clientVersion"); err != nil {
+		log.Debug().Err(err).Msg("Unable to get the client version")
+	}
+	if chainID, err := c.ChainID(ctx); err != nil {
+		log.Debug().Err(err).Msg("Unable to get the chain id")
+	} else {
+		metadata.ChainID = chainID.Uint64()
+	}
+	for _, m := range inputLoadTestParams.ParsedModes {
+		metadata.Modes = append(metadata.Modes, m.String())
+	}
+	if loadTestFlags != nil {
+		loadTestFlags.VisitAll(func(f *pflag.Flag) {
+			if _, redacted := redactedFlags[f.Name]; redacted {
+				return
+			}
+			metadata.Flags[f.Name] = f.Value.String()
+		})
+	}
+	return metadata
+}
+
+func getRunSummary(lts []loadTestSample, startTime, endTime time.Time) RunSummary {
+	summary := RunSummary{Samples: len(lts)}
+	latencies := make([]float64, 0, len(lts))
+	for _, s := range lts {
+		if s.IsError {
+			summary.Errors++
+		}
+		latencies = append(latencies, s.WaitTime.Seconds())
+	}
+	testDuration := endTime.Sub(startTime).Seconds()
+	if summary.Samples > 0 {
+		summary.ErrorRate = float64(summary.Errors) / float64(summary.Samples)
+	}
+	if testDuration > 0 {
+		summary.RequestsPerSec = float64(summary.Samples) / testDuration
+		summary.TransactionsPerSec = float64(summary.Samples-summary.Errors) / testDuration
+	}
+	if blockSummaryOutput != nil {
+		summary.MinedTransactionsPerSec = blockSummaryOutput.TransactionsPerSec
+		summary.GasPerSecond = blockSummaryOutput.GasPerSecond
+	}
+	summary.RequestLatency.Mean, _ = stats.Mean(latencies)
+	summary.RequestLatency.P50, _ = stats.Percentile(latencies, 50)
+	summary.RequestLatency.P90, _ = stats.Percentile(latencies, 90)
+	summary.RequestLatency.P99, _ = stats.Percentile(latencies, 99)
+	summary.RequestLatency.Max, _ = stats.Max(latencies)
+	summary.InclusionLatencies = getInclusionLatencies(lts)
+	if summary.Errors > 0 {
+		summary.ErrorCategories = getErrorCategoryCounts(lts)
+	}
+	return summary
+}
+
+// readRunResult reads a results file. The run can be given as a path to the file or as the
+// id of a run saved in the results directory.
+func readRunResult(run string) (*RunResult, error) {
+	fileName := run
+	if _, err := os.Stat(fileName); err != nil {
+		fileName = filepath.Join(*inputLoadTestParams.ResultsDir, strings.TrimSuffix(run, ".json")+".json")
+	}
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the results of run %s: %w", run, err)
+	}
+	result := new(RunResult)
+	if err = json.Unmarshal(data, result); err != nil {
+		return nil, fmt.Errorf("unable to decode the results of run %s: %w", run, err)
+	}
+	return result, nil
+}
+
+// getOverallInclusionLatency returns the inclusion latencies across all the modes of the run.
+// When several modes are used, these are the last entry.
+func (s RunSummary) getOverallInclusionLatency() (InclusionLatency, bool) {
+	if len(s.InclusionLatencies) == 0 {
+		return InclusionLatency{}, false
+	}
+	return s.InclusionLatencies[len(s.InclusionLatencies)-1], true
+}
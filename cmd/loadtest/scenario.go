@@ -0,0 +1,208 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// loadTestScenario is a load test plan made of phases that run one after the other.
+	loadTestScenario struct {
+		Phases []*loadTestPhase `yaml:"phases"`
+	}
+
+	// loadTestPhase describes the load generated during one phase of a scenario. The phase
+	// ends once its duration has elapsed or once every go routine has sent its requests.
+	loadTestPhase struct {
+		Name        string             `yaml:"name"`
+		Duration    time.Duration      `yaml:"duration"`
+		Requests    int64              `yaml:"requests"`
+		Concurrency int64              `yaml:"concurrency"`
+		RateLimit   *float64           `yaml:"rate-limit"`
+		Modes       map[string]float64 `yaml:"modes"`
+
+		// Computed
+		parsedModes       []loadTestMode
+		cumulativeWeights []float64
+	}
+)
+
+var loadTestScenarioPlan *loadTestScenario
+
+// loadScenario reads a scenario file and fills the missing phase settings with the values
+// of the `--requests`, `--concurrency`, `--rate-limit` and `--mode` flags.
+func loadScenario(fileName string) (*loadTestScenario, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	scenario := new(loadTestScenario)
+	if err = yaml.Unmarshal(data, scenario); err != nil {
+		return nil, fmt.Errorf("unable to parse the scenario file %s: %w", fileName, err)
+	}
+	if len(scenario.Phases) == 0 {
+		return nil, fmt.Errorf("the scenario file %s doesn't define any phase", fileName)
+	}
+
+	ltp := inputLoadTestParams
+	for k, phase := range scenario.Phases {
+		if phase.Name == "" {
+			phase.Name = fmt.Sprintf("phase-%d", k+1)
+		}
+		if phase.Duration < 0 || phase.Requests < 0 || phase.Concurrency < 0 {
+			return nil, fmt.Errorf("the duration, requests and concurrency of phase %s can't be negative", phase.Name)
+		}
+		if phase.Duration == 0 && phase.Requests == 0 {
+			phase.Requests = *ltp.Requests
+		}
+		if phase.Concurrency == 0 {
+			phase.Concurrency = *ltp.Concurrency
+		}
+		if phase.RateLimit == nil {
+			phase.RateLimit = ltp.RateLimit
+		}
+		if len(phase.Modes) == 0 {
+			// Without an explicit mix, the modes from the flags are used evenly.
+			phase.Modes = make(map[string]float64)
+			for _, m := range *ltp.Modes {
+				phase.Modes[m] = 1
+			}
+		}
+		if err = phase.parseModes(); err != nil {
+			return nil, err
+		}
+	}
+	return scenario, nil
+}
+
+// parseModes validates the mode mix of the phase and precomputes the cumulative weights used
+// to pick a mode for every request.
+func (p *loadTestPhase) parseModes() error {
+	// The modes are sorted so that the selection only depends on the seed.
+	modes := make([]string, 0, len(p.Modes))
+	for m := range p.Modes {
+		modes = append(modes, m)
+	}
+	sort.Strings(modes)
+
+	var total float64
+	p.parsedModes = make([]loadTestMode, 0, len(modes))
+	p.cumulativeWeights = make([]float64, 0, len(modes))
+	for _, m := range modes {
+		weight := p.Modes[m]
+		if weight < 0 {
+			return fmt.Errorf("the weight of mode %s in phase %s can't be negative", m, p.Name)
+		}
+		if weight == 0 {
+			continue
+		}
+		parsedMode, err := characterToLoadTestMode(m)
+		if err != nil {
+			return err
+		}
+		if hasMode(parsedMode, p.parsedModes) {
+			return fmt.Errorf("duplicate mode %s in phase %s", m, p.Name)
+		}
+		total += weight
+		p.parsedModes = append(p.parsedModes, parsedMode)
+		p.cumulativeWeights = append(p.cumulativeWeights, total)
+	}
+	if len(p.parsedModes) == 0 {
+		return fmt.Errorf("phase %s needs at least one mode with a positive weight", p.Name)
+	}
+	return nil
+}
+
+// getRandomMode picks a mode of the phase according to the weights of the mode mix.
+func (p *loadTestPhase) getRandomMode() loadTestMode {
+	r := randSrc.Float64() * p.cumulativeWeights[len(p.cumulativeWeights)-1]
+	idx := sort.SearchFloat64s(p.cumulativeWeights, r)
+	if idx >= len(p.parsedModes) {
+		idx = len(p.parsedModes) - 1
+	}
+	return p.parsedModes[idx]
+}
+
+// getRateLimit returns the rate limit of the phase. A value less than or equal to zero
+// removes the limit.
+func (p *loadTestPhase) getRateLimit() rate.Limit {
+	if p.RateLimit == nil || *p.RateLimit <= 0 {
+		return rate.Inf
+	}
+	return rate.Limit(*p.RateLimit)
+}
+
+// getContext returns the context of the phase, which expires with the duration of the phase.
+func (p *loadTestPhase) getContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.Duration > 0 {
+		return context.WithTimeout(ctx, p.Duration)
+	}
+	return context.WithCancel(ctx)
+}
+
+// getModes returns every mode used by the scenario.
+func (s *loadTestScenario) getModes() []loadTestMode {
+	modes := make([]loadTestMode, 0)
+	for _, phase := range s.Phases {
+		for _, m := range phase.parsedModes {
+			if !hasMode(m, modes) {
+				modes = append(modes, m)
+			}
+		}
+	}
+	return modes
+}
+
+// getMaxConcurrency returns the highest concurrency across the phases of the scenario.
+func (s *loadTestScenario) getMaxConcurrency() int64 {
+	var maxConcurrency int64
+	for _, phase := range s.Phases {
+		if phase.Concurrency > maxConcurrency {
+			maxConcurrency = phase.Concurrency
+		}
+	}
+	return maxConcurrency
+}
+
+// getLoadTestPhases returns the phases to run. Without a scenario, a single phase is built
+// from the flags and the modes are rotated rather than picked by weight.
+func getLoadTestPhases() []*loadTestPhase {
+	if loadTestScenarioPlan != nil {
+		return loadTestScenarioPlan.Phases
+	}
+	ltp := inputLoadTestParams
+	return []*loadTestPhase{{
+		Requests:    *ltp.Requests,
+		Concurrency: *ltp.Concurrency,
+		RateLimit:   ltp.RateLimit,
+	}}
+}
+
+// startLoadTestPhase applies the rate limit of a scenario phase before its go routines start.
+func startLoadTestPhase(phase *loadTestPhase) {
+	if loadTestScenarioPlan == nil {
+		return
+	}
+	if rl != nil {
+		rl.SetLimit(phase.getRateLimit())
+	}
+	l := log.Info().
+		Str("phase", phase.Name).
+		Int64("concurrency", phase.Concurrency).
+		Float64("rateLimit", float64(phase.getRateLimit())).
+		Interface("modes", phase.Modes)
+	if phase.Duration > 0 {
+		l = l.Str("duration", phase.Duration.String())
+	}
+	if phase.Requests > 0 {
+		l = l.Int64("requests", phase.Requests)
+	}
+	l.Msg("Starting load test phase")
+}
@@ -0,0 +1,141 @@
+package loadtest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLoadScenario tests the parsing of the scenario files and the defaults taken from the
+// flags.
+func TestLoadScenario(t *testing.T) {
+	type Test struct {
+		Name     string
+		Scenario string
+		Expected []loadTestPhase
+		ErrMsg   string
+	}
+
+	tests := []Test{
+		{
+			Name: "phases with modes",
+			Scenario: `
+phases:
+  - name: warmup
+    duration: 30s
+    concurrency: 4
+    rate-limit: 10
+    modes:
+      t: 3
+      erc20: 1
+  - requests: 50
+    modes:
+      s: 1
+`,
+			Expected: []loadTestPhase{
+				{Name: "warmup", Duration: 30 * time.Second, Concurrency: 4, parsedModes: []loadTestMode{loadTestModeERC20, loadTestModeTransaction}, cumulativeWeights: []float64{1, 4}},
+				{Name: "phase-2", Requests: 50, Concurrency: *inputLoadTestParams.Concurrency, parsedModes: []loadTestMode{loadTestModeStore}, cumulativeWeights: []float64{1}},
+			},
+		},
+		{
+			Name: "defaults from the flags",
+			Scenario: `
+phases:
+  - name: steady
+`,
+			Expected: []loadTestPhase{
+				{Name: "steady", Requests: *inputLoadTestParams.Requests, Concurrency: *inputLoadTestParams.Concurrency, parsedModes: []loadTestMode{loadTestModeTransaction}, cumulativeWeights: []float64{1}},
+			},
+		},
+		{
+			Name: "zero weights are skipped",
+			Scenario: `
+phases:
+  - modes:
+      t: 0
+      s: 2
+`,
+			Expected: []loadTestPhase{
+				{Name: "phase-1", Requests: *inputLoadTestParams.Requests, Concurrency: *inputLoadTestParams.Concurrency, parsedModes: []loadTestMode{loadTestModeStore}, cumulativeWeights: []float64{2}},
+			},
+		},
+		{
+			Name:     "no phases",
+			Scenario: "phases: []\n",
+			ErrMsg:   "doesn't define any phase",
+		},
+		{
+			Name: "negative duration",
+			Scenario: `
+phases:
+  - duration: -1s
+`,
+			ErrMsg: "can't be negative",
+		},
+		{
+			Name: "negative weight",
+			Scenario: `
+phases:
+  - modes:
+      t: -1
+`,
+			ErrMsg: "can't be negative",
+		},
+		{
+			Name: "only zero weights",
+			Scenario: `
+phases:
+  - modes:
+      t: 0
+`,
+			ErrMsg: "at least one mode with a positive weight",
+		},
+		{
+			Name: "duplicate mode",
+			Scenario: `
+phases:
+  - modes:
+      t: 1
+      transaction: 1
+`,
+			ErrMsg: "duplicate mode",
+		},
+		{
+			Name: "unknown mode",
+			Scenario: `
+phases:
+  - modes:
+      unknown: 1
+`,
+			ErrMsg: "unrecognized load test mode",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "scenario.yaml")
+			require.NoError(t, os.WriteFile(fileName, []byte(tc.Scenario), 0o644))
+
+			scenario, err := loadScenario(fileName)
+			if tc.ErrMsg != "" {
+				assert.ErrorContains(t, err, tc.ErrMsg)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, scenario.Phases, len(tc.Expected))
+			for k, expected := range tc.Expected {
+				phase := scenario.Phases[k]
+				assert.Equal(t, expected.Name, phase.Name)
+				assert.Equal(t, expected.Duration, phase.Duration)
+				assert.Equal(t, expected.Requests, phase.Requests)
+				assert.Equal(t, expected.Concurrency, phase.Concurrency)
+				assert.Equal(t, expected.parsedModes, phase.parsedModes)
+				assert.Equal(t, expected.cumulativeWeights, phase.cumulativeWeights)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/0xPolygon/polygon-cli/bindings/tester"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// setCodeCallGas is the gas allowed for the call through the delegated account in set code
+	// mode.
+	setCodeCallGas uint64 = 100000
+
+	// setCodeDelegationBatch is the number of authorizations of every transaction delegating
+	// the authorities before the load test starts.
+	setCodeDelegationBatch = 64
+)
+
+type (
+	// setCodeAuthority is an account delegated to the load test contract in set code mode,
+	// along with the nonce of its next authorization.
+	setCodeAuthority struct {
+		privateKey *ecdsa.PrivateKey
+		address    ethcommon.Address
+		nonce      uint64
+	}
+
+	// setCodeLoadTest holds the authorities delegated to the load test contract. An authority
+	// is taken by a single request at a time, so that the nonces of its authorizations follow
+	// each other.
+	setCodeLoadTest struct {
+		contract    ethcommon.Address
+		authorities chan *setCodeAuthority
+	}
+)
+
+var setCodeAuthorities *setCodeLoadTest
+
+// initSetCodeLoadTest creates enough authorities for every concurrent request and delegates
+// them to the load test contract, so that the load test only calls accounts that are already
+// delegated.
+func initSetCodeLoadTest(ctx context.Context, c *ethclient.Client, tops *bind.TransactOpts, ltAddress ethcommon.Address) (*setCodeLoadTest, error) {
+	ltp := inputLoadTestParams
+	concurrency := *ltp.Concurrency
+	if loadTestScenarioPlan != nil {
+		concurrency = loadTestScenarioPlan.getMaxConcurrency()
+	}
+	count := int(*ltp.SetCodeAuthorizations) * int(max(concurrency, 1))
+
+	s := &setCodeLoadTest{
+		contract:    ltAddress,
+		authorities: make(chan *setCodeAuthority, count),
+	}
+	authorities := make([]*setCodeAuthority, 0, count)
+	for range count {
+		privateKey, err := ethcrypto.GenerateKey()
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to generate the authority key")
+			return nil, err
+		}
+		authorities = append(authorities, &setCodeAuthority{
+			privateKey: privateKey,
+			address:    ethcrypto.PubkeyToAddress(privateKey.PublicKey),
+		})
+	}
+
+	for start := 0; start < count; start += setCodeDelegationBatch {
+		batch := authorities[start:min(start+setCodeDelegationBatch, count)]
+		if err := s.delegate(ctx, c, tops, batch); err != nil {
+			return nil, err
+		}
+	}
+	for _, authority := range authorities {
+		s.authorities <- authority
+	}
+	log.Debug().Int("authorities", count).Stringer("contract", ltAddress).Msg("Delegated the set code authorities")
+	return s, nil
+}
+
+// delegate sends a transaction from the funding account delegating the authorities to the load
+// test contract, and checks that the delegation is in place once it's mined.
+func (s *setCodeLoadTest) delegate(ctx context.Context, c *ethclient.Client, tops *bind.TransactOpts, authorities []*setCodeAuthority) error {
+	authList, err := s.getAuthorizations(authorities)
+	if err != nil {
+		return err
+	}
+	data, err := getSetCodeCallData()
+	if err != nil {
+		return err
+	}
+	nonce, err := c.PendingNonceAt(ctx, tops.From)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get the nonce of the funding account")
+		return err
+	}
+	gasPrice, gasTipCap := getSuggestedGasPrices(ctx, c)
+	tx, err := tops.Signer(tops.From, ethtypes.NewTx(&ethtypes.SetCodeTx{
+		ChainID:   uint256.NewInt(*inputLoadTestParams.ChainID),
+		Nonce:     nonce,
+		GasTipCap: uint256.MustFromBig(gasTipCap),
+		GasFeeCap: uint256.MustFromBig(gasPrice),
+		Gas:       getSetCodeGasLimit(uint64(len(authList))),
+		To:        authorities[0].address,
+		Value:     uint256.NewInt(0),
+		Data:      data,
+		AuthList:  authList,
+	}))
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to sign the delegation transaction")
+		return err
+	}
+	if err = c.SendTransaction(ctx, tx); err != nil {
+		log.Error().Err(err).Msg("Unable to send the delegation transaction")
+		return err
+	}
+	receipt, err := bind.WaitMined(ctx, c, tx)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to wait for the delegation transaction")
+		return err
+	}
+	if receipt.Status != ethtypes.ReceiptStatusSuccessful {
+		return fmt.Errorf("the delegation transaction %s failed", tx.Hash())
+	}
+
+	delegation := ethtypes.AddressToDelegation(s.contract)
+	for _, authority := range authorities {
+		code, err := c.CodeAt(ctx, authority.address, nil)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(code, delegation) {
+			return fmt.Errorf("the authority %s wasn't delegated to the load test contract. Make sure the chain has the Prague fork activated", authority.address)
+		}
+		authority.nonce++
+	}
+	return nil
+}
+
+// getAuthorizations signs an authorization delegating every authority to the load test
+// contract with the next nonce of the authority.
+func (s *setCodeLoadTest) getAuthorizations(authorities []*setCodeAuthority) ([]ethtypes.SetCodeAuthorization, error) {
+	authList := make([]ethtypes.SetCodeAuthorization, 0, len(authorities))
+	for _, authority := range authorities {
+		auth, err := ethtypes.SignSetCode(authority.privateKey, ethtypes.SetCodeAuthorization{
+			ChainID: *uint256.NewInt(*inputLoadTestParams.ChainID),
+			Address: s.contract,
+			Nonce:   authority.nonce,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to sign the authorization")
+			return nil, err
+		}
+		authList = append(authList, auth)
+	}
+	return authList, nil
+}
+
+// take waits for the given number of authorities to be free.
+func (s *setCodeLoadTest) take(ctx context.Context, count uint64) ([]*setCodeAuthority, error) {
+	authorities := make([]*setCodeAuthority, 0, count)
+	for range count {
+		select {
+		case <-ctx.Done():
+			s.release(authorities)
+			return nil, ctx.Err()
+		case authority := <-s.authorities:
+			authorities = append(authorities, authority)
+		}
+	}
+	return authorities, nil
+}
+
+// release makes the authorities available to the other requests.
+func (s *setCodeLoadTest) release(authorities []*setCodeAuthority) {
+	for _, authority := range authorities {
+		s.authorities <- authority
+	}
+}
+
+// getSetCodeCallData returns the call of the load test contract made through the delegated
+// accounts.
+func getSetCodeCallData() ([]byte, error) {
+	ltABI, err := tester.LoadTesterMetaData.GetAbi()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get the load test contract abi")
+		return nil, err
+	}
+	data, err := ltABI.Pack("inc")
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to pack the load test contract call")
+		return nil, err
+	}
+	return data, nil
+}
+
+// getSetCodeGasLimit returns the gas limit of a set code transaction. eth_estimateGas can't
+// take the authorization list into account, so the gas limit covers the cost of every
+// authorization and the call through the delegated account.
+func getSetCodeGasLimit(authCount uint64) uint64 {
+	return params.TxGas + params.CallNewAccountGas*authCount + setCodeCallGas
+}
+
+// loadTestSetCode sends an EIP-7702 transaction whose authorization list renews the delegation
+// of some of the authorities to the load test contract, and calls the contract through the
+// first of them, so that the delegated code runs against the storage of the account. The
+// nonces of the authorities only move forward once the transaction is accepted. If it's
+// dropped later on, the next authorizations of its authorities are skipped by the chain, but
+// the calls still go through the existing delegations.
+func loadTestSetCode(ctx context.Context, c *ethclient.Client, account *loadTestAccount, nonce uint64, s *setCodeLoadTest) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
+	ltp := inputLoadTestParams
+
+	chainID := new(big.Int).SetUint64(*ltp.ChainID)
+	privateKey := account.PrivateKey
+
+	authorities, err := s.take(ctx, *ltp.SetCodeAuthorizations)
+	if err != nil {
+		return
+	}
+	defer s.release(authorities)
+	authList, err := s.getAuthorizations(authorities)
+	if err != nil {
+		return
+	}
+	data, err := getSetCodeCallData()
+	if err != nil {
+		return
+	}
+
+	gasLimit := getSetCodeGasLimit(uint64(len(authList)))
+	if *ltp.ForceGasLimit != 0 {
+		gasLimit = *ltp.ForceGasLimit
+	}
+	gasPrice, gasTipCap := getSuggestedGasPrices(ctx, c)
+	tx := ethtypes.NewTx(&ethtypes.SetCodeTx{
+		ChainID:   uint256.MustFromBig(chainID),
+		Nonce:     nonce,
+		GasTipCap: uint256.MustFromBig(gasTipCap),
+		GasFeeCap: uint256.MustFromBig(gasPrice),
+		Gas:       gasLimit,
+		To:        authorities[0].address,
+		Value:     uint256.NewInt(0),
+		Data:      data,
+		AuthList:  authList,
+	})
+	stx, err := ethtypes.SignTx(tx, ethtypes.LatestSignerForChainID(chainID), privateKey)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to sign transaction")
+		return
+	}
+	txHash = stx.Hash()
+
+	t1 = time.Now()
+	defer func() { t2 = time.Now() }()
+	if err = c.SendTransaction(ctx, stx); err != nil {
+		return
+	}
+	for _, authority := range authorities {
+		authority.nonce++
+	}
+	return
+}
@@ -0,0 +1,306 @@
+package loadtest
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	gethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/montanaflynn/stats"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// stateGrowthContractBin deploys the contract from contracts/src/asm/state-growth.easm.
+	// The contract takes the number of storage slots to write, of accounts to create and of
+	// contracts to deploy, along with a random seed. The slots and the accounts are derived
+	// from the seed, so they're fresh, and every contract gets a different code.
+	stateGrowthContractBin = "606580600b6000396000f36060356000355b8015601957600190038181018290556006565b506020355b8015603b5760019003600060006000600060018587015af150601e565b5050683060005260206000f36000526040355b801560635760019003600960176000f050604e565b00"
+
+	// These are estimates of the state added to the trie, counting the hashed keys and the
+	// encoded values of the leaves but not the intermediate nodes.
+	stateGrowthSlotBytes     = 64
+	stateGrowthAccountBytes  = 104
+	stateGrowthContractBytes = stateGrowthAccountBytes + 32
+
+	// stateGrowthBudgetPollInterval is how often the head is checked while waiting for the
+	// next block once the state growth budget of a block is used.
+	stateGrowthBudgetPollInterval = 250 * time.Millisecond
+)
+
+type (
+	// sentStateGrowthTx is a state growth transaction sent during the load test.
+	sentStateGrowthTx struct {
+		Hash ethcommon.Hash
+		Seed *big.Int
+	}
+
+	// stateGrowthLoadTest holds the contract used in state growth mode, the budget of the
+	// current block and the transactions that were sent.
+	stateGrowthLoadTest struct {
+		address ethcommon.Address
+
+		blockNumber uint64
+		budgetUsed  uint64
+
+		sent    []sentStateGrowthTx
+		mutex   sync.Mutex
+		summary *StateGrowthSummary
+	}
+
+	// StateGrowthSummary holds the state added by the included state growth transactions,
+	// and the depth of the eth_getProof proofs of a sample of the new accounts and slots.
+	StateGrowthSummary struct {
+		Sent              int
+		Included          int
+		Failed            int
+		Slots             uint64
+		Accounts          uint64
+		Contracts         uint64
+		Bytes             uint64
+		BytesPerBlock     float64
+		AccountProofDepth float64
+		MaxAccountProof   int
+		StorageProofDepth float64
+		MaxStorageProof   int
+	}
+
+	// accountProof is the subset of the eth_getProof response needed to measure the depth of
+	// the proofs.
+	accountProof struct {
+		AccountProof []string `json:"accountProof"`
+		StorageProof []struct {
+			Proof []string `json:"proof"`
+		} `json:"storageProof"`
+	}
+)
+
+var stateGrowth *stateGrowthLoadTest
+
+// initStateGrowthLoadTest deploys the contract used to grow the state.
+func initStateGrowthLoadTest(ctx context.Context, c *ethclient.Client, tops *bind.TransactOpts) (*stateGrowthLoadTest, error) {
+	address, tx, _, err := bind.DeployContract(tops, gethabi.ABI{}, ethcommon.FromHex(stateGrowthContractBin), c)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to deploy the state growth contract")
+		return nil, err
+	}
+	if _, err = bind.WaitDeployed(ctx, c, tx); err != nil {
+		log.Error().Err(err).Msg("Unable to wait for the state growth contract deployment")
+		return nil, err
+	}
+	log.Debug().Stringer("address", address).Msg("State growth contract deployed")
+	return &stateGrowthLoadTest{address: address}, nil
+}
+
+// getStateGrowthTxBytes returns the estimated amount of state added by a state growth
+// transaction.
+func getStateGrowthTxBytes() uint64 {
+	ltp := inputLoadTestParams
+	return *ltp.StateGrowthSlots*stateGrowthSlotBytes +
+		*ltp.StateGrowthAccounts*stateGrowthAccountBytes +
+		*ltp.StateGrowthContracts*stateGrowthContractBytes
+}
+
+// waitForBudget blocks until the transaction fits in the state growth budget of the current
+// block. The budget is counted against the head when the transaction is sent, which is an
+// approximation of the block that includes it. A transaction larger than the budget is still
+// sent once per block.
+func (s *stateGrowthLoadTest) waitForBudget(ctx context.Context, c *ethclient.Client, size uint64) error {
+	budget := *inputLoadTestParams.StateGrowthBytesPerBlock
+	if budget == 0 {
+		return nil
+	}
+	for {
+		s.mutex.Lock()
+		if s.budgetUsed == 0 || s.budgetUsed+size <= budget {
+			s.budgetUsed += size
+			s.mutex.Unlock()
+			return nil
+		}
+		s.mutex.Unlock()
+
+		blockNumber, err := c.BlockNumber(ctx)
+		if err != nil {
+			return err
+		}
+		s.mutex.Lock()
+		if blockNumber > s.blockNumber {
+			s.blockNumber = blockNumber
+			s.budgetUsed = 0
+		}
+		refreshed := s.budgetUsed == 0
+		s.mutex.Unlock()
+		if refreshed {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(stateGrowthBudgetPollInterval):
+		}
+	}
+}
+
+// loadTestStateGrowth calls the state growth contract to write fresh storage slots, create
+// new accounts and deploy new contracts.
+func loadTestStateGrowth(ctx context.Context, c *ethclient.Client, account *loadTestAccount, nonce uint64, s *stateGrowthLoadTest) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
+	ltp := inputLoadTestParams
+
+	chainID := new(big.Int).SetUint64(*ltp.ChainID)
+	tops, err := bind.NewKeyedTransactorWithChainID(account.PrivateKey, chainID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable create transaction signer")
+		return
+	}
+	tops.Nonce = new(big.Int).SetUint64(nonce)
+	// Every new account receives 1 wei from the contract.
+	tops.Value = new(big.Int).SetUint64(*ltp.StateGrowthAccounts)
+	tops = configureTransactOpts(ctx, c, tops)
+
+	var seedHash ethcommon.Hash
+	_, _ = randSrc.Read(seedHash[:])
+	seed := seedHash.Big()
+	data := make([]byte, 0, 128)
+	data = append(data, ethcommon.BigToHash(new(big.Int).SetUint64(*ltp.StateGrowthSlots)).Bytes()...)
+	data = append(data, ethcommon.BigToHash(new(big.Int).SetUint64(*ltp.StateGrowthAccounts)).Bytes()...)
+	data = append(data, ethcommon.BigToHash(new(big.Int).SetUint64(*ltp.StateGrowthContracts)).Bytes()...)
+	data = append(data, seedHash.Bytes()...)
+
+	if err = s.waitForBudget(ctx, c, getStateGrowthTxBytes()); err != nil {
+		return
+	}
+
+	t1 = time.Now()
+	defer func() { t2 = time.Now() }()
+	if *ltp.CallOnly {
+		msg := transactOptsToCallMsg(tops)
+		msg.To = &s.address
+		msg.Data = data
+		_, err = c.CallContract(ctx, msg, nil)
+		return
+	}
+
+	contract := bind.NewBoundContract(s.address, gethabi.ABI{}, c, c, c)
+	var tx *ethtypes.Transaction
+	tx, err = contract.RawTransact(tops, data)
+	if err != nil {
+		return
+	}
+	txHash = tx.Hash()
+
+	s.mutex.Lock()
+	s.sent = append(s.sent, sentStateGrowthTx{Hash: txHash, Seed: seed})
+	s.mutex.Unlock()
+	return
+}
+
+// resolve counts the state added by the included state growth transactions and samples the
+// depth of the proofs of the new accounts and of the new storage slots.
+func (s *stateGrowthLoadTest) resolve(ctx context.Context, c *ethclient.Client, rpc *ethrpc.Client) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ltp := inputLoadTestParams
+	summary := &StateGrowthSummary{}
+	included := make([]sentStateGrowthTx, 0, len(s.sent))
+	for _, tx := range s.sent {
+		summary.Sent++
+		receipt, err := c.TransactionReceipt(ctx, tx.Hash)
+		if err != nil {
+			log.Debug().Err(err).Stringer("txHash", tx.Hash).Msg("Unable to get the receipt of the state growth transaction")
+			continue
+		}
+		if receipt.Status != ethtypes.ReceiptStatusSuccessful {
+			summary.Failed++
+			continue
+		}
+		summary.Included++
+		included = append(included, tx)
+	}
+	summary.Slots = uint64(summary.Included) * *ltp.StateGrowthSlots
+	summary.Accounts = uint64(summary.Included) * *ltp.StateGrowthAccounts
+	summary.Contracts = uint64(summary.Included) * *ltp.StateGrowthContracts
+	summary.Bytes = uint64(summary.Included) * getStateGrowthTxBytes()
+	if finalBlockNumber > startBlockNumber {
+		summary.BytesPerBlock = float64(summary.Bytes) / float64(finalBlockNumber-startBlockNumber)
+	}
+	s.sampleProofs(ctx, rpc, included, summary)
+	s.summary = summary
+}
+
+// sampleProofs measures the depth of the eth_getProof proofs of new accounts, and of new
+// storage slots of the state growth contract, picked at random among the included
+// transactions.
+func (s *stateGrowthLoadTest) sampleProofs(ctx context.Context, rpc *ethrpc.Client, included []sentStateGrowthTx, summary *StateGrowthSummary) {
+	ltp := inputLoadTestParams
+	samples := *ltp.StateGrowthProofSamples
+	if samples == 0 || len(included) == 0 {
+		return
+	}
+
+	accountDepths := make([]float64, 0, samples)
+	storageDepths := make([]float64, 0, samples)
+	for k := uint64(0); k < samples; k++ {
+		tx := included[randSrc.Intn(len(included))]
+		if *ltp.StateGrowthAccounts > 0 {
+			// The new accounts are the seed and the following addresses, truncated to 20 bytes.
+			offset := big.NewInt(randSrc.Int63n(int64(*ltp.StateGrowthAccounts)))
+			address := ethcommon.BigToAddress(new(big.Int).Add(tx.Seed, offset))
+			var proof accountProof
+			if err := rpc.CallContext(ctx, &proof, "eth_getProof", address, []ethcommon.Hash{}, "latest"); err != nil {
+				log.Error().Err(err).Stringer("address", address).Msg("Unable to get the proof of the new account")
+				return
+			}
+			accountDepths = append(accountDepths, float64(len(proof.AccountProof)))
+			summary.MaxAccountProof = max(summary.MaxAccountProof, len(proof.AccountProof))
+		}
+		if *ltp.StateGrowthSlots > 0 {
+			offset := big.NewInt(randSrc.Int63n(int64(*ltp.StateGrowthSlots)))
+			slot := ethcommon.BigToHash(new(big.Int).Add(tx.Seed, offset))
+			var proof accountProof
+			if err := rpc.CallContext(ctx, &proof, "eth_getProof", s.address, []ethcommon.Hash{slot}, "latest"); err != nil {
+				log.Error().Err(err).Stringer("slot", slot).Msg("Unable to get the proof of the new storage slot")
+				return
+			}
+			if len(proof.StorageProof) == 0 {
+				continue
+			}
+			storageDepths = append(storageDepths, float64(len(proof.StorageProof[0].Proof)))
+			summary.MaxStorageProof = max(summary.MaxStorageProof, len(proof.StorageProof[0].Proof))
+		}
+	}
+	summary.AccountProofDepth, _ = stats.Mean(accountDepths)
+	summary.StorageProofDepth, _ = stats.Mean(storageDepths)
+}
+
+func stateGrowthLightSummary() {
+	if stateGrowth == nil || stateGrowth.summary == nil {
+		return
+	}
+	s := stateGrowth.summary
+	log.Info().
+		Int("sent", s.Sent).
+		Int("included", s.Included).
+		Int("failed", s.Failed).
+		Uint64("slots", s.Slots).
+		Uint64("accounts", s.Accounts).
+		Uint64("contracts", s.Contracts).
+		Uint64("bytes", s.Bytes).
+		Float64("bytesPerBlock", s.BytesPerBlock).
+		Msg("State Growth Stats")
+	if *inputLoadTestParams.StateGrowthProofSamples > 0 {
+		log.Info().
+			Float64("accountProofDepth", s.AccountProofDepth).
+			Int("maxAccountProofDepth", s.MaxAccountProof).
+			Float64("storageProofDepth", s.StorageProofDepth).
+			Int("maxStorageProofDepth", s.MaxStorageProof).
+			Msg("State Growth Proof Depths")
+	}
+}
@@ -64,6 +64,22 @@ func checkUniswapV3LoadtestFlags() error {
 	if (*uniswapv3LoadTestParams.UniswapPoolToken0 != "") != (*uniswapv3LoadTestParams.UniswapPoolToken1 != "") {
 		return errors.New("both pool tokens must be empty or specified. Specifying only one token is not allowed")
 	}
+
+	// Check the pools and the routes.
+	tokenCount := *uniswapv3LoadTestParams.Tokens
+	if tokenCount < 2 {
+		return errors.New("at least two tokens are required")
+	}
+	maxPools := tokenCount * (tokenCount - 1) / 2 * uint64(len(uniswapv3loadtest.FeeTiers))
+	if *uniswapv3LoadTestParams.Pools == 0 || *uniswapv3LoadTestParams.Pools > maxPools {
+		return fmt.Errorf("the number of pools needs to be between 1 and %d with %d tokens", maxPools, tokenCount)
+	}
+	if *uniswapv3LoadTestParams.MaxHops == 0 {
+		return errors.New("the maximum number of hops has to be greater than zero")
+	}
+	if ratio := *uniswapv3LoadTestParams.LiquidityRatio; ratio < 0 || ratio > 1 {
+		return fmt.Errorf("the liquidity ratio needs to be between 0 and 1. Given: %f", ratio)
+	}
 	return nil
 }
 
@@ -71,6 +87,8 @@ type uniswap3params struct {
 	UniswapFactoryV3, UniswapMulticall, UniswapProxyAdmin, UniswapTickLens, UniswapNFTLibDescriptor, UniswapNonfungibleTokenPositionDescriptor, UniswapUpgradeableProxy, UniswapNonfungiblePositionManager, UniswapMigrator, UniswapStaker, UniswapQuoterV2, UniswapSwapRouter, WETH9, UniswapPoolToken0, UniswapPoolToken1 *string
 	PoolFees                                                                                                                                                                                                                                                                                                                *float64
 	SwapAmountInput                                                                                                                                                                                                                                                                                                         *uint64
+	Tokens, Pools, MaxHops                                                                                                                                                                                                                                                                                                  *uint64
+	LiquidityRatio                                                                                                                                                                                                                                                                                                          *float64
 }
 
 func init() {
@@ -97,12 +115,16 @@ func init() {
 	// Pool and swap parameters.
 	params.PoolFees = uniswapV3LoadTestCmd.Flags().Float64P("pool-fees", "f", float64(uniswapv3loadtest.StandardTier), "Trading fees charged on each swap or trade made within a UniswapV3 liquidity pool (e.g. 0.3 means 0.3%)")
 	params.SwapAmountInput = uniswapV3LoadTestCmd.Flags().Uint64P("swap-amount", "a", uniswapv3loadtest.SwapAmountInput.Uint64(), "The amount of inbound token given as swap input")
+	params.Tokens = uniswapV3LoadTestCmd.Flags().Uint64("tokens", 2, "The number of ERC20 tokens traded. The first two tokens can be pre-deployed, the other ones are always deployed")
+	params.Pools = uniswapV3LoadTestCmd.Flags().Uint64("pools", 1, "The number of pools between the tokens. The pools of every token pair are created with --pool-fees first, then with the other fee tiers")
+	params.MaxHops = uniswapV3LoadTestCmd.Flags().Uint64("max-hops", 1, "The maximum number of pools that a swap is routed through. Swaps through more than one pool use exactInput with a random route")
+	params.LiquidityRatio = uniswapV3LoadTestCmd.Flags().Float64("liquidity-ratio", 0, "The fraction of requests that mint, increase, decrease or collect liquidity positions instead of swapping")
 
 	uniswapv3LoadTestParams = *params
 }
 
 // Initialise UniswapV3 loadtest.
-func initUniswapV3Loadtest(ctx context.Context, c *ethclient.Client, tops *bind.TransactOpts, cops *bind.CallOpts, uniswapAddresses uniswapv3loadtest.UniswapV3Addresses, recipient common.Address) (uniswapV3Config uniswapv3loadtest.UniswapV3Config, poolConfig uniswapv3loadtest.PoolConfig, err error) {
+func initUniswapV3Loadtest(ctx context.Context, c *ethclient.Client, tops *bind.TransactOpts, cops *bind.CallOpts, uniswapAddresses uniswapv3loadtest.UniswapV3Addresses, recipient common.Address) (uniswapV3Config uniswapv3loadtest.UniswapV3Config, pools []uniswapv3loadtest.PoolConfig, err error) {
 	log.Debug().Msg("🦄 Deploying UniswapV3 contracts...")
 	uniswapV3Config, err = uniswapv3loadtest.DeployUniswapV3(ctx, c, tops, cops, uniswapAddresses, recipient)
 	if err != nil {
@@ -111,18 +133,24 @@ func initUniswapV3Loadtest(ctx context.Context, c *ethclient.Client, tops *bind.
 	log.Debug().Interface("addresses", uniswapV3Config.GetAddresses()).Msg("UniswapV3 deployed")
 
 	log.Debug().Msg("🪙 Deploying ERC20 tokens...")
-	var token0 uniswapv3loadtest.ContractConfig[tokens.ERC20]
-	token0, err = uniswapv3loadtest.DeployERC20(
-		ctx, c, tops, cops, uniswapV3Config, "SwapperA", "SA", uniswapv3loadtest.MintAmount, recipient, common.HexToAddress(*uniswapv3LoadTestParams.UniswapPoolToken0))
-	if err != nil {
-		return
-	}
-
-	var token1 uniswapv3loadtest.ContractConfig[tokens.ERC20]
-	token1, err = uniswapv3loadtest.DeployERC20(
-		ctx, c, tops, cops, uniswapV3Config, "SwapperB", "SB", uniswapv3loadtest.MintAmount, recipient, common.HexToAddress(*uniswapv3LoadTestParams.UniswapPoolToken1))
-	if err != nil {
-		return
+	knownTokenAddresses := []string{*uniswapv3LoadTestParams.UniswapPoolToken0, *uniswapv3LoadTestParams.UniswapPoolToken1}
+	tokenConfigs := make([]uniswapv3loadtest.ContractConfig[tokens.ERC20], *uniswapv3LoadTestParams.Tokens)
+	for i := range tokenConfigs {
+		var knownAddress common.Address
+		if i < len(knownTokenAddresses) {
+			knownAddress = common.HexToAddress(knownTokenAddresses[i])
+		}
+		name := fmt.Sprintf("Swapper%c", 'A'+i)
+		symbol := fmt.Sprintf("S%c", 'A'+i)
+		if i >= 26 {
+			name = fmt.Sprintf("Swapper%d", i)
+			symbol = fmt.Sprintf("S%d", i)
+		}
+		tokenConfigs[i], err = uniswapv3loadtest.DeployERC20(
+			ctx, c, tops, cops, uniswapV3Config, name, symbol, uniswapv3loadtest.MintAmount, recipient, knownAddress)
+		if err != nil {
+			return
+		}
 	}
 
 	log.Info().
@@ -132,8 +160,8 @@ func initUniswapV3Loadtest(ctx context.Context, c *ethclient.Client, tops *bind.
 		Stringer("--uniswap-nft-descriptor-lib-address", uniswapV3Config.NFTDescriptorLib.Address).
 		Stringer("--uniswap-nft-position-descriptor-address", uniswapV3Config.NonfungibleTokenPositionDescriptor.Address).
 		Stringer("--uniswap-non-fungible-position-manager-address", uniswapV3Config.NonfungiblePositionManager.Address).
-		Stringer("--uniswap-pool-token-0-address", token0.Address).
-		Stringer("--uniswap-pool-token-1-address", token1.Address).
+		Stringer("--uniswap-pool-token-0-address", tokenConfigs[0].Address).
+		Stringer("--uniswap-pool-token-1-address", tokenConfigs[1].Address).
 		Stringer("--uniswap-proxy-admin-address", uniswapV3Config.ProxyAdmin.Address).
 		Stringer("--uniswap-quoter-v2-address", uniswapV3Config.QuoterV2.Address).
 		Stringer("--uniswap-staker-address", uniswapV3Config.Staker.Address).
@@ -142,25 +170,42 @@ func initUniswapV3Loadtest(ctx context.Context, c *ethclient.Client, tops *bind.
 		Stringer("--uniswap-upgradeable-proxy-address", uniswapV3Config.TransparentUpgradeableProxy.Address).
 		Stringer("--weth9-address", uniswapV3Config.WETH9.Address).Msg("Parameters to re-run")
 
-	fees := uniswapv3loadtest.PercentageToUniswapFeeTier(*uniswapv3LoadTestParams.PoolFees)
-	poolConfig = *uniswapv3loadtest.NewPool(token0, token1, fees)
-	if *uniswapv3LoadTestParams.UniswapPoolToken0 != "" {
-		return
+	// The pools are created with the fee tier given by --pool-fees first.
+	fees := []*big.Int{uniswapv3loadtest.PercentageToUniswapFeeTier(*uniswapv3LoadTestParams.PoolFees)}
+	for _, tier := range uniswapv3loadtest.FeeTiers {
+		if float64(tier) != *uniswapv3LoadTestParams.PoolFees {
+			fees = append(fees, uniswapv3loadtest.PercentageToUniswapFeeTier(float64(tier)))
+		}
 	}
-	if err = uniswapv3loadtest.SetupLiquidityPool(ctx, c, tops, cops, uniswapV3Config, poolConfig, recipient); err != nil {
+	pools, err = uniswapv3loadtest.NewPools(tokenConfigs, fees, int(*uniswapv3LoadTestParams.Pools))
+	if err != nil {
 		return
 	}
+	for i := range pools {
+		// The first pool already exists when the pool tokens are pre-deployed.
+		if i > 0 || *uniswapv3LoadTestParams.UniswapPoolToken0 == "" {
+			if err = uniswapv3loadtest.SetupLiquidityPool(ctx, c, tops, cops, uniswapV3Config, pools[i], recipient); err != nil {
+				return
+			}
+		}
+		pools[i].PositionID, err = uniswapv3loadtest.GetPositionID(cops, uniswapV3Config.NonfungiblePositionManager.Contract, pools[i], recipient)
+		if err != nil {
+			return
+		}
+	}
+	log.Debug().Int("tokens", len(tokenConfigs)).Int("pools", len(pools)).Msg("UniswapV3 pools ready")
 	return
 }
 
-// Run UniswapV3 loadtest.
-func runUniswapV3Loadtest(ctx context.Context, c *ethclient.Client, nonce uint64, uniswapV3Config uniswapv3loadtest.UniswapV3Config, poolConfig uniswapv3loadtest.PoolConfig, swapAmountIn *big.Int) (t1 time.Time, t2 time.Time, txHash common.Hash, err error) {
+// Run UniswapV3 loadtest. Every request either swaps through a random route of pools or, depending
+// on the liquidity ratio, operates on a liquidity position of a random pool.
+func runUniswapV3Loadtest(ctx context.Context, c *ethclient.Client, account *loadTestAccount, nonce uint64, uniswapV3Config uniswapv3loadtest.UniswapV3Config, pools []uniswapv3loadtest.PoolConfig, swapAmountIn *big.Int) (t1 time.Time, t2 time.Time, txHash common.Hash, err error) {
 	var tops *bind.TransactOpts
 	var tx *ethtypes.Transaction
 
 	ltp := inputLoadTestParams
 	chainID := new(big.Int).SetUint64(*ltp.ChainID)
-	privateKey := ltp.ECDSAPrivateKey
+	privateKey := account.PrivateKey
 
 	tops, err = bind.NewKeyedTransactorWithChainID(privateKey, chainID)
 	if err != nil {
@@ -172,7 +217,15 @@ func runUniswapV3Loadtest(ctx context.Context, c *ethclient.Client, nonce uint64
 
 	t1 = time.Now()
 	defer func() { t2 = time.Now() }()
-	tx, err = uniswapv3loadtest.ExactInputSingleSwap(tops, uniswapV3Config.SwapRouter02.Contract, poolConfig, swapAmountIn, *ltp.FromETHAddress, nonce)
+	if ratio := *uniswapv3LoadTestParams.LiquidityRatio; ratio > 0 && randSrc.Float64() < ratio {
+		operation := uniswapv3loadtest.LiquidityOperations[randSrc.Intn(len(uniswapv3loadtest.LiquidityOperations))]
+		tx, err = uniswapv3loadtest.ModifyLiquidity(tops, uniswapV3Config.NonfungiblePositionManager.Contract, pools[randSrc.Intn(len(pools))], operation, swapAmountIn, account.Address)
+	} else if len(pools) == 1 || *uniswapv3LoadTestParams.MaxHops == 1 {
+		tx, err = uniswapv3loadtest.ExactInputSingleSwap(tops, uniswapV3Config.SwapRouter02.Contract, pools[randSrc.Intn(len(pools))], swapAmountIn, account.Address, nonce)
+	} else {
+		route := uniswapv3loadtest.GetRandomRoute(pools, int(*uniswapv3LoadTestParams.MaxHops), randSrc)
+		tx, err = uniswapv3loadtest.ExactInputSwap(tops, uniswapV3Config.SwapRouter02.Contract, route, swapAmountIn, account.Address)
+	}
 	if err == nil && tx != nil {
 		txHash = tx.Hash()
 	}
@@ -0,0 +1,88 @@
+package uniswapv3loadtest
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/0xPolygon/polygon-cli/bindings/uniswapv3"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// LiquidityOperation is an operation on a liquidity position through the NonfungiblePositionManager.
+type LiquidityOperation string
+
+const (
+	MintOperation              LiquidityOperation = "mint"
+	IncreaseLiquidityOperation LiquidityOperation = "increase"
+	DecreaseLiquidityOperation LiquidityOperation = "decrease"
+	CollectOperation           LiquidityOperation = "collect"
+)
+
+// LiquidityOperations lists the operations on liquidity positions.
+var LiquidityOperations = []LiquidityOperation{MintOperation, IncreaseLiquidityOperation, DecreaseLiquidityOperation, CollectOperation}
+
+// The largest amount of fees that can be collected, which is the maximum uint128.
+var maxCollectAmount = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+
+// ModifyLiquidity performs an operation on a liquidity position of the pool. Mints create a new full
+// range position while the other operations use the position of the recipient in the pool, which
+// falls back to a mint if there's none. The amount is used both as the amount of each token
+// provided and as the liquidity removed.
+func ModifyLiquidity(tops *bind.TransactOpts, nftPositionManagerContract *uniswapv3.NonfungiblePositionManager, poolConfig PoolConfig, operation LiquidityOperation, amount *big.Int, recipient common.Address) (tx *types.Transaction, err error) {
+	if poolConfig.PositionID == nil {
+		operation = MintOperation
+	}
+	// The block timestamp isn't fetched to avoid an extra call on every request.
+	deadline := big.NewInt(time.Now().Add(mintOperationTimeout).Unix())
+
+	switch operation {
+	case MintOperation:
+		tickLower, tickUpper := getFullRangeTicks(poolConfig.TickSpacing)
+		tx, err = nftPositionManagerContract.Mint(tops, uniswapv3.INonfungiblePositionManagerMintParams{
+			Token0:         poolConfig.Token0.Address,
+			Token1:         poolConfig.Token1.Address,
+			Fee:            poolConfig.Fees,
+			TickLower:      tickLower,
+			TickUpper:      tickUpper,
+			Amount0Desired: amount,
+			Amount1Desired: amount,
+			Amount0Min:     big.NewInt(0),
+			Amount1Min:     big.NewInt(0),
+			Recipient:      recipient,
+			Deadline:       deadline,
+		})
+	case IncreaseLiquidityOperation:
+		tx, err = nftPositionManagerContract.IncreaseLiquidity(tops, uniswapv3.INonfungiblePositionManagerIncreaseLiquidityParams{
+			TokenId:        poolConfig.PositionID,
+			Amount0Desired: amount,
+			Amount1Desired: amount,
+			Amount0Min:     big.NewInt(0),
+			Amount1Min:     big.NewInt(0),
+			Deadline:       deadline,
+		})
+	case DecreaseLiquidityOperation:
+		tx, err = nftPositionManagerContract.DecreaseLiquidity(tops, uniswapv3.INonfungiblePositionManagerDecreaseLiquidityParams{
+			TokenId:    poolConfig.PositionID,
+			Liquidity:  amount,
+			Amount0Min: big.NewInt(0),
+			Amount1Min: big.NewInt(0),
+			Deadline:   deadline,
+		})
+	case CollectOperation:
+		tx, err = nftPositionManagerContract.Collect(tops, uniswapv3.INonfungiblePositionManagerCollectParams{
+			TokenId:    poolConfig.PositionID,
+			Recipient:  recipient,
+			Amount0Max: maxCollectAmount,
+			Amount1Max: maxCollectAmount,
+		})
+	}
+	if err != nil {
+		log.Error().Err(err).Str("operation", string(operation)).Interface("fees", poolConfig.Fees).Msg("Unable to modify liquidity")
+		return
+	}
+	log.Trace().Str("operation", string(operation)).Interface("fees", poolConfig.Fees).Interface("amount", amount).Msg("Successful liquidity operation")
+	return
+}
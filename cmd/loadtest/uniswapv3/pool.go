@@ -3,6 +3,7 @@ package uniswapv3loadtest
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/0xPolygon/polygon-cli/bindings/tokens"
 	"math/big"
 	"time"
@@ -36,6 +37,16 @@ var (
 	ExoticTier   feeTier = 1    // 10_000
 )
 
+// FeeTiers lists the fee tiers supported by UniswapV3.
+var FeeTiers = []feeTier{StableTier, StandardTier, ExoticTier}
+
+// The tick spacing of the pools of every fee tier, as enabled by the UniswapV3 factory.
+var feeTickSpacing = map[int64]int64{
+	500:    10,
+	3_000:  60,
+	10_000: 200,
+}
+
 // PercentageToUniswapFeeTier takes a percentage and returns the corresponding UniswapV3 fee tier.
 func PercentageToUniswapFeeTier(p float64) *big.Int {
 	var fees int64
@@ -55,14 +66,19 @@ type PoolConfig struct {
 	Token0, Token1     ContractConfig[tokens.ERC20]
 	ReserveA, ReserveB *big.Int
 	Fees               *big.Int
+	TickSpacing        *big.Int
+
+	// The liquidity position of the recipient in the pool, if any.
+	PositionID *big.Int
 }
 
 // Create a new `PoolConfig` object.
 func NewPool(token0, token1 ContractConfig[tokens.ERC20], fees *big.Int) *PoolConfig {
 	p := PoolConfig{
-		ReserveA: poolReserveForOneToken,
-		ReserveB: poolReserveForOneToken,
-		Fees:     fees,
+		ReserveA:    poolReserveForOneToken,
+		ReserveB:    poolReserveForOneToken,
+		Fees:        fees,
+		TickSpacing: big.NewInt(feeTickSpacing[fees.Int64()]),
 	}
 
 	// Make sure the token pair is sorted.
@@ -77,6 +93,51 @@ func NewPool(token0, token1 ContractConfig[tokens.ERC20], fees *big.Int) *PoolCo
 	return &p
 }
 
+// NewPools returns the configurations of count pools between the given tokens. The pools of
+// every token pair are created with the first fee tier, then with the next fee tiers until
+// there are enough pools.
+func NewPools(tokenConfigs []ContractConfig[tokens.ERC20], fees []*big.Int, count int) ([]PoolConfig, error) {
+	pools := make([]PoolConfig, 0, count)
+	for _, fee := range fees {
+		for i := range tokenConfigs {
+			for j := i + 1; j < len(tokenConfigs); j++ {
+				if len(pools) == count {
+					return pools, nil
+				}
+				pools = append(pools, *NewPool(tokenConfigs[i], tokenConfigs[j], fee))
+			}
+		}
+	}
+	if len(pools) < count {
+		return nil, fmt.Errorf("%d tokens and %d fee tiers only allow %d pools", len(tokenConfigs), len(fees), len(pools))
+	}
+	return pools, nil
+}
+
+// GetPositionID returns the id of a liquidity position of the owner in the pool, or nil if the
+// owner doesn't have any.
+func GetPositionID(cops *bind.CallOpts, nftPositionManagerContract *uniswapv3.NonfungiblePositionManager, poolConfig PoolConfig, owner common.Address) (*big.Int, error) {
+	balance, err := nftPositionManagerContract.BalanceOf(cops, owner)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get the number of liquidity positions")
+		return nil, err
+	}
+	for i := int64(0); i < balance.Int64(); i++ {
+		id, err := nftPositionManagerContract.TokenOfOwnerByIndex(cops, owner, big.NewInt(i))
+		if err != nil {
+			return nil, err
+		}
+		position, err := nftPositionManagerContract.Positions(cops, id)
+		if err != nil {
+			return nil, err
+		}
+		if position.Token0 == poolConfig.Token0.Address && position.Token1 == poolConfig.Token1.Address && position.Fee.Cmp(poolConfig.Fees) == 0 {
+			return id, nil
+		}
+	}
+	return nil, nil
+}
+
 // slot represents the state of a UniswapV3 pool.
 type slot struct {
 	SqrtPriceX96               *big.Int
@@ -195,11 +256,7 @@ func provideLiquidity(ctx context.Context, c *ethclient.Client, tops *bind.Trans
 		log.Error().Err(err).Msg("Unable to get tick spacing")
 		return err
 	}
-	// tickUpper = (MAX_TICK / tickSpacing) * tickSpacing
-	tickUpper := new(big.Int).Div(big.NewInt(maxTick), tickSpacing)
-	tickUpper.Mul(tickUpper, tickSpacing)
-	// tickLower = - tickUpper
-	tickLower := new(big.Int).Neg(tickUpper)
+	tickLower, tickUpper := getFullRangeTicks(tickSpacing)
 
 	// Compute deadline.
 	var latestBlockTimestamp *big.Int
@@ -270,6 +327,16 @@ func provideLiquidity(ctx context.Context, c *ethclient.Client, tops *bind.Trans
 	return nil
 }
 
+// getFullRangeTicks returns the lowest and highest ticks divisible by the tick spacing.
+func getFullRangeTicks(tickSpacing *big.Int) (tickLower, tickUpper *big.Int) {
+	// tickUpper = (MAX_TICK / tickSpacing) * tickSpacing
+	tickUpper = new(big.Int).Div(big.NewInt(maxTick), tickSpacing)
+	tickUpper.Mul(tickUpper, tickSpacing)
+	// tickLower = - tickUpper
+	tickLower = new(big.Int).Neg(tickUpper)
+	return
+}
+
 // Get the timestamp of the latest block.
 func getLatestBlockTimestamp(ctx context.Context, c *ethclient.Client) (*big.Int, error) {
 	blockHead, err := c.HeaderByNumber(ctx, nil)
@@ -3,6 +3,7 @@ package uniswapv3loadtest
 import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"math/big"
+	"math/rand"
 
 	"github.com/0xPolygon/polygon-cli/bindings/uniswapv3"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -21,11 +22,7 @@ func ExactInputSingleSwap(tops *bind.TransactOpts, swapRouter *uniswapv3.SwapRou
 	swapDirection := getSwapDirection(nonce, poolConfig)
 
 	// Perform swap.
-	slippageFactor := new(big.Float).SetFloat64(0.75)
-	amountInFloat := new(big.Float).SetInt(amountIn)
-	amountInFloat.Mul(amountInFloat, slippageFactor)
-	amountOut := new(big.Int)
-	amountInFloat.Int(amountOut)
+	amountOut := getAmountOutMinimum(amountIn)
 
 	tx, err = swapRouter.ExactInputSingle(tops, uniswapv3.IV3SwapRouterExactInputSingleParams{
 		// The contract address of the inbound token.
@@ -53,6 +50,94 @@ func ExactInputSingleSwap(tops *bind.TransactOpts, swapRouter *uniswapv3.SwapRou
 	return
 }
 
+// ExactInputSwap performs a multi-hop UniswapV3 swap using the `ExactInput` method which swaps a fixed
+// amount of the first token of the route for a maximum possible amount of its last token.
+func ExactInputSwap(tops *bind.TransactOpts, swapRouter *uniswapv3.SwapRouter02, route Route, amountIn *big.Int, recipient common.Address) (tx *types.Transaction, err error) {
+	tx, err = swapRouter.ExactInput(tops, uniswapv3.IV3SwapRouterExactInputParams{
+		// The tokens of the route and the fee tiers of the pools between them.
+		Path: route.encodePath(),
+		// The destination address of the outbound token.
+		Recipient: recipient,
+		// The amount of inbound token given as swap input.
+		AmountIn: amountIn,
+		// The minimum amount of outbound token received as swap output.
+		AmountOutMinimum: getAmountOutMinimum(amountIn),
+	})
+	if err != nil {
+		log.Error().Err(err).Int("hops", len(route.Fees)).Interface("amountIn", amountIn).Msg("Unable to swap")
+		return
+	}
+	log.Trace().Int("hops", len(route.Fees)).Interface("amountIn", amountIn).Msg("Successful swap")
+	return
+}
+
+// getAmountOutMinimum returns the minimum amount of outbound token accepted for a swap, allowing for
+// 25% of slippage.
+func getAmountOutMinimum(amountIn *big.Int) *big.Int {
+	slippageFactor := new(big.Float).SetFloat64(0.75)
+	amountInFloat := new(big.Float).SetInt(amountIn)
+	amountInFloat.Mul(amountInFloat, slippageFactor)
+	amountOut := new(big.Int)
+	amountInFloat.Int(amountOut)
+	return amountOut
+}
+
+// Route represents the tokens that a multi-hop swap goes through and the fee tiers of the pools
+// between them.
+type Route struct {
+	Tokens []common.Address
+	Fees   []*big.Int
+}
+
+// GetRandomRoute returns a route of at most maxHops pools. The route starts from a random pool and
+// is extended with pools that don't go back to a token that was already visited.
+func GetRandomRoute(pools []PoolConfig, maxHops int, r *rand.Rand) Route {
+	first := pools[r.Intn(len(pools))]
+	route := Route{
+		Tokens: []common.Address{first.Token0.Address, first.Token1.Address},
+		Fees:   []*big.Int{first.Fees},
+	}
+	if r.Intn(2) == 0 {
+		route.Tokens[0], route.Tokens[1] = route.Tokens[1], route.Tokens[0]
+	}
+	visited := map[common.Address]bool{route.Tokens[0]: true, route.Tokens[1]: true}
+
+	hops := 1 + r.Intn(maxHops)
+	for len(route.Fees) < hops {
+		last := route.Tokens[len(route.Tokens)-1]
+		candidates := make([]Route, 0)
+		for _, p := range pools {
+			if p.Token0.Address == last && !visited[p.Token1.Address] {
+				candidates = append(candidates, Route{Tokens: []common.Address{p.Token1.Address}, Fees: []*big.Int{p.Fees}})
+			} else if p.Token1.Address == last && !visited[p.Token0.Address] {
+				candidates = append(candidates, Route{Tokens: []common.Address{p.Token0.Address}, Fees: []*big.Int{p.Fees}})
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		next := candidates[r.Intn(len(candidates))]
+		route.Tokens = append(route.Tokens, next.Tokens[0])
+		route.Fees = append(route.Fees, next.Fees[0])
+		visited[next.Tokens[0]] = true
+	}
+	return route
+}
+
+// encodePath encodes the route in the format expected by the swap router: every token address is
+// followed by the 3-byte fee tier of the pool to the next token.
+func (r Route) encodePath() []byte {
+	path := make([]byte, 0, len(r.Tokens)*common.AddressLength+len(r.Fees)*3)
+	for i, token := range r.Tokens {
+		path = append(path, token.Bytes()...)
+		if i < len(r.Fees) {
+			fee := r.Fees[i].Uint64()
+			path = append(path, byte(fee>>16), byte(fee>>8), byte(fee))
+		}
+	}
+	return path
+}
+
 // swapDirection represents a swap direction with the inbound and outbound tokens.
 type uniswapDirection struct {
 	tokenIn, tokenOut         common.Address
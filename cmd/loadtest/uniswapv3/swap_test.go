@@ -0,0 +1,99 @@
+package uniswapv3loadtest
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/0xPolygon/polygon-cli/bindings/tokens"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEncodePath tests the encoding of the routes of the multi-hop swaps, where every token is
+// followed by the 3-byte fee tier of the next pool.
+func TestEncodePath(t *testing.T) {
+	type Test struct {
+		Name     string
+		Route    Route
+		Expected string
+	}
+
+	tokenA := common.HexToAddress("0x000000000000000000000000000000000000000a")
+	tokenB := common.HexToAddress("0x000000000000000000000000000000000000000b")
+	tokenC := common.HexToAddress("0x000000000000000000000000000000000000000c")
+	tests := []Test{
+		{
+			Name:     "single hop",
+			Route:    Route{Tokens: []common.Address{tokenA, tokenB}, Fees: []*big.Int{big.NewInt(3000)}},
+			Expected: "000000000000000000000000000000000000000a000bb8000000000000000000000000000000000000000b",
+		},
+		{
+			Name:     "two hops",
+			Route:    Route{Tokens: []common.Address{tokenA, tokenB, tokenC}, Fees: []*big.Int{big.NewInt(500), big.NewInt(10000)}},
+			Expected: "000000000000000000000000000000000000000a0001f4000000000000000000000000000000000000000b002710000000000000000000000000000000000000000c",
+		},
+		{
+			Name:     "largest fee tier",
+			Route:    Route{Tokens: []common.Address{tokenC, tokenA}, Fees: []*big.Int{big.NewInt(0xffffff)}},
+			Expected: "000000000000000000000000000000000000000cffffff000000000000000000000000000000000000000a",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, common.FromHex(tc.Expected), tc.Route.encodePath())
+		})
+	}
+}
+
+// TestGetRandomRoute tests that the random routes follow existing pools, never visit a token
+// twice and stay within the maximum number of hops.
+func TestGetRandomRoute(t *testing.T) {
+	type Test struct {
+		Name    string
+		Pools   [][2]int64
+		MaxHops int
+	}
+
+	tests := []Test{
+		{Name: "single pool", Pools: [][2]int64{{1, 2}}, MaxHops: 3},
+		{Name: "chain of pools", Pools: [][2]int64{{1, 2}, {2, 3}, {3, 4}}, MaxHops: 3},
+		{Name: "triangle", Pools: [][2]int64{{1, 2}, {2, 3}, {1, 3}}, MaxHops: 3},
+		{Name: "star", Pools: [][2]int64{{1, 2}, {1, 3}, {1, 4}, {1, 5}}, MaxHops: 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			pools := make([]PoolConfig, 0, len(tc.Pools))
+			fees := make(map[[2]common.Address]*big.Int)
+			for k, p := range tc.Pools {
+				token0 := ContractConfig[tokens.ERC20]{Address: common.BigToAddress(big.NewInt(p[0]))}
+				token1 := ContractConfig[tokens.ERC20]{Address: common.BigToAddress(big.NewInt(p[1]))}
+				fee := big.NewInt(int64(100 * (k + 1)))
+				pools = append(pools, PoolConfig{Token0: token0, Token1: token1, Fees: fee})
+				fees[[2]common.Address{token0.Address, token1.Address}] = fee
+				fees[[2]common.Address{token1.Address, token0.Address}] = fee
+			}
+
+			r := rand.New(rand.NewSource(1))
+			for range 100 {
+				route := GetRandomRoute(pools, tc.MaxHops, r)
+				assert.Len(t, route.Tokens, len(route.Fees)+1)
+				assert.GreaterOrEqual(t, len(route.Fees), 1)
+				assert.LessOrEqual(t, len(route.Fees), tc.MaxHops)
+
+				visited := make(map[common.Address]bool)
+				for i, token := range route.Tokens {
+					assert.False(t, visited[token], "token %s visited twice", token)
+					visited[token] = true
+					if i < len(route.Fees) {
+						fee, isPool := fees[[2]common.Address{token, route.Tokens[i+1]}]
+						assert.True(t, isPool, "no pool between %s and %s", token, route.Tokens[i+1])
+						assert.Equal(t, fee, route.Fees[i])
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,514 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	gethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/montanaflynn/stats"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// The canonical deployments of the v0.7 EntryPoint and SimpleAccountFactory.
+	defaultEntryPointAddress     = "0x0000000071727De22E5E9d8BAf0edAc6f37da032"
+	defaultAccountFactoryAddress = "0x91E60e0613810449d098b0b5Ec8b51A0FE8c8985"
+
+	userOpVerificationGasLimit = 150000
+	userOpCallGasLimit         = 100000
+	userOpPreVerificationGas   = 60000
+
+	entryPointABI = `[
+		{"type":"function","name":"handleOps","stateMutability":"nonpayable","inputs":[{"name":"ops","type":"tuple[]","components":[{"name":"sender","type":"address"},{"name":"nonce","type":"uint256"},{"name":"initCode","type":"bytes"},{"name":"callData","type":"bytes"},{"name":"accountGasLimits","type":"bytes32"},{"name":"preVerificationGas","type":"uint256"},{"name":"gasFees","type":"bytes32"},{"name":"paymasterAndData","type":"bytes"},{"name":"signature","type":"bytes"}]},{"name":"beneficiary","type":"address"}],"outputs":[]},
+		{"type":"function","name":"depositTo","stateMutability":"payable","inputs":[{"name":"account","type":"address"}],"outputs":[]},
+		{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"account","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+		{"type":"event","name":"UserOperationEvent","inputs":[{"name":"userOpHash","type":"bytes32","indexed":true},{"name":"sender","type":"address","indexed":true},{"name":"paymaster","type":"address","indexed":true},{"name":"nonce","type":"uint256","indexed":false},{"name":"success","type":"bool","indexed":false},{"name":"actualGasCost","type":"uint256","indexed":false},{"name":"actualGasUsed","type":"uint256","indexed":false}]},
+		{"type":"event","name":"UserOperationRevertReason","inputs":[{"name":"userOpHash","type":"bytes32","indexed":true},{"name":"sender","type":"address","indexed":true},{"name":"nonce","type":"uint256","indexed":false},{"name":"revertReason","type":"bytes","indexed":false}]}
+	]`
+	accountFactoryABI = `[
+		{"type":"function","name":"createAccount","stateMutability":"nonpayable","inputs":[{"name":"owner","type":"address"},{"name":"salt","type":"uint256"}],"outputs":[{"name":"ret","type":"address"}]},
+		{"type":"function","name":"getAddress","stateMutability":"view","inputs":[{"name":"owner","type":"address"},{"name":"salt","type":"uint256"}],"outputs":[{"name":"","type":"address"}]}
+	]`
+	smartAccountABI = `[
+		{"type":"function","name":"execute","stateMutability":"nonpayable","inputs":[{"name":"dest","type":"address"},{"name":"value","type":"uint256"},{"name":"func","type":"bytes"}],"outputs":[]}
+	]`
+)
+
+// entryPointErrorCode matches the error codes of the EntryPoint, such as "AA21 didn't pay
+// prefund".
+var entryPointErrorCode = regexp.MustCompile(`AA\d\d[^"]*`)
+
+type (
+	// packedUserOperation is the v0.7 UserOperation passed to handleOps.
+	packedUserOperation struct {
+		Sender             ethcommon.Address
+		Nonce              *big.Int
+		InitCode           []byte
+		CallData           []byte
+		AccountGasLimits   [32]byte
+		PreVerificationGas *big.Int
+		GasFees            [32]byte
+		PaymasterAndData   []byte
+		Signature          []byte
+	}
+
+	// rpcUserOperation is the v0.7 UserOperation sent to a bundler with eth_sendUserOperation.
+	rpcUserOperation struct {
+		Sender               ethcommon.Address `json:"sender"`
+		Nonce                *hexutil.Big      `json:"nonce"`
+		CallData             hexutil.Bytes     `json:"callData"`
+		CallGasLimit         *hexutil.Big      `json:"callGasLimit"`
+		VerificationGasLimit *hexutil.Big      `json:"verificationGasLimit"`
+		PreVerificationGas   *hexutil.Big      `json:"preVerificationGas"`
+		MaxFeePerGas         *hexutil.Big      `json:"maxFeePerGas"`
+		MaxPriorityFeePerGas *hexutil.Big      `json:"maxPriorityFeePerGas"`
+		Signature            hexutil.Bytes     `json:"signature"`
+	}
+
+	userOpReceipt struct {
+		Success bool   `json:"success"`
+		Reason  string `json:"reason"`
+		Receipt struct {
+			TransactionHash ethcommon.Hash `json:"transactionHash"`
+			BlockNumber     hexutil.Uint64 `json:"blockNumber"`
+		} `json:"receipt"`
+	}
+
+	// sentUserOp is a UserOperation sent during the load test. The bundle transaction is
+	// known right away when the load test bundles the UserOperations itself.
+	sentUserOp struct {
+		Hash     ethcommon.Hash
+		SentTime time.Time
+		BundleTx ethcommon.Hash
+	}
+
+	// userOpLoadTest holds the contracts and the smart accounts used in userop mode.
+	userOpLoadTest struct {
+		entryPoint     *bind.BoundContract
+		entryPointAddr ethcommon.Address
+		entryPointABI  gethabi.ABI
+		accountABI     gethabi.ABI
+		smartAccounts  map[ethcommon.Address]ethcommon.Address
+		bundler        *ethrpc.Client
+
+		sent         []sentUserOp
+		sendFailures map[string]int
+		mutex        sync.Mutex
+		summary      *UserOpSummary
+	}
+
+	// UserOpSummary holds the outcome of the UserOperations and the percentiles, in seconds,
+	// of the time between sending a UserOperation and seeing its bundle in a block.
+	UserOpSummary struct {
+		Sent           int
+		Included       int
+		Failed         int
+		Missing        int
+		P50            float64
+		P90            float64
+		P99            float64
+		FailureReasons map[string]int
+	}
+)
+
+var userOps *userOpLoadTest
+
+// checkUserOpContracts makes sure the EntryPoint and the account factory are deployed before
+// anything is sent. Deploying them isn't supported yet, since polycli doesn't ship the bytecode
+// of the v0.7 EntryPoint and SimpleAccountFactory, so they need to be deployed beforehand.
+func checkUserOpContracts(ctx context.Context, c *ethclient.Client) error {
+	ltp := inputLoadTestParams
+	contracts := []struct {
+		name string
+		flag string
+		addr ethcommon.Address
+	}{
+		{"v0.7 EntryPoint", "--entrypoint-address", ethcommon.HexToAddress(*ltp.EntryPointAddress)},
+		{"SimpleAccountFactory", "--account-factory-address", ethcommon.HexToAddress(*ltp.AccountFactoryAddress)},
+	}
+	for _, contract := range contracts {
+		code, err := c.CodeAt(ctx, contract.addr, nil)
+		if err != nil {
+			return err
+		}
+		if len(code) == 0 {
+			return fmt.Errorf("userop mode requires a %s contract, but there is none at %s. The load test can't deploy it yet, so deploy it beforehand, e.g. with the eth-infinitism/account-abstraction deployment scripts, and give its address with `%s`", contract.name, contract.addr, contract.flag)
+		}
+	}
+	return nil
+}
+
+// initUserOpLoadTest creates and funds a smart account owned by every sending account.
+func initUserOpLoadTest(ctx context.Context, c *ethclient.Client, tops *bind.TransactOpts) (*userOpLoadTest, error) {
+	ltp := inputLoadTestParams
+	entryPointAddr := ethcommon.HexToAddress(*ltp.EntryPointAddress)
+	factoryAddr := ethcommon.HexToAddress(*ltp.AccountFactoryAddress)
+
+	u := &userOpLoadTest{
+		entryPointAddr: entryPointAddr,
+		smartAccounts:  make(map[ethcommon.Address]ethcommon.Address),
+		sendFailures:   make(map[string]int),
+	}
+	var err error
+	if u.entryPointABI, err = gethabi.JSON(strings.NewReader(entryPointABI)); err != nil {
+		return nil, err
+	}
+	if u.accountABI, err = gethabi.JSON(strings.NewReader(smartAccountABI)); err != nil {
+		return nil, err
+	}
+	factoryABI, err := gethabi.JSON(strings.NewReader(accountFactoryABI))
+	if err != nil {
+		return nil, err
+	}
+	u.entryPoint = bind.NewBoundContract(entryPointAddr, u.entryPointABI, c, c, c)
+	factory := bind.NewBoundContract(factoryAddr, factoryABI, c, c, c)
+
+	if *ltp.BundlerURL != "" {
+		if u.bundler, err = ethrpc.DialContext(ctx, *ltp.BundlerURL); err != nil {
+			log.Error().Err(err).Msg("Unable to dial the bundler")
+			return nil, err
+		}
+	}
+
+	deposit := new(big.Int)
+	new(big.Float).Mul(big.NewFloat(*ltp.UserOpDeposit), big.NewFloat(1e18)).Int(deposit)
+	for _, account := range sendingAccounts {
+		var out []interface{}
+		if err = factory.Call(&bind.CallOpts{Context: ctx}, &out, "getAddress", account.Address, big.NewInt(0)); err != nil {
+			log.Error().Err(err).Msg("Unable to get the smart account address")
+			return nil, err
+		}
+		smartAccount := *gethabi.ConvertType(out[0], new(ethcommon.Address)).(*ethcommon.Address)
+		u.smartAccounts[account.Address] = smartAccount
+
+		code, err := c.CodeAt(ctx, smartAccount, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(code) == 0 {
+			tx, err := factory.Transact(tops, "createAccount", account.Address, big.NewInt(0))
+			if err != nil {
+				log.Error().Err(err).Msg("Unable to create the smart account")
+				return nil, err
+			}
+			if _, err = bind.WaitMined(ctx, c, tx); err != nil {
+				return nil, err
+			}
+		}
+
+		out = nil
+		if err = u.entryPoint.Call(&bind.CallOpts{Context: ctx}, &out, "balanceOf", smartAccount); err != nil {
+			log.Error().Err(err).Msg("Unable to get the smart account deposit")
+			return nil, err
+		}
+		balance := *gethabi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+		if balance.Cmp(deposit) < 0 {
+			depositOpts := *tops
+			depositOpts.Value = new(big.Int).Sub(deposit, balance)
+			tx, err := u.entryPoint.Transact(&depositOpts, "depositTo", smartAccount)
+			if err != nil {
+				log.Error().Err(err).Msg("Unable to deposit for the smart account")
+				return nil, err
+			}
+			if _, err = bind.WaitMined(ctx, c, tx); err != nil {
+				return nil, err
+			}
+		}
+		log.Debug().Stringer("owner", account.Address).Stringer("smartAccount", smartAccount).Msg("Initialized smart account")
+	}
+	return u, nil
+}
+
+// loadTestUserOp sends a UserOperation making a call from the smart account of the sending
+// account. The UserOperation is sent to the bundler if one is given, otherwise the sending
+// account bundles it with handleOps. Every UserOperation uses a random nonce key so that
+// several of them can be in flight for the same smart account.
+func loadTestUserOp(ctx context.Context, c *ethclient.Client, account *loadTestAccount, nonce uint64, u *userOpLoadTest) (t1 time.Time, t2 time.Time, txHash ethcommon.Hash, err error) {
+	ltp := inputLoadTestParams
+	chainID := new(big.Int).SetUint64(*ltp.ChainID)
+
+	// The smart account only holds its EntryPoint deposit, so the call doesn't send any value.
+	callData, err := u.accountABI.Pack("execute", *ltp.ToETHAddress, big.NewInt(0), []byte{})
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to pack the smart account call")
+		return
+	}
+	nonceKey := make([]byte, 24)
+	_, _ = randSrc.Read(nonceKey)
+	gasPrice, gasTipCap := getSuggestedGasPrices(ctx, c)
+	if gasPrice == nil {
+		err = errors.New("unable to get the suggested gas prices")
+		return
+	}
+	if gasTipCap == nil || *ltp.LegacyTransactionMode {
+		gasTipCap = gasPrice
+	}
+	op := packedUserOperation{
+		Sender:             u.smartAccounts[account.Address],
+		Nonce:              new(big.Int).Lsh(new(big.Int).SetBytes(nonceKey), 64),
+		InitCode:           []byte{},
+		CallData:           callData,
+		AccountGasLimits:   packUints128(big.NewInt(userOpVerificationGasLimit), big.NewInt(userOpCallGasLimit)),
+		PreVerificationGas: big.NewInt(userOpPreVerificationGas),
+		GasFees:            packUints128(gasTipCap, gasPrice),
+		PaymasterAndData:   []byte{},
+	}
+	opHash, err := getUserOpHash(op, u.entryPointAddr, chainID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to hash the user operation")
+		return
+	}
+	signature, err := ethcrypto.Sign(accounts.TextHash(opHash[:]), account.PrivateKey)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to sign the user operation")
+		return
+	}
+	signature[64] += 27
+	op.Signature = signature
+
+	if *ltp.CallOnly {
+		err = errors.New("call only mode isn't supported for user operations")
+		return
+	}
+
+	var bundleTx ethcommon.Hash
+	t1 = time.Now()
+	defer func() { t2 = time.Now() }()
+	if u.bundler != nil {
+		rpcOp := rpcUserOperation{
+			Sender:               op.Sender,
+			Nonce:                (*hexutil.Big)(op.Nonce),
+			CallData:             op.CallData,
+			CallGasLimit:         (*hexutil.Big)(big.NewInt(userOpCallGasLimit)),
+			VerificationGasLimit: (*hexutil.Big)(big.NewInt(userOpVerificationGasLimit)),
+			PreVerificationGas:   (*hexutil.Big)(op.PreVerificationGas),
+			MaxFeePerGas:         (*hexutil.Big)(gasPrice),
+			MaxPriorityFeePerGas: (*hexutil.Big)(gasTipCap),
+			Signature:            op.Signature,
+		}
+		// The bundle transaction isn't known yet, so the user operation hash is reported
+		// until the receipts are looked up.
+		err = u.bundler.CallContext(ctx, &txHash, "eth_sendUserOperation", rpcOp, u.entryPointAddr)
+		if err == nil && txHash != opHash {
+			log.Warn().Stringer("bundlerHash", txHash).Stringer("opHash", opHash).Msg("The bundler returned an unexpected user operation hash")
+			txHash = opHash
+		}
+	} else {
+		tops, iErr := bind.NewKeyedTransactorWithChainID(account.PrivateKey, chainID)
+		if iErr != nil {
+			err = iErr
+			log.Error().Err(err).Msg("Unable create transaction signer")
+			return
+		}
+		tops.Nonce = new(big.Int).SetUint64(nonce)
+		tops = configureTransactOpts(ctx, c, tops)
+		var tx *ethtypes.Transaction
+		tx, err = u.entryPoint.Transact(tops, "handleOps", []packedUserOperation{op}, account.Address)
+		if err == nil {
+			txHash = tx.Hash()
+			bundleTx = txHash
+		}
+	}
+	u.recordSent(opHash, t1, bundleTx, err)
+	return
+}
+
+// isBundledUserOp returns whether the requests of a mode are user operations sent to a bundler,
+// rather than transactions of the sending accounts.
+func isBundledUserOp(mode loadTestMode) bool {
+	return mode == loadTestModeUserOp && userOps != nil && userOps.bundler != nil
+}
+
+// packUints128 packs two values in the high and low 128 bits of a word.
+func packUints128(high, low *big.Int) [32]byte {
+	var word [32]byte
+	high.FillBytes(word[:16])
+	low.FillBytes(word[16:])
+	return word
+}
+
+// getUserOpHash returns the hash signed by the owner of the smart account, as computed by the
+// v0.7 EntryPoint.
+func getUserOpHash(op packedUserOperation, entryPoint ethcommon.Address, chainID *big.Int) (ethcommon.Hash, error) {
+	addressType, _ := gethabi.NewType("address", "", nil)
+	uint256Type, _ := gethabi.NewType("uint256", "", nil)
+	bytes32Type, _ := gethabi.NewType("bytes32", "", nil)
+
+	packed, err := gethabi.Arguments{
+		{Type: addressType}, {Type: uint256Type}, {Type: bytes32Type}, {Type: bytes32Type},
+		{Type: bytes32Type}, {Type: uint256Type}, {Type: bytes32Type}, {Type: bytes32Type},
+	}.Pack(
+		op.Sender, op.Nonce, ethcrypto.Keccak256Hash(op.InitCode), ethcrypto.Keccak256Hash(op.CallData),
+		op.AccountGasLimits, op.PreVerificationGas, op.GasFees, ethcrypto.Keccak256Hash(op.PaymasterAndData),
+	)
+	if err != nil {
+		return ethcommon.Hash{}, err
+	}
+	encoded, err := gethabi.Arguments{{Type: bytes32Type}, {Type: addressType}, {Type: uint256Type}}.Pack(
+		ethcrypto.Keccak256Hash(packed), entryPoint, chainID,
+	)
+	if err != nil {
+		return ethcommon.Hash{}, err
+	}
+	return ethcrypto.Keccak256Hash(encoded), nil
+}
+
+func (u *userOpLoadTest) recordSent(opHash ethcommon.Hash, sentTime time.Time, bundleTx ethcommon.Hash, err error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if err != nil {
+		u.sendFailures[getUserOpFailureReason(err.Error())]++
+		return
+	}
+	u.sent = append(u.sent, sentUserOp{Hash: opHash, SentTime: sentTime, BundleTx: bundleTx})
+}
+
+// getUserOpFailureReason returns the EntryPoint error code of a failure, if there is one.
+func getUserOpFailureReason(msg string) string {
+	if code := entryPointErrorCode.FindString(msg); code != "" {
+		return strings.TrimSpace(code)
+	}
+	if msg == "" {
+		return "unknown"
+	}
+	return msg
+}
+
+// resolve looks up the outcome of every UserOperation sent during the load test and computes
+// the summary. The bundle transactions are matched with the blocks seen by the inclusion
+// tracker to get the inclusion latencies.
+func (u *userOpLoadTest) resolve(ctx context.Context, c *ethclient.Client) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	summary := &UserOpSummary{FailureReasons: make(map[string]int)}
+	for reason, count := range u.sendFailures {
+		summary.FailureReasons[reason] += count
+		summary.Failed += count
+	}
+	latencies := make([]float64, 0, len(u.sent))
+	bundleTxs := make(map[ethcommon.Hash]ethcommon.Hash)
+	for _, op := range u.sent {
+		summary.Sent++
+		bundleTx, blockNumber, success, reason, found := u.getUserOpOutcome(ctx, c, op)
+		if !found {
+			summary.Missing++
+			continue
+		}
+		bundleTxs[op.Hash] = bundleTx
+		if !success {
+			summary.Failed++
+			summary.FailureReasons[reason]++
+			continue
+		}
+		summary.Included++
+		if inclusions == nil {
+			continue
+		}
+		// The bundle transactions sent by a bundler aren't tracked while the load test runs,
+		// so they're matched with the block that included them.
+		if seenTime, seen := inclusions.trackBlockInclusion(bundleTx, blockNumber); seen {
+			latencies = append(latencies, seenTime.Sub(op.SentTime).Seconds())
+		}
+	}
+	summary.P50, _ = stats.Percentile(latencies, 50)
+	summary.P90, _ = stats.Percentile(latencies, 90)
+	summary.P99, _ = stats.Percentile(latencies, 99)
+	u.summary = summary
+
+	// The samples of the user operations sent to a bundler get the hash of their bundle
+	// transaction, so that they're summarized like the other transactions.
+	if u.bundler == nil {
+		return
+	}
+	loadTestResutsMutex.Lock()
+	defer loadTestResutsMutex.Unlock()
+	for k, s := range loadTestResults {
+		if bundleTx, found := bundleTxs[s.TxHash]; found && s.Mode == loadTestModeUserOp {
+			loadTestResults[k].TxHash = bundleTx
+		}
+	}
+}
+
+// getUserOpOutcome returns the bundle transaction of a UserOperation, the block that included
+// it, and whether its execution succeeded.
+func (u *userOpLoadTest) getUserOpOutcome(ctx context.Context, c *ethclient.Client, op sentUserOp) (bundleTx ethcommon.Hash, blockNumber uint64, success bool, reason string, found bool) {
+	if u.bundler != nil {
+		var receipt *userOpReceipt
+		if err := u.bundler.CallContext(ctx, &receipt, "eth_getUserOperationReceipt", op.Hash); err != nil || receipt == nil {
+			return
+		}
+		return receipt.Receipt.TransactionHash, uint64(receipt.Receipt.BlockNumber), receipt.Success, getUserOpFailureReason(receipt.Reason), true
+	}
+
+	receipt, err := c.TransactionReceipt(ctx, op.BundleTx)
+	if err != nil {
+		return
+	}
+	blockNumber = receipt.BlockNumber.Uint64()
+	if receipt.Status != ethtypes.ReceiptStatusSuccessful {
+		return op.BundleTx, blockNumber, false, "handleOps reverted", true
+	}
+	event := u.entryPointABI.Events["UserOperationEvent"]
+	revertEvent := u.entryPointABI.Events["UserOperationRevertReason"]
+	reason = "unknown"
+	for _, l := range receipt.Logs {
+		if len(l.Topics) < 2 || l.Topics[1] != op.Hash {
+			continue
+		}
+		switch l.Topics[0] {
+		case revertEvent.ID:
+			values, err := revertEvent.Inputs.NonIndexed().Unpack(l.Data)
+			if err == nil && len(values) == 2 {
+				reason = fmt.Sprintf("reverted: %s", hexutil.Encode(values[1].([]byte)))
+			}
+		case event.ID:
+			values, err := event.Inputs.NonIndexed().Unpack(l.Data)
+			if err == nil && len(values) == 4 {
+				success = values[1].(bool)
+				found = true
+			}
+		}
+	}
+	return op.BundleTx, blockNumber, success, reason, found
+}
+
+// userOpLightSummary logs the outcome and the inclusion latencies of the UserOperations.
+func userOpLightSummary() {
+	if userOps == nil || userOps.summary == nil {
+		return
+	}
+	s := userOps.summary
+	log.Info().
+		Int("sent", s.Sent).
+		Int("included", s.Included).
+		Int("failed", s.Failed).
+		Int("missing", s.Missing).
+		Float64("p50", s.P50).
+		Float64("p90", s.P90).
+		Float64("p99", s.P99).
+		Msg("User Operation Inclusion Latency Stats")
+	reasons := make([]string, 0, len(s.FailureReasons))
+	for reason := range s.FailureReasons {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		log.Info().Str("reason", reason).Int("count", s.FailureReasons[reason]).Msg("User Operation failures")
+	}
+}
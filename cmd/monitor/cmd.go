@@ -22,6 +22,8 @@ var (
 	subBatchSize    int
 	blockCacheLimit int
 	intervalStr     string
+	headless        bool
+	prometheusPort  uint
 
 	defaultBatchSize = 100
 )
@@ -85,6 +87,8 @@ func init() {
 	MonitorCmd.PersistentFlags().IntVarP(&subBatchSize, "sub-batch-size", "s", 50, "Number of requests per sub-batch")
 	MonitorCmd.PersistentFlags().IntVarP(&blockCacheLimit, "cache-limit", "c", 200, "Number of cached blocks for the LRU block data structure (Min 100)")
 	MonitorCmd.PersistentFlags().StringVarP(&intervalStr, "interval", "i", "5s", "Amount of time between batch block rpc calls")
+	MonitorCmd.PersistentFlags().BoolVar(&headless, "headless", false, "Print a JSON line for every new block instead of rendering the terminal UI")
+	MonitorCmd.PersistentFlags().UintVar(&prometheusPort, "prometheus-port", 0, "If set with --headless, the head block, block time, txpool and zkEVM batch gauges are exposed on this port at the /metrics endpoint")
 }
 
 func checkFlags() (err error) {
@@ -112,5 +116,9 @@ func checkFlags() (err error) {
 		return fmt.Errorf("block-cache can't be less than 100")
 	}
 
+	if prometheusPort > 0 && !headless {
+		return fmt.Errorf("the prometheus port can only be used in headless mode")
+	}
+
 	return nil
 }
@@ -0,0 +1,210 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/0xPolygon/polygon-cli/metrics"
+	"github.com/0xPolygon/polygon-cli/rpctypes"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// headlessBlockEvent is the JSON line printed for every new block in headless mode.
+	headlessBlockEvent struct {
+		Number    uint64            `json:"number"`
+		Hash      ethcommon.Hash    `json:"hash"`
+		Timestamp uint64            `json:"timestamp"`
+		BlockTime *uint64           `json:"blockTime,omitempty"`
+		TxCount   int               `json:"txCount"`
+		GasUsed   uint64            `json:"gasUsed"`
+		GasLimit  uint64            `json:"gasLimit"`
+		BaseFee   *big.Int          `json:"baseFee,omitempty"`
+		Signer    ethcommon.Address `json:"signer"`
+	}
+
+	monitorMetrics struct {
+		headBlock     prometheus.Gauge
+		blockTime     prometheus.Gauge
+		txPoolPending prometheus.Gauge
+		txPoolQueued  prometheus.Gauge
+		zkEVMBatches  *prometheus.GaugeVec
+	}
+)
+
+// startMetricsServer registers the monitor gauges and starts a server to expose them at the
+// /metrics endpoint. The txpool and zkEVM batch gauges are only registered when the RPC
+// endpoint supports them. An error is returned when the port can't be listened on.
+func startMetricsServer(port uint, txPoolStatusSupported, zkEVMBatchesSupported bool) (*monitorMetrics, error) {
+	m := &monitorMetrics{
+		headBlock: promauto.NewGauge(prometheus.GaugeOpts{
+			Namespace: "monitor",
+			Name:      "head_block",
+			Help:      "The number of the latest block",
+		}),
+		blockTime: promauto.NewGauge(prometheus.GaugeOpts{
+			Namespace: "monitor",
+			Name:      "block_time_seconds",
+			Help:      "The time between the latest block and its parent",
+		}),
+	}
+	if txPoolStatusSupported {
+		m.txPoolPending = promauto.NewGauge(prometheus.GaugeOpts{
+			Namespace: "monitor",
+			Name:      "txpool_pending",
+			Help:      "The number of pending transactions reported by txpool_status",
+		})
+		m.txPoolQueued = promauto.NewGauge(prometheus.GaugeOpts{
+			Namespace: "monitor",
+			Name:      "txpool_queued",
+			Help:      "The number of queued transactions reported by txpool_status",
+		})
+	}
+	if zkEVMBatchesSupported {
+		m.zkEVMBatches = promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "monitor",
+			Name:      "zkevm_batch",
+			Help:      "The number of the latest trusted, virtual and verified zkEVM batches",
+		}, []string{"status"})
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to start Prometheus handler")
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Error().Err(err).Msg("Prometheus handler stopped")
+		}
+	}()
+	return m, nil
+}
+
+// updateChainState sets the gauges of the chain state fetched by fetchCurrentBlockData.
+func (m *monitorMetrics) updateChainState(ms *monitorStatus) {
+	m.headBlock.Set(float64(ms.HeadBlock.Uint64()))
+	if m.txPoolPending != nil {
+		m.txPoolPending.Set(float64(ms.TxPoolStatus.pending))
+		m.txPoolQueued.Set(float64(ms.TxPoolStatus.queued))
+	}
+	if m.zkEVMBatches != nil {
+		m.zkEVMBatches.WithLabelValues("trusted").Set(float64(ms.ZkEVMBatches.trusted))
+		m.zkEVMBatches.WithLabelValues("virtual").Set(float64(ms.ZkEVMBatches.virtual))
+		m.zkEVMBatches.WithLabelValues("verified").Set(float64(ms.ZkEVMBatches.verified))
+	}
+}
+
+// runHeadless polls the chain like the terminal UI, but prints a JSON line to stdout for
+// every new block instead of rendering the dashboard. Errors while polling are logged and
+// the next poll is attempted, so that it can run unattended.
+func runHeadless(ctx context.Context, ec *ethclient.Client, ms *monitorStatus, rpc *ethrpc.Client, txPoolStatusSupported, zkEVMBatchesSupported, peerCountSupported bool) error {
+	var m *monitorMetrics
+	if prometheusPort > 0 {
+		var err error
+		m, err = startMetricsServer(prometheusPort, txPoolStatusSupported, zkEVMBatchesSupported)
+		if err != nil {
+			return err
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	var lastBlock *big.Int
+	for {
+		if err := fetchCurrentBlockData(ctx, ec, ms, false, txPoolStatusSupported, zkEVMBatchesSupported, peerCountSupported); err != nil {
+			log.Warn().Err(err).Msg("Unable to fetch current block data")
+		} else {
+			if m != nil {
+				m.updateChainState(ms)
+			}
+			if lastBlock == nil {
+				lastBlock = new(big.Int).Sub(ms.HeadBlock, one)
+			}
+			if ms.HeadBlock.Cmp(lastBlock) > 0 {
+				lastBlock = ms.emitNewBlocks(ctx, rpc, encoder, m, lastBlock)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// emitNewBlocks fetches the blocks after lastBlock up to the head and prints them. It returns
+// the last block that was printed.
+func (ms *monitorStatus) emitNewBlocks(ctx context.Context, rpc *ethrpc.Client, encoder *json.Encoder, m *monitorMetrics, lastBlock *big.Int) *big.Int {
+	head := new(big.Int).Set(ms.HeadBlock)
+	if err := ms.getBlockRange(ctx, head, rpc); err != nil {
+		log.Warn().Err(err).Msg("Unable to fetch the new blocks")
+		return lastBlock
+	}
+
+	from := new(big.Int).Add(lastBlock, one)
+	if from.Cmp(ms.LowerBlock) < 0 {
+		log.Warn().Str("from", from.String()).Str("to", ms.LowerBlock.String()).Msg("Skipping blocks older than the batch size")
+		from.Set(ms.LowerBlock)
+	}
+	for n := from; n.Cmp(head) <= 0; n.Add(n, one) {
+		ms.BlocksLock.RLock()
+		cached, ok := ms.BlockCache.Get(n.String())
+		var parent any
+		if ok {
+			parent, _ = ms.BlockCache.Get(new(big.Int).Sub(n, one).String())
+		}
+		ms.BlocksLock.RUnlock()
+		if !ok {
+			log.Warn().Str("blockNumber", n.String()).Msg("Block missing from the cache")
+			continue
+		}
+
+		block := cached.(rpctypes.PolyBlock)
+		event := getHeadlessBlockEvent(block)
+		if parentBlock, isBlock := parent.(rpctypes.PolyBlock); isBlock && block.Time() >= parentBlock.Time() {
+			blockTime := block.Time() - parentBlock.Time()
+			event.BlockTime = &blockTime
+			if m != nil {
+				m.blockTime.Set(float64(blockTime))
+			}
+		}
+		if err := encoder.Encode(event); err != nil {
+			log.Error().Err(err).Msg("Unable to write the block event")
+		}
+	}
+	return head
+}
+
+func getHeadlessBlockEvent(block rpctypes.PolyBlock) headlessBlockEvent {
+	signer := block.Miner()
+	if signer == (ethcommon.Address{}) {
+		if recovered, err := metrics.Ecrecover(&block); err == nil {
+			signer = ethcommon.BytesToAddress(recovered)
+		}
+	}
+	return headlessBlockEvent{
+		Number:    block.Number().Uint64(),
+		Hash:      block.Hash(),
+		Timestamp: block.Time(),
+		TxCount:   len(block.Transactions()),
+		GasUsed:   block.GasUsed(),
+		GasLimit:  block.GasLimit(),
+		BaseFee:   block.BaseFee(),
+		Signer:    signer,
+	}
+}
@@ -166,6 +166,10 @@ func monitor(ctx context.Context) error {
 
 	observedPendingTxs = make(historicalRange, 0)
 
+	if headless {
+		return runHeadless(ctx, ec, ms, rpc, txPoolStatusSupported, zkEVMBatchesSupported, peerCountSupported)
+	}
+
 	isUiRendered := false
 	errChan := make(chan error)
 	go func() {